@@ -2,5 +2,258 @@ package iam
 
 // AuthenticationService is the service for an authentication.
 type AuthenticationService interface {
-	Authenticate(tenantID TenantID, username, password string) (*User, error)
+	Authenticate(tenantID TenantID, username, password string) (*Authentication, error)
+	VerifySecondFactor(tenantID TenantID, username, challenge, code string) (*Authentication, error)
+}
+
+// AuthenticationStatus is the enum type for the outcome of a successful authentication.
+type AuthenticationStatus string
+
+// Authenticated is the status of a complete authentication.
+// PasswordChangeRequired is the status of an authentication that must be followed by a password change.
+// MFARequired is the status of an authentication waiting for the second factor challenge to be completed.
+// MFAEnrollmentRequired is the status of an authentication that must be followed by a second factor enrollment.
+const (
+	Authenticated          AuthenticationStatus = "authenticated"
+	PasswordChangeRequired AuthenticationStatus = "passwordChangeRequired"
+	MFARequired            AuthenticationStatus = "mfaRequired"
+	MFAEnrollmentRequired  AuthenticationStatus = "mfaEnrollmentRequired"
+)
+
+// Authentication is the value object holding the outcome of a successful authentication.
+// While a second factor is required User is nil and Challenge holds the token to complete it with.
+type Authentication struct {
+	Status    AuthenticationStatus
+	User      *User
+	Challenge string
+}
+
+// IsComplete will check if the authentication grants a full login.
+func (a *Authentication) IsComplete() bool {
+	return a.Status == Authenticated
+}
+
+// NewAuthenticationService will create a new authentication service backed by supplied repositories.
+func NewAuthenticationService(
+	tenantRepository TenantRepository,
+	userRepository UserRepository,
+	eventPublisher EventPublisher,
+) AuthenticationService {
+	return &authenticationService{
+		tenantRepository: tenantRepository,
+		userRepository:   userRepository,
+		eventPublisher:   eventPublisher,
+	}
+}
+
+type authenticationService struct {
+	tenantRepository TenantRepository
+	userRepository   UserRepository
+	eventPublisher   EventPublisher
+}
+
+// Authenticate will authenticate the user with supplied credentials for the tenant.
+// Unknown, disabled, locked out and password-less users get the same error as a wrong password, after the same
+// password hashing work, so that neither the outcome nor its timing disclose which usernames exist.
+func (s *authenticationService) Authenticate(tenantID TenantID, username, password string) (*Authentication, error) {
+	const op = "Authenticate"
+	tenant, user, err := s.lookup(op, tenantID, username)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil || !user.IsEnabled() || user.Password == "" {
+		verifyDummyPassword(password)
+		return nil, invalidCredentials(op)
+	}
+	events := user.ExpireLockout()
+	if user.IsLockedOut() {
+		verifyDummyPassword(password)
+		return nil, invalidCredentials(op)
+	}
+	if !user.VerifyPassword(password) {
+		if err := s.fail(op, tenant, user, events); err != nil {
+			return nil, err
+		}
+		return nil, invalidCredentials(op)
+	}
+	if user.resetFailedAuthentications() || len(events) > 0 {
+		if err := s.updateLockout(op, user); err != nil {
+			return nil, err
+		}
+	}
+	if user.PasswordNeedsRehash() {
+		// The password was just verified, rehashing it without verifying twice.
+		rehashed, err := user.rehashPassword(password)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, rehashed...)
+	}
+	events = append(events, user.ExpirePassword(tenant.PasswordPolicy.MaxAge)...)
+	return s.succeed(op, tenant, user, events)
+}
+
+// VerifySecondFactor will complete the challenge of an authentication with a TOTP or recovery code.
+// Wrong codes count as failed authentications.
+func (s *authenticationService) VerifySecondFactor(tenantID TenantID, username, challenge, code string) (*Authentication, error) {
+	const op = "VerifySecondFactor"
+	tenant, user, err := s.lookup(op, tenantID, username)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil || !user.IsEnabled() || !user.HasSecondFactorChallenge(challenge) {
+		return nil, &Error{Code: EUNAUTHORIZED, Message: "Second factor challenge is invalid or expired.", Op: op}
+	}
+	if user.IsLockedOut() {
+		return nil, &Error{Code: EUNAUTHORIZED, Message: "User is temporarily locked out.", Op: op}
+	}
+	events, ok := user.VerifySecondFactor(code)
+	if !ok {
+		if err := s.fail(op, tenant, user, nil); err != nil {
+			return nil, err
+		}
+		return nil, &Error{Code: EUNAUTHORIZED, Message: "Invalid second factor code.", Op: op}
+	}
+	if user.resetFailedAuthentications() {
+		if err := s.updateLockout(op, user); err != nil {
+			return nil, err
+		}
+	}
+	if err := s.update(op, user); err != nil {
+		return nil, err
+	}
+	if err := s.publish(events); err != nil {
+		return nil, err
+	}
+	return s.authentication(tenant, user), nil
+}
+
+// invalidCredentials will return the uniform error of a failed password authentication.
+func invalidCredentials(op string) error {
+	return &Error{Code: EUNAUTHORIZED, Message: "Invalid username or password.", Op: op}
+}
+
+// lookup will retrieve the active tenant and the user with supplied username, nil if not found.
+func (s *authenticationService) lookup(op string, tenantID TenantID, username string) (*Tenant, *User, error) {
+	tenant, err := s.tenantRepository.TenantOfID(tenantID)
+	if err != nil {
+		return nil, nil, &Error{
+			Code:    EINTERNAL,
+			Message: "An unexpected error occurred while retrieving tenant.",
+			Op:      op,
+			Err:     err,
+		}
+	}
+	if tenant == nil {
+		return nil, nil, &Error{Code: ENOTFOUND, Message: "Tenant not found.", Op: op}
+	}
+	if err := tenant.assertActive(op); err != nil {
+		return nil, nil, err
+	}
+	user, err := s.userRepository.UserWithUsername(tenantID, username)
+	if err != nil {
+		return nil, nil, &Error{
+			Code:    EINTERNAL,
+			Message: "An unexpected error occurred while retrieving user.",
+			Op:      op,
+			Err:     err,
+		}
+	}
+	return tenant, user, nil
+}
+
+// succeed will complete the verification of the first factor of the user, saving the changes it caused.
+// Users with a second factor get a challenge to complete with VerifySecondFactor.
+func (s *authenticationService) succeed(op string, tenant *Tenant, user *User, events Events) (*Authentication, error) {
+	if user.HasMFA() {
+		challenge, err := user.ChallengeSecondFactor()
+		if err != nil {
+			return nil, err
+		}
+		if err := s.update(op, user); err != nil {
+			return nil, err
+		}
+		if err := s.publish(events); err != nil {
+			return nil, err
+		}
+		return &Authentication{Status: MFARequired, Challenge: challenge}, nil
+	}
+	if err := s.save(op, user, events); err != nil {
+		return nil, err
+	}
+	return s.authentication(tenant, user), nil
+}
+
+// authentication will return the outcome of an authentication with every factor verified.
+func (s *authenticationService) authentication(tenant *Tenant, user *User) *Authentication {
+	switch {
+	case tenant.MFARequired && !user.HasMFA():
+		return &Authentication{Status: MFAEnrollmentRequired, User: user}
+	case user.MustChangePassword:
+		return &Authentication{Status: PasswordChangeRequired, User: user}
+	}
+	return &Authentication{Status: Authenticated, User: user}
+}
+
+// fail will atomically count the failed authentication of the user, locking it out as the tenant policy requires.
+func (s *authenticationService) fail(op string, tenant *Tenant, user *User, events Events) error {
+	attempts, err := s.userRepository.IncrementFailedAttempts(user.TenantID, user.Username)
+	if err != nil {
+		return &Error{
+			Code:    EINTERNAL,
+			Message: "An unexpected error occurred while updating user.",
+			Op:      op,
+			Err:     err,
+		}
+	}
+	events = append(events, user.FailAuthentication(attempts, tenant.LockoutPolicy)...)
+	if len(events) == 0 {
+		return nil
+	}
+	if err := s.updateLockout(op, user); err != nil {
+		return err
+	}
+	return s.eventPublisher.Publish(events)
+}
+
+func (s *authenticationService) updateLockout(op string, user *User) error {
+	if err := s.userRepository.UpdateLockout(user); err != nil {
+		return &Error{
+			Code:    EINTERNAL,
+			Message: "An unexpected error occurred while updating user.",
+			Op:      op,
+			Err:     err,
+		}
+	}
+	return nil
+}
+
+// save will update the user and publish the events, when the authentication changed it.
+func (s *authenticationService) save(op string, user *User, events Events) error {
+	if len(events) == 0 {
+		return nil
+	}
+	if err := s.update(op, user); err != nil {
+		return err
+	}
+	return s.eventPublisher.Publish(events)
+}
+
+func (s *authenticationService) update(op string, user *User) error {
+	if err := s.userRepository.Update(user); err != nil {
+		return &Error{
+			Code:    EINTERNAL,
+			Message: "An unexpected error occurred while updating user.",
+			Op:      op,
+			Err:     err,
+		}
+	}
+	return nil
+}
+
+func (s *authenticationService) publish(events Events) error {
+	if len(events) == 0 {
+		return nil
+	}
+	return s.eventPublisher.Publish(events)
 }
@@ -0,0 +1,246 @@
+package iam_test
+
+import (
+	"encoding/base64"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"golang.org/x/crypto/bcrypt"
+
+	. "github.com/maurofran/iam"
+	"github.com/maurofran/iam/mock"
+)
+
+var _ = Describe("Authentication service", func() {
+	var (
+		tenant  *Tenant
+		user    *User
+		tr      *mock.TenantRepository
+		ur      *mock.UserRepository
+		ep      *mock.EventPublisher
+		events  Events
+		service AuthenticationService
+	)
+
+	BeforeEach(func() {
+		enc, err := bcrypt.GenerateFromPassword([]byte("gV7#pLq2!wZx"), bcrypt.MinCost)
+		Expect(err).NotTo(HaveOccurred())
+		tenant = &Tenant{ID: "tenant", Name: "Tenant", Active: true}
+		user = &User{
+			TenantID:   tenant.ID,
+			Username:   "jdoe",
+			Password:   base64.StdEncoding.EncodeToString(enc),
+			Enablement: IndefiniteEnablement(),
+		}
+		tr = &mock.TenantRepository{
+			TenantOfIDFn: func(tenantID TenantID) (*Tenant, error) {
+				if tenantID == tenant.ID {
+					return tenant, nil
+				}
+				return nil, nil
+			},
+		}
+		ur = &mock.UserRepository{
+			UserWithUsernameFn: func(tenantID TenantID, username string) (*User, error) {
+				if tenantID == user.TenantID && username == user.Username {
+					return user, nil
+				}
+				return nil, nil
+			},
+			UpdateFn: func(*User) error {
+				return nil
+			},
+			IncrementFailedAttemptsFn: func(TenantID, string) (int, error) {
+				return user.FailedAttempts + 1, nil
+			},
+			UpdateLockoutFn: func(*User) error {
+				return nil
+			},
+		}
+		events = nil
+		ep = &mock.EventPublisher{
+			PublishFn: func(ee Events) error {
+				events = append(events, ee...)
+				return nil
+			},
+		}
+		service = NewAuthenticationService(tr, ur, ep)
+	})
+
+	Describe("#Authenticate", func() {
+		It("should return the user for valid credentials", func() {
+			a, err := service.Authenticate(tenant.ID, "jdoe", "gV7#pLq2!wZx")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(a.Status).To(Equal(Authenticated))
+			Expect(a.User).To(Equal(user))
+		})
+		It("should rehash a legacy password", func() {
+			_, err := service.Authenticate(tenant.ID, "jdoe", "gV7#pLq2!wZx")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ur.UpdateInvoked).To(BeTrue())
+			Expect(user.Password).To(HavePrefix("$argon2id$"))
+			Expect(user.VerifyPassword("gV7#pLq2!wZx")).To(BeTrue())
+			Expect(events).To(HaveLen(1))
+			Expect(events[0].Type).To(Equal("UserPasswordRehashed"))
+		})
+		It("should not rehash an up to date password", func() {
+			_, err := service.Authenticate(tenant.ID, "jdoe", "gV7#pLq2!wZx")
+			Expect(err).NotTo(HaveOccurred())
+			ur.UpdateInvoked = false
+			_, err = service.Authenticate(tenant.ID, "jdoe", "gV7#pLq2!wZx")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ur.UpdateInvoked).To(BeFalse())
+		})
+		It("should require a password change when flagged", func() {
+			user.RequirePasswordChange()
+			a, err := service.Authenticate(tenant.ID, "jdoe", "gV7#pLq2!wZx")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(a.Status).To(Equal(PasswordChangeRequired))
+			Expect(a.IsComplete()).To(BeFalse())
+		})
+		It("should expire an old password", func() {
+			tenant.PasswordPolicy.MaxAge = 24 * time.Hour
+			user.PasswordChangedAt = time.Now().Add(-48 * time.Hour)
+			a, err := service.Authenticate(tenant.ID, "jdoe", "gV7#pLq2!wZx")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(a.Status).To(Equal(PasswordChangeRequired))
+			Expect(user.MustChangePassword).To(BeTrue())
+			Expect(events).To(ContainElement(WithTransform(func(e *Event) string { return e.Type }, Equal("UserPasswordExpired"))))
+		})
+		It("should reject a wrong password", func() {
+			a, err := service.Authenticate(tenant.ID, "jdoe", "wrong")
+			Expect(a).To(BeNil())
+			Expect(ErrorCode(err)).To(Equal(EUNAUTHORIZED))
+		})
+		It("should reject an unknown user", func() {
+			a, err := service.Authenticate(tenant.ID, "unknown", "gV7#pLq2!wZx")
+			Expect(a).To(BeNil())
+			Expect(ErrorCode(err)).To(Equal(EUNAUTHORIZED))
+		})
+		It("should reject a disabled user", func() {
+			user.Enablement.Enabled = false
+			a, err := service.Authenticate(tenant.ID, "jdoe", "gV7#pLq2!wZx")
+			Expect(a).To(BeNil())
+			Expect(ErrorCode(err)).To(Equal(EUNAUTHORIZED))
+		})
+		It("should reject an inactive tenant", func() {
+			tenant.Active = false
+			a, err := service.Authenticate(tenant.ID, "jdoe", "gV7#pLq2!wZx")
+			Expect(a).To(BeNil())
+			Expect(ErrorCode(err)).To(Equal(ECONFLICT))
+			Expect(ur.UserWithUsernameInvoked).To(BeFalse())
+		})
+		It("should reject an unknown tenant", func() {
+			a, err := service.Authenticate("unknown", "jdoe", "gV7#pLq2!wZx")
+			Expect(a).To(BeNil())
+			Expect(ErrorCode(err)).To(Equal(ENOTFOUND))
+		})
+	})
+
+	Describe("lockout", func() {
+		BeforeEach(func() {
+			tenant.LockoutPolicy = LockoutPolicy{MaxFailedAttempts: 3, Duration: time.Minute, MaxDuration: time.Hour}
+		})
+
+		It("should count failed authentications", func() {
+			_, err := service.Authenticate(tenant.ID, "jdoe", "wrong")
+			Expect(ErrorCode(err)).To(Equal(EUNAUTHORIZED))
+			Expect(ur.IncrementFailedAttemptsInvoked).To(BeTrue())
+			Expect(user.FailedAttempts).To(Equal(1))
+			Expect(events).To(BeEmpty())
+		})
+		It("should lock the user out at the threshold", func() {
+			for i := 0; i < 3; i++ {
+				service.Authenticate(tenant.ID, "jdoe", "wrong")
+			}
+			Expect(user.IsLockedOut()).To(BeTrue())
+			Expect(ur.UpdateLockoutInvoked).To(BeTrue())
+			Expect(events).To(HaveLen(1))
+			Expect(events[0].Type).To(Equal("UserLockedOut"))
+			_, err := service.Authenticate(tenant.ID, "jdoe", "gV7#pLq2!wZx")
+			Expect(err).To(Equal(&Error{Code: EUNAUTHORIZED, Message: "Invalid username or password.", Op: "Authenticate"}))
+		})
+		It("should unlock the user after the cooldown", func() {
+			user.Lockouts = 1
+			user.LockedUntil = time.Now().Add(-time.Second)
+			a, err := service.Authenticate(tenant.ID, "jdoe", "gV7#pLq2!wZx")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(a.IsComplete()).To(BeTrue())
+			Expect(user.LockedUntil.IsZero()).To(BeTrue())
+			Expect(user.Lockouts).To(BeZero())
+			Expect(events[0].Type).To(Equal("UserUnlocked"))
+		})
+		It("should not count failures when disabled", func() {
+			tenant.LockoutPolicy = LockoutPolicy{}
+			for i := 0; i < 10; i++ {
+				service.Authenticate(tenant.ID, "jdoe", "wrong")
+			}
+			Expect(user.IsLockedOut()).To(BeFalse())
+		})
+	})
+
+	Describe("dummy password verification", func() {
+		var (
+			hasher   *countingHasher
+			previous PasswordHasher
+		)
+
+		BeforeEach(func() {
+			previous = DefaultPasswordHasher
+			hasher = &countingHasher{}
+			DefaultPasswordHasher = hasher
+		})
+
+		AfterEach(func() {
+			DefaultPasswordHasher = previous
+		})
+
+		authenticate := func(username string) {
+			_, err := service.Authenticate(tenant.ID, username, "wrong")
+			Expect(err).To(Equal(&Error{Code: EUNAUTHORIZED, Message: "Invalid username or password.", Op: "Authenticate"}))
+		}
+
+		It("should verify the dummy password for unknown users", func() {
+			authenticate("unknown")
+			Expect(hasher.verifications).To(Equal(1))
+		})
+		It("should verify the dummy password for disabled users", func() {
+			user.Enablement.Enabled = false
+			authenticate("jdoe")
+			Expect(hasher.verifications).To(Equal(1))
+		})
+		It("should verify the dummy password for locked out users", func() {
+			user.LockedUntil = time.Now().Add(time.Hour)
+			authenticate("jdoe")
+			Expect(hasher.verifications).To(Equal(1))
+		})
+		It("should verify the dummy password for users without password", func() {
+			user.Password = ""
+			authenticate("jdoe")
+			Expect(hasher.verifications).To(Equal(1))
+		})
+		It("should verify the password of the user otherwise", func() {
+			authenticate("jdoe")
+			Expect(hasher.verifications).To(BeZero())
+		})
+	})
+})
+
+// countingHasher is the password hasher counting the verified passwords.
+type countingHasher struct {
+	verifications int
+}
+
+func (h *countingHasher) Hash(password string) (string, error) {
+	return "$counting$" + password, nil
+}
+
+func (h *countingHasher) Verify(password, encoded string) bool {
+	h.verifications++
+	return encoded == "$counting$"+password
+}
+
+func (h *countingHasher) NeedsRehash(string) bool {
+	return false
+}
@@ -0,0 +1,92 @@
+//go:build timing
+// +build timing
+
+package iam_test
+
+import (
+	"sort"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/maurofran/iam"
+	"github.com/maurofran/iam/mock"
+)
+
+var _ = Describe("Authentication service timing", func() {
+	const samples = 101
+
+	var (
+		tenant  *Tenant
+		user    *User
+		service AuthenticationService
+	)
+
+	BeforeEach(func() {
+		password, err := DefaultPasswordHasher.Hash("gV7#pLq2!wZx")
+		Expect(err).NotTo(HaveOccurred())
+		tenant = &Tenant{ID: "tenant", Name: "Tenant", Active: true}
+		user = &User{
+			TenantID:   tenant.ID,
+			Username:   "jdoe",
+			Password:   password,
+			Enablement: IndefiniteEnablement(),
+		}
+		tr := &mock.TenantRepository{
+			TenantOfIDFn: func(TenantID) (*Tenant, error) {
+				return tenant, nil
+			},
+		}
+		ur := &mock.UserRepository{
+			UserWithUsernameFn: func(_ TenantID, username string) (*User, error) {
+				if username == user.Username {
+					return user, nil
+				}
+				return nil, nil
+			},
+			IncrementFailedAttemptsFn: func(TenantID, string) (int, error) {
+				return 0, nil
+			},
+		}
+		ep := &mock.EventPublisher{
+			PublishFn: func(Events) error {
+				return nil
+			},
+		}
+		service = NewAuthenticationService(tr, ur, ep)
+		service.Authenticate(tenant.ID, "unknown", "wrong")
+	})
+
+	// quartiles will return the first and third quartiles of the durations of failed authentications of username.
+	quartiles := func(username string) (time.Duration, time.Duration) {
+		durations := make([]time.Duration, samples)
+		for i := range durations {
+			start := time.Now()
+			_, err := service.Authenticate(tenant.ID, username, "wrong")
+			durations[i] = time.Since(start)
+			Expect(ErrorCode(err)).To(Equal(EUNAUTHORIZED))
+		}
+		sort.Slice(durations, func(i, j int) bool { return durations[i] < durations[j] })
+		return durations[samples/4], durations[3*samples/4]
+	}
+
+	// expectOverlap will check that the interquartile ranges of the durations of failed authentications overlap.
+	expectOverlap := func(username string, change func()) {
+		knownQ1, knownQ3 := quartiles("jdoe")
+		change()
+		q1, q3 := quartiles(username)
+		Expect(q1).To(BeNumerically("<=", knownQ3))
+		Expect(knownQ1).To(BeNumerically("<=", q3))
+	}
+
+	It("should not disclose unknown users", func() {
+		expectOverlap("unknown", func() {})
+	})
+	It("should not disclose disabled users", func() {
+		expectOverlap("jdoe", func() { user.Enablement.Enabled = false })
+	})
+	It("should not disclose locked out users", func() {
+		expectOverlap("jdoe", func() { user.LockedUntil = time.Now().Add(time.Hour) })
+	})
+})
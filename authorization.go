@@ -4,4 +4,164 @@ package iam
 type AuthorizationService interface {
 	IsUsernameInRole(tenantID TenantID, username, roleName string) (bool, error)
 	IsUserInRole(user *User, roleName string) (bool, error)
+	UserRoles(user *User) ([]string, error)
+	IsServiceAccountNameInRole(tenantID TenantID, name, roleName string) (bool, error)
+	IsServiceAccountInRole(account *ServiceAccount, roleName string) (bool, error)
+	ServiceAccountRoles(account *ServiceAccount) ([]string, error)
+}
+
+// NewAuthorizationService will create a new authorization service backed by supplied repositories.
+func NewAuthorizationService(
+	userRepository UserRepository,
+	serviceAccountRepository ServiceAccountRepository,
+	groupRepository GroupRepository,
+	roleRepository RoleRepository,
+) AuthorizationService {
+	return &authorizationService{
+		userRepository:           userRepository,
+		serviceAccountRepository: serviceAccountRepository,
+		roleRepository:           roleRepository,
+		groupMemberService:       NewGroupMemberService(groupRepository),
+	}
+}
+
+type authorizationService struct {
+	userRepository           UserRepository
+	serviceAccountRepository ServiceAccountRepository
+	roleRepository           RoleRepository
+	groupMemberService       GroupMemberService
+}
+
+// IsUsernameInRole will check if the user with supplied username is in the role.
+func (s *authorizationService) IsUsernameInRole(tenantID TenantID, username, roleName string) (bool, error) {
+	user, err := s.userRepository.UserWithUsername(tenantID, username)
+	if err != nil {
+		return false, &Error{
+			Code:    EINTERNAL,
+			Message: "An unexpected error occurred while retrieving user.",
+			Op:      "IsUsernameInRole",
+			Err:     err,
+		}
+	}
+	if user == nil {
+		return false, nil
+	}
+	return s.IsUserInRole(user, roleName)
+}
+
+// IsUserInRole will check if supplied user is in the role, either directly or through nested groups.
+func (s *authorizationService) IsUserInRole(user *User, roleName string) (bool, error) {
+	const op = "IsUserInRole"
+	if user == nil || !user.IsEnabled() {
+		return false, nil
+	}
+	role, err := s.role(op, user.TenantID, roleName)
+	if err != nil || role == nil {
+		return false, err
+	}
+	return role.IsInRole(user, s.groupMemberService)
+}
+
+// UserRoles will return the names of the roles supplied user is in, either directly or through nested groups.
+func (s *authorizationService) UserRoles(user *User) ([]string, error) {
+	const op = "UserRoles"
+	if user == nil || !user.IsEnabled() {
+		return nil, nil
+	}
+	roles, err := s.roles(op, user.TenantID)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, role := range roles {
+		in, err := role.IsInRole(user, s.groupMemberService)
+		if err != nil {
+			return nil, err
+		}
+		if in {
+			names = append(names, role.Name)
+		}
+	}
+	return names, nil
+}
+
+// IsServiceAccountNameInRole will check if the service account with supplied name is in the role.
+func (s *authorizationService) IsServiceAccountNameInRole(tenantID TenantID, name, roleName string) (bool, error) {
+	account, err := s.serviceAccountRepository.ServiceAccountNamed(tenantID, name)
+	if err != nil {
+		return false, &Error{
+			Code:    EINTERNAL,
+			Message: "An unexpected error occurred while retrieving service account.",
+			Op:      "IsServiceAccountNameInRole",
+			Err:     err,
+		}
+	}
+	if account == nil {
+		return false, nil
+	}
+	return s.IsServiceAccountInRole(account, roleName)
+}
+
+// IsServiceAccountInRole will check if supplied service account is in the role, either directly or through
+// nested groups.
+func (s *authorizationService) IsServiceAccountInRole(account *ServiceAccount, roleName string) (bool, error) {
+	const op = "IsServiceAccountInRole"
+	if account == nil || !account.IsEnabled() {
+		return false, nil
+	}
+	role, err := s.role(op, account.TenantID, roleName)
+	if err != nil || role == nil {
+		return false, err
+	}
+	return role.IsServiceAccountInRole(account, s.groupMemberService)
+}
+
+// ServiceAccountRoles will return the names of the roles supplied service account is in, either directly or
+// through nested groups.
+func (s *authorizationService) ServiceAccountRoles(account *ServiceAccount) ([]string, error) {
+	const op = "ServiceAccountRoles"
+	if account == nil || !account.IsEnabled() {
+		return nil, nil
+	}
+	roles, err := s.roles(op, account.TenantID)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, role := range roles {
+		in, err := role.IsServiceAccountInRole(account, s.groupMemberService)
+		if err != nil {
+			return nil, err
+		}
+		if in {
+			names = append(names, role.Name)
+		}
+	}
+	return names, nil
+}
+
+func (s *authorizationService) roles(op string, tenantID TenantID) (Roles, error) {
+	roles, err := s.roleRepository.AllRoles(tenantID)
+	if err != nil {
+		return nil, &Error{
+			Code:    EINTERNAL,
+			Message: "An unexpected error occurred while retrieving roles.",
+			Op:      op,
+			Err:     err,
+		}
+	}
+	return roles, nil
+}
+
+func (s *authorizationService) role(op string, tenantID TenantID, roleName string) (*Role, error) {
+	role, err := s.roleRepository.RoleNamed(tenantID, roleName)
+	if err != nil {
+		return nil, &Error{
+			Code:    EINTERNAL,
+			Message: "An unexpected error occurred while retrieving role.",
+			Op:      op,
+			Err:     err,
+		}
+	}
+	return role, nil
 }
@@ -0,0 +1,131 @@
+package iam_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/maurofran/iam"
+	"github.com/maurofran/iam/mock"
+)
+
+var _ = Describe("Authorization service", func() {
+	var (
+		user    *User
+		account *ServiceAccount
+		role    *Role
+		groups  map[string]*Group
+		service AuthorizationService
+	)
+
+	BeforeEach(func() {
+		user = &User{TenantID: "tenant", Username: "jdoe", Enablement: IndefiniteEnablement()}
+		account = &ServiceAccount{TenantID: "tenant", Name: "billing", Enablement: IndefiniteEnablement()}
+		groups = map[string]*Group{
+			"developers": {
+				TenantID: "tenant",
+				Name:     "developers",
+				Members:  GroupMembers{{Type: GroupGroupMember, Name: "backend"}},
+			},
+			"backend": {
+				TenantID: "tenant",
+				Name:     "backend",
+				Members: GroupMembers{
+					{Type: GroupGroupMember, Name: "developers"},
+					{Type: UserGroupMember, Name: "jdoe"},
+					{Type: ServiceAccountGroupMember, Name: "billing"},
+				},
+			},
+		}
+		role = &Role{
+			TenantID:        "tenant",
+			Name:            "deployer",
+			SupportsNesting: true,
+			Group: &Group{
+				TenantID: "tenant",
+				Name:     "ROLE-INTERNAL-GROUP: deployer",
+				Members:  GroupMembers{{Type: GroupGroupMember, Name: "developers"}},
+			},
+		}
+		ur := &mock.UserRepository{
+			UserWithUsernameFn: func(tenantID TenantID, username string) (*User, error) {
+				if username == user.Username {
+					return user, nil
+				}
+				return nil, nil
+			},
+		}
+		sar := &mock.ServiceAccountRepository{
+			ServiceAccountNamedFn: func(tenantID TenantID, name string) (*ServiceAccount, error) {
+				if name == account.Name {
+					return account, nil
+				}
+				return nil, nil
+			},
+		}
+		gr := &mock.GroupRepository{
+			GroupNamedFn: func(tenantID TenantID, name string) (*Group, error) {
+				return groups[name], nil
+			},
+		}
+		rr := &mock.RoleRepository{
+			RoleNamedFn: func(tenantID TenantID, name string) (*Role, error) {
+				if name == role.Name {
+					return role, nil
+				}
+				return nil, nil
+			},
+		}
+		service = NewAuthorizationService(ur, sar, gr, rr)
+	})
+
+	Describe("#IsUsernameInRole", func() {
+		It("should resolve nested groups", func() {
+			Expect(service.IsUsernameInRole("tenant", "jdoe", "deployer")).To(BeTrue())
+		})
+		It("should not resolve nested groups when nesting is not supported", func() {
+			role.SupportsNesting = false
+			Expect(service.IsUsernameInRole("tenant", "jdoe", "deployer")).To(BeFalse())
+		})
+		It("should resolve direct members when nesting is not supported", func() {
+			role.SupportsNesting = false
+			role.Group.Members = append(role.Group.Members, &GroupMember{Type: UserGroupMember, Name: "jdoe"})
+			Expect(service.IsUsernameInRole("tenant", "jdoe", "deployer")).To(BeTrue())
+		})
+		It("should terminate on cyclic nesting", func() {
+			Expect(service.IsUsernameInRole("tenant", "other", "deployer")).To(BeFalse())
+			user.Username = "other"
+			Expect(service.IsUsernameInRole("tenant", "other", "deployer")).To(BeFalse())
+		})
+		It("should skip disabled users", func() {
+			user.Enablement.Enabled = false
+			Expect(service.IsUsernameInRole("tenant", "jdoe", "deployer")).To(BeFalse())
+		})
+		It("should return false for unknown roles", func() {
+			Expect(service.IsUsernameInRole("tenant", "jdoe", "unknown")).To(BeFalse())
+		})
+	})
+
+	Describe("#IsServiceAccountNameInRole", func() {
+		It("should resolve nested groups", func() {
+			Expect(service.IsServiceAccountNameInRole("tenant", "billing", "deployer")).To(BeTrue())
+		})
+		It("should not mistake users for service accounts", func() {
+			account.Name = "jdoe"
+			Expect(service.IsServiceAccountNameInRole("tenant", "jdoe", "deployer")).To(BeFalse())
+		})
+		It("should resolve direct members when nesting is not supported", func() {
+			role.SupportsNesting = false
+			Expect(service.IsServiceAccountNameInRole("tenant", "billing", "deployer")).To(BeFalse())
+			_, err := role.AssignServiceAccount(account)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(service.IsServiceAccountNameInRole("tenant", "billing", "deployer")).To(BeTrue())
+		})
+		It("should skip disabled service accounts", func() {
+			account.Enablement.Enabled = false
+			Expect(service.IsServiceAccountNameInRole("tenant", "billing", "deployer")).To(BeFalse())
+		})
+		It("should return false for unknown service accounts", func() {
+			Expect(service.IsServiceAccountNameInRole("tenant", "unknown", "deployer")).To(BeFalse())
+		})
+	})
+})
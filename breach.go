@@ -0,0 +1,255 @@
+package iam
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// BreachedPasswordChecker is the interface for corpora of passwords exposed in known data breaches.
+type BreachedPasswordChecker interface {
+	// IsBreached will check if supplied password appears in the corpus.
+	IsBreached(password string) (bool, error)
+}
+
+// hashPrefixLength is the length of the SHA-1 hex prefix naming the buckets of the range format.
+const hashPrefixLength = 5
+
+// RangeFileChecker is the breached password checker reading a local mirror of the Have I Been Pwned range files.
+// Dir holds one file per upper case SHA-1 hex prefix of 5 characters, named after the prefix with an optional
+// .txt extension, listing a hash suffix and an occurrence count per line, as in 0018A45C4D1DEF81644B54AB7F969B88D65:1.
+type RangeFileChecker struct {
+	Dir string
+}
+
+// NewRangeFileChecker will create a new checker for the range files stored in supplied directory.
+func NewRangeFileChecker(dir string) *RangeFileChecker {
+	return &RangeFileChecker{Dir: dir}
+}
+
+// IsBreached will check if supplied password appears in the bucket of its hash prefix.
+func (c *RangeFileChecker) IsBreached(password string) (bool, error) {
+	hash := passwordSHA1(password)
+	prefix, suffix := hash[:hashPrefixLength], hash[hashPrefixLength:]
+	f, err := os.Open(filepath.Join(c.Dir, prefix))
+	if os.IsNotExist(err) {
+		f, err = os.Open(filepath.Join(c.Dir, prefix+".txt"))
+	}
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		if i := strings.IndexByte(line, ':'); i >= 0 {
+			line = line[:i]
+		}
+		if strings.EqualFold(line, suffix) {
+			return true, nil
+		}
+	}
+	return false, s.Err()
+}
+
+func passwordSHA1(password string) string {
+	sum := sha1.Sum([]byte(password))
+	return strings.ToUpper(hex.EncodeToString(sum[:]))
+}
+
+// BloomFilter is the breached password checker testing SHA-1 hashes against a compact probabilistic set.
+// A breached password is always reported, a safe one is wrongly reported with the false positive rate
+// the filter was built with.
+type BloomFilter struct {
+	bits   []uint64
+	size   uint64
+	hashes uint32
+}
+
+// NewBloomFilter will create an empty bloom filter sized for n hashes at supplied false positive rate.
+func NewBloomFilter(n uint64, falsePositiveRate float64) *BloomFilter {
+	if n == 0 {
+		n = 1
+	}
+	size := uint64(math.Ceil(-float64(n) * math.Log(falsePositiveRate) / (math.Ln2 * math.Ln2)))
+	if size < 64 {
+		size = 64
+	}
+	hashes := uint32(math.Round(float64(size) / float64(n) * math.Ln2))
+	if hashes < 1 {
+		hashes = 1
+	}
+	return &BloomFilter{bits: make([]uint64, (size+63)/64), size: size, hashes: hashes}
+}
+
+// Add will add supplied SHA-1 hash to the filter.
+func (f *BloomFilter) Add(hash [sha1.Size]byte) {
+	h1, h2 := f.split(hash)
+	for i := uint64(0); i < uint64(f.hashes); i++ {
+		bit := (h1 + i*h2) % f.size
+		f.bits[bit/64] |= 1 << (bit % 64)
+	}
+}
+
+// Contains will check if supplied SHA-1 hash was probably added to the filter.
+func (f *BloomFilter) Contains(hash [sha1.Size]byte) bool {
+	h1, h2 := f.split(hash)
+	for i := uint64(0); i < uint64(f.hashes); i++ {
+		bit := (h1 + i*h2) % f.size
+		if f.bits[bit/64]&(1<<(bit%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// split will derive the two hashes used for double hashing from the uniformly distributed SHA-1 bytes.
+func (f *BloomFilter) split(hash [sha1.Size]byte) (uint64, uint64) {
+	return binary.BigEndian.Uint64(hash[0:8]), binary.BigEndian.Uint64(hash[8:16]) | 1
+}
+
+// IsBreached will check if supplied password was probably added to the filter.
+func (f *BloomFilter) IsBreached(password string) (bool, error) {
+	return f.Contains(sha1.Sum([]byte(password))), nil
+}
+
+// bloomFilterMagic identifies the serialized bloom filters.
+// maxBloomFilterSize and maxBloomFilterHashes bound the filters read, so that a corrupted header cannot exhaust
+// memory: 2^35 bits hold billions of hashes at a false positive rate of 0.1%.
+const (
+	bloomFilterMagic     = "IAMBLOOM1"
+	maxBloomFilterSize   = 1 << 35
+	maxBloomFilterHashes = 64
+)
+
+// WriteTo will serialize the filter to supplied writer.
+func (f *BloomFilter) WriteTo(w io.Writer) (int64, error) {
+	bw := bufio.NewWriter(w)
+	header := make([]byte, len(bloomFilterMagic)+12)
+	copy(header, bloomFilterMagic)
+	binary.BigEndian.PutUint64(header[len(bloomFilterMagic):], f.size)
+	binary.BigEndian.PutUint32(header[len(bloomFilterMagic)+8:], f.hashes)
+	if _, err := bw.Write(header); err != nil {
+		return 0, err
+	}
+	word := make([]byte, 8)
+	for _, b := range f.bits {
+		binary.BigEndian.PutUint64(word, b)
+		if _, err := bw.Write(word); err != nil {
+			return 0, err
+		}
+	}
+	return int64(len(header) + 8*len(f.bits)), bw.Flush()
+}
+
+// ReadBloomFilter will deserialize a bloom filter written by WriteTo. The size of the filter is checked against
+// the remaining length of seekable readers, such as files, before allocating it.
+func ReadBloomFilter(r io.Reader) (*BloomFilter, error) {
+	corrupted := errors.New("corrupted bloom filter")
+	header := make([]byte, len(bloomFilterMagic)+12)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, err
+	}
+	if string(header[:len(bloomFilterMagic)]) != bloomFilterMagic {
+		return nil, errors.New("not a bloom filter")
+	}
+	size := binary.BigEndian.Uint64(header[len(bloomFilterMagic):])
+	hashes := binary.BigEndian.Uint32(header[len(bloomFilterMagic)+8:])
+	if size == 0 || size > maxBloomFilterSize || hashes == 0 || hashes > maxBloomFilterHashes {
+		return nil, corrupted
+	}
+	words := (size + 63) / 64
+	if s, ok := r.(io.Seeker); ok {
+		remaining, err := remainingLength(s)
+		if err != nil {
+			return nil, err
+		}
+		if remaining < int64(words*8) {
+			return nil, corrupted
+		}
+	}
+	br := bufio.NewReader(r)
+	f := &BloomFilter{bits: make([]uint64, words), size: size, hashes: hashes}
+	word := make([]byte, 8)
+	for i := range f.bits {
+		if _, err := io.ReadFull(br, word); err != nil {
+			return nil, err
+		}
+		f.bits[i] = binary.BigEndian.Uint64(word)
+	}
+	return f, nil
+}
+
+// remainingLength will return the number of bytes after the current offset of the seeker, leaving it unchanged.
+func remainingLength(s io.Seeker) (int64, error) {
+	offset, err := s.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return 0, err
+	}
+	end, err := s.Seek(0, io.SeekEnd)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := s.Seek(offset, io.SeekStart); err != nil {
+		return 0, err
+	}
+	return end - offset, nil
+}
+
+// BuildBloomFilter will build a bloom filter for n hashes from a dump listing a SHA-1 hex hash per line,
+// optionally followed by a colon and the occurrence count, as in the ordered Have I Been Pwned downloads.
+func BuildBloomFilter(dump io.Reader, n uint64, falsePositiveRate float64) (*BloomFilter, error) {
+	f := NewBloomFilter(n, falsePositiveRate)
+	s := bufio.NewScanner(dump)
+	for line := 1; s.Scan(); line++ {
+		text := strings.TrimSpace(s.Text())
+		if i := strings.IndexByte(text, ':'); i >= 0 {
+			text = text[:i]
+		}
+		if text == "" {
+			continue
+		}
+		var hash [sha1.Size]byte
+		if len(text) != 2*sha1.Size {
+			return nil, fmt.Errorf("line %d: invalid SHA-1 hash", line)
+		}
+		if _, err := hex.Decode(hash[:], []byte(text)); err != nil {
+			return nil, fmt.Errorf("line %d: %v", line, err)
+		}
+		f.Add(hash)
+	}
+	return f, s.Err()
+}
+
+// checkBreached will reject supplied password if it appears in the corpus of the checker, if any.
+func checkBreached(op, password string, checker BreachedPasswordChecker) error {
+	if checker == nil {
+		return nil
+	}
+	breached, err := checker.IsBreached(password)
+	if err != nil {
+		return &Error{
+			Code:    EINTERNAL,
+			Message: "An unexpected error occurred while checking breached passwords.",
+			Op:      op,
+			Err:     err,
+		}
+	}
+	if breached {
+		return &Error{
+			Code:    EINVALID,
+			Message: "Password appears in a known data breach.",
+			Op:      op,
+			Err:     &PasswordPolicyViolation{Rules: []PasswordRule{PasswordBreached}},
+		}
+	}
+	return nil
+}
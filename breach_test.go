@@ -0,0 +1,162 @@
+package iam_test
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+
+	. "github.com/maurofran/iam"
+	"github.com/maurofran/iam/mock"
+)
+
+func sha1Hex(password string) string {
+	sum := sha1.Sum([]byte(password))
+	return strings.ToUpper(hex.EncodeToString(sum[:]))
+}
+
+var _ = Describe("Breached passwords", func() {
+	breached := []string{"Password1234!", "gV7#pLq2!wZx-leaked"}
+
+	Describe("RangeFileChecker", func() {
+		var dir string
+
+		BeforeEach(func() {
+			var err error
+			dir, err = ioutil.TempDir("", "ranges")
+			Expect(err).NotTo(HaveOccurred())
+			for _, p := range append(breached, "Rk4$mN8@tYb1") {
+				hash := sha1Hex(p)
+				name := filepath.Join(dir, hash[:5]+".txt")
+				content := fmt.Sprintf("0000000000000000000000000000000000A:3\r\n%s:12\r\n", hash[5:])
+				if p == "Rk4$mN8@tYb1" {
+					content = "0000000000000000000000000000000000A:3\r\n"
+				}
+				Expect(ioutil.WriteFile(name, []byte(content), 0600)).To(Succeed())
+			}
+		})
+
+		AfterEach(func() {
+			os.RemoveAll(dir)
+		})
+
+		It("should find breached passwords in their bucket", func() {
+			checker := NewRangeFileChecker(dir)
+			for _, p := range breached {
+				Expect(checker.IsBreached(p)).To(BeTrue())
+			}
+			Expect(checker.IsBreached("Rk4$mN8@tYb1")).To(BeFalse())
+		})
+
+		It("should fail when the bucket is missing", func() {
+			_, err := NewRangeFileChecker(dir).IsBreached("Kx7#qZ2v-missing")
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Describe("BloomFilter", func() {
+		var dump string
+
+		BeforeEach(func() {
+			var lines []string
+			for _, p := range breached {
+				lines = append(lines, sha1Hex(p)+":12")
+			}
+			dump = strings.Join(lines, "\n")
+		})
+
+		It("should contain every hash of the dump", func() {
+			filter, err := BuildBloomFilter(strings.NewReader(dump), 2, 0.001)
+			Expect(err).NotTo(HaveOccurred())
+			for _, p := range breached {
+				Expect(filter.IsBreached(p)).To(BeTrue())
+			}
+			Expect(filter.IsBreached("Rk4$mN8@tYb1")).To(BeFalse())
+		})
+
+		It("should survive serialization", func() {
+			filter, err := BuildBloomFilter(strings.NewReader(dump), 2, 0.001)
+			Expect(err).NotTo(HaveOccurred())
+			var buf bytes.Buffer
+			_, err = filter.WriteTo(&buf)
+			Expect(err).NotTo(HaveOccurred())
+			read, err := ReadBloomFilter(&buf)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(read).To(Equal(filter))
+		})
+
+		It("should reject malformed dumps", func() {
+			_, err := BuildBloomFilter(strings.NewReader("not-a-hash:1"), 1, 0.001)
+			Expect(err).To(HaveOccurred())
+		})
+
+		DescribeTable("should reject corrupted headers without allocating the filter",
+			func(size uint64, hashes uint32, words int) {
+				header := make([]byte, 21)
+				copy(header, "IAMBLOOM1")
+				binary.BigEndian.PutUint64(header[9:], size)
+				binary.BigEndian.PutUint32(header[17:], hashes)
+				_, err := ReadBloomFilter(bytes.NewReader(append(header, make([]byte, 8*words)...)))
+				Expect(err).To(MatchError("corrupted bloom filter"))
+			},
+			Entry("empty filter", uint64(0), uint32(7), 0),
+			Entry("no hash", uint64(64), uint32(0), 1),
+			Entry("too many hashes", uint64(64), uint32(1<<20), 1),
+			Entry("oversized filter", uint64(1)<<63, uint32(7), 1),
+			Entry("longer than the file", uint64(1)<<30, uint32(7), 1),
+		)
+	})
+
+	Describe("User", func() {
+		var checker *mock.BreachedPasswordChecker
+
+		BeforeEach(func() {
+			checker = &mock.BreachedPasswordChecker{
+				IsBreachedFn: func(password string) (bool, error) {
+					return password == "gV7#pLq2!wZx-leaked", nil
+				},
+			}
+		})
+
+		It("should reject breached passwords on registration", func() {
+			_, _, err := NewUser("tenant", "jdoe", "gV7#pLq2!wZx-leaked", nil, DefaultPasswordPolicy(), checker)
+			Expect(ErrorCode(err)).To(Equal(EINVALID))
+			Expect(err.(*Error).Err.(*PasswordPolicyViolation).Rules).To(ConsistOf(PasswordBreached))
+			Expect(checker.IsBreachedInvoked).To(BeTrue())
+		})
+
+		It("should reject breached passwords on registration through an invitation", func() {
+			t := &Tenant{ID: "tenant", Active: true, PasswordPolicy: DefaultPasswordPolicy()}
+			i, _, err := t.OfferRegistrationInvitation("Open registration")
+			Expect(err).NotTo(HaveOccurred())
+			_, _, err = t.RegisterUser(i.ID, "jdoe", "gV7#pLq2!wZx-leaked", nil, checker)
+			Expect(ErrorCode(err)).To(Equal(EINVALID))
+			Expect(err.(*Error).Err.(*PasswordPolicyViolation).Rules).To(ConsistOf(PasswordBreached))
+		})
+
+		It("should reject breached passwords on change", func() {
+			u, _, err := NewUser("tenant", "jdoe", "gV7#pLq2!wZx", nil, DefaultPasswordPolicy(), checker)
+			Expect(err).NotTo(HaveOccurred())
+			_, err = u.ChangePassword("gV7#pLq2!wZx", "gV7#pLq2!wZx-leaked", DefaultPasswordPolicy(), checker)
+			Expect(ErrorCode(err)).To(Equal(EINVALID))
+			Expect(u.VerifyPassword("gV7#pLq2!wZx")).To(BeTrue())
+		})
+
+		It("should report checker failures as internal errors", func() {
+			checker.IsBreachedFn = func(string) (bool, error) {
+				return false, os.ErrNotExist
+			}
+			_, _, err := NewUser("tenant", "jdoe", "gV7#pLq2!wZx", nil, DefaultPasswordPolicy(), checker)
+			Expect(ErrorCode(err)).To(Equal(EINTERNAL))
+		})
+	})
+})
@@ -0,0 +1,373 @@
+package iam
+
+import (
+	"net/url"
+	"strings"
+	"time"
+)
+
+// GrantType is the enum type for the OAuth 2.0 grants a client may use.
+type GrantType string
+
+// AuthorizationCodeGrant is the grant exchanging an authorization code, protected with PKCE, for tokens.
+// ClientCredentialsGrant is the grant of confidential clients acting as their service account.
+// RefreshTokenGrant is the grant exchanging a refresh token for new tokens.
+const (
+	AuthorizationCodeGrant GrantType = "authorization_code"
+	ClientCredentialsGrant GrantType = "client_credentials"
+	RefreshTokenGrant      GrantType = "refresh_token"
+)
+
+// Clients is the collection of clients.
+type Clients []*Client
+
+// Client is the aggregate root representing an application registered to obtain tokens through OAuth 2.0.
+// Secret is the hash of the secret of confidential clients, empty for public ones. Redirect URIs are matched
+// exactly. Scopes are the scope values the client may request. ServiceAccount is the name of the service account
+// the client acts as with the client credentials grant.
+type Client struct {
+	TenantID       TenantID    `bson:"tenantId"`
+	ID             string      `bson:"clientId"`
+	Name           string      `bson:"name"`
+	Secret         string      `bson:"secret,omitempty" json:"-"`
+	RedirectURIs   []string    `bson:"redirectUris"`
+	GrantTypes     []GrantType `bson:"grantTypes"`
+	Scopes         []string    `bson:"scopes,omitempty"`
+	ServiceAccount string      `bson:"serviceAccount,omitempty"`
+	CreatedAt      time.Time   `bson:"createdAt"`
+}
+
+// ClientRegistration is the value object holding the data needed to register a client.
+type ClientRegistration struct {
+	Name           string
+	Public         bool
+	RedirectURIs   []string
+	GrantTypes     []GrantType
+	Scopes         []string
+	ServiceAccount string
+}
+
+// newClient will create a new client from supplied registration, returning the secret of confidential clients.
+func newClient(op string, tenantID TenantID, registration ClientRegistration) (*Client, string, error) {
+	if registration.Name == "" {
+		return nil, "", &Error{Code: EINVALID, Message: "Client name is required.", Op: op}
+	}
+	c := &Client{
+		TenantID:       tenantID,
+		Name:           registration.Name,
+		RedirectURIs:   registration.RedirectURIs,
+		GrantTypes:     registration.GrantTypes,
+		Scopes:         registration.Scopes,
+		ServiceAccount: registration.ServiceAccount,
+		CreatedAt:      time.Now(),
+	}
+	if err := c.validate(op, registration.Public); err != nil {
+		return nil, "", err
+	}
+	id, err := newIdentity(op)
+	if err != nil {
+		return nil, "", err
+	}
+	c.ID = id
+	if registration.Public {
+		return c, "", nil
+	}
+	secret, err := newToken(op)
+	if err != nil {
+		return nil, "", err
+	}
+	c.Secret = hashToken(secret)
+	return c, secret, nil
+}
+
+// validate will check that grants, redirect URIs, scopes and service account of the client are consistent.
+func (c *Client) validate(op string, public bool) error {
+	if len(c.GrantTypes) == 0 {
+		return &Error{Code: EINVALID, Message: "At least a grant type is required.", Op: op}
+	}
+	for _, g := range c.GrantTypes {
+		switch g {
+		case AuthorizationCodeGrant, RefreshTokenGrant:
+		case ClientCredentialsGrant:
+			if public {
+				return &Error{Code: EINVALID, Message: "Public clients cannot use client credentials.", Op: op}
+			}
+			if c.ServiceAccount == "" {
+				return &Error{Code: EINVALID, Message: "Client credentials require a service account.", Op: op}
+			}
+		default:
+			return &Error{Code: EINVALID, Message: "Unsupported grant type.", Op: op}
+		}
+	}
+	if c.AllowsGrant(AuthorizationCodeGrant) && len(c.RedirectURIs) == 0 {
+		return &Error{Code: EINVALID, Message: "Authorization code requires a redirect URI.", Op: op}
+	}
+	for _, uri := range c.RedirectURIs {
+		u, err := url.Parse(uri)
+		if err != nil || !u.IsAbs() || u.Fragment != "" {
+			return &Error{Code: EINVALID, Message: "Redirect URIs must be absolute and without fragment.", Op: op}
+		}
+	}
+	for _, scope := range c.Scopes {
+		if len(strings.Fields(scope)) != 1 || strings.TrimSpace(scope) != scope {
+			return &Error{Code: EINVALID, Message: "Scopes must be single values without spaces.", Op: op}
+		}
+	}
+	return nil
+}
+
+// IsConfidential will check if the client authenticates with a secret.
+func (c *Client) IsConfidential() bool {
+	return c.Secret != ""
+}
+
+// VerifySecret will check supplied secret against the one of the client.
+func (c *Client) VerifySecret(secret string) bool {
+	return tokenMatches(secret, c.Secret)
+}
+
+// AllowsGrant will check if the client may use supplied grant type.
+func (c *Client) AllowsGrant(grantType GrantType) bool {
+	for _, g := range c.GrantTypes {
+		if g == grantType {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowsRedirectURI will check if supplied URI is one of the registered redirect URIs.
+func (c *Client) AllowsRedirectURI(uri string) bool {
+	for _, r := range c.RedirectURIs {
+		if r == uri {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowsScope will check if every value of supplied scope is one of the scopes of the client.
+func (c *Client) AllowsScope(scope string) bool {
+	for _, value := range strings.Fields(scope) {
+		allowed := false
+		for _, s := range c.Scopes {
+			if s == value {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return false
+		}
+	}
+	return true
+}
+
+// RotateSecret will replace the secret of a confidential client, returning the new one.
+func (c *Client) RotateSecret() (string, Events, error) {
+	const op = "RotateSecret"
+	if !c.IsConfidential() {
+		return "", nil, &Error{Code: ECONFLICT, Message: "Public clients have no secret.", Op: op}
+	}
+	secret, err := newToken(op)
+	if err != nil {
+		return "", nil, err
+	}
+	c.Secret = hashToken(secret)
+	return secret, Events{EventWithPayload(&ClientSecretRotated{TenantID: c.TenantID, ClientID: c.ID})}, nil
+}
+
+// RedefineRedirectURIs will replace the redirect URIs of the client.
+func (c *Client) RedefineRedirectURIs(redirectURIs []string) (Events, error) {
+	previous := c.RedirectURIs
+	c.RedirectURIs = redirectURIs
+	if err := c.validate("RedefineRedirectURIs", !c.IsConfidential()); err != nil {
+		c.RedirectURIs = previous
+		return nil, err
+	}
+	return Events{EventWithPayload(&ClientRedirectURIsRedefined{
+		TenantID:     c.TenantID,
+		ClientID:     c.ID,
+		RedirectURIs: redirectURIs,
+	})}, nil
+}
+
+// ClientRepository is the repository of clients.
+type ClientRepository interface {
+	Add(*Client) error
+	Update(*Client) error
+	Remove(*Client) error
+	ClientOfID(TenantID, string) (*Client, error)
+	AllClients(TenantID) (Clients, error)
+}
+
+// ClientRegistered is the event raised when a client is registered for a tenant.
+type ClientRegistered struct {
+	TenantID   TenantID
+	ClientID   string
+	Name       string
+	GrantTypes []GrantType
+	Scopes     []string
+}
+
+// ClientSecretRotated is the event raised when the secret of a client is replaced.
+type ClientSecretRotated struct {
+	TenantID TenantID
+	ClientID string
+}
+
+// ClientRedirectURIsRedefined is the event raised when the redirect URIs of a client are replaced.
+type ClientRedirectURIsRedefined struct {
+	TenantID     TenantID
+	ClientID     string
+	RedirectURIs []string
+}
+
+// ClientService is the service managing the client registry of tenants.
+type ClientService interface {
+	RegisterClient(tenantID TenantID, registration ClientRegistration) (*Client, string, error)
+	RotateClientSecret(tenantID TenantID, clientID string) (string, error)
+	RedefineRedirectURIs(tenantID TenantID, clientID string, redirectURIs []string) error
+}
+
+// NewClientService will create a new client service backed by supplied repositories.
+func NewClientService(
+	tenantRepository TenantRepository,
+	clientRepository ClientRepository,
+	serviceAccountRepository ServiceAccountRepository,
+	eventPublisher EventPublisher,
+) ClientService {
+	return &clientService{
+		tenantRepository:         tenantRepository,
+		clientRepository:         clientRepository,
+		serviceAccountRepository: serviceAccountRepository,
+		eventPublisher:           eventPublisher,
+	}
+}
+
+type clientService struct {
+	tenantRepository         TenantRepository
+	clientRepository         ClientRepository
+	serviceAccountRepository ServiceAccountRepository
+	eventPublisher           EventPublisher
+}
+
+// RegisterClient will register a new client for the tenant, returning its secret unless it is public.
+func (s *clientService) RegisterClient(tenantID TenantID, registration ClientRegistration) (*Client, string, error) {
+	const op = "RegisterClient"
+	tenant, err := s.tenant(op, tenantID)
+	if err != nil {
+		return nil, "", err
+	}
+	if registration.ServiceAccount != "" {
+		account, err := s.serviceAccountRepository.ServiceAccountNamed(tenantID, registration.ServiceAccount)
+		if err != nil {
+			return nil, "", &Error{
+				Code:    EINTERNAL,
+				Message: "An unexpected error occurred while retrieving service account.",
+				Op:      op,
+				Err:     err,
+			}
+		}
+		if account == nil {
+			return nil, "", &Error{Code: EINVALID, Message: "Service account not found.", Op: op}
+		}
+	}
+	client, secret, events, err := tenant.RegisterClient(registration)
+	if err != nil {
+		return nil, "", err
+	}
+	if err := s.clientRepository.Add(client); err != nil {
+		return nil, "", &Error{
+			Code:    EINTERNAL,
+			Message: "An unexpected error occurred while adding client.",
+			Op:      op,
+			Err:     err,
+		}
+	}
+	if err := s.eventPublisher.Publish(events); err != nil {
+		return nil, "", err
+	}
+	return client, secret, nil
+}
+
+// RotateClientSecret will replace the secret of the confidential client, returning the new one.
+func (s *clientService) RotateClientSecret(tenantID TenantID, clientID string) (string, error) {
+	const op = "RotateClientSecret"
+	client, err := s.client(op, tenantID, clientID)
+	if err != nil {
+		return "", err
+	}
+	secret, events, err := client.RotateSecret()
+	if err != nil {
+		return "", err
+	}
+	if err := s.save(op, client, events); err != nil {
+		return "", err
+	}
+	return secret, nil
+}
+
+// RedefineRedirectURIs will replace the redirect URIs of the client.
+func (s *clientService) RedefineRedirectURIs(tenantID TenantID, clientID string, redirectURIs []string) error {
+	const op = "RedefineRedirectURIs"
+	client, err := s.client(op, tenantID, clientID)
+	if err != nil {
+		return err
+	}
+	events, err := client.RedefineRedirectURIs(redirectURIs)
+	if err != nil {
+		return err
+	}
+	return s.save(op, client, events)
+}
+
+func (s *clientService) tenant(op string, tenantID TenantID) (*Tenant, error) {
+	tenant, err := s.tenantRepository.TenantOfID(tenantID)
+	if err != nil {
+		return nil, &Error{
+			Code:    EINTERNAL,
+			Message: "An unexpected error occurred while retrieving tenant.",
+			Op:      op,
+			Err:     err,
+		}
+	}
+	if tenant == nil {
+		return nil, &Error{Code: ENOTFOUND, Message: "Tenant not found.", Op: op}
+	}
+	if err := tenant.assertActive(op); err != nil {
+		return nil, err
+	}
+	return tenant, nil
+}
+
+func (s *clientService) client(op string, tenantID TenantID, clientID string) (*Client, error) {
+	if _, err := s.tenant(op, tenantID); err != nil {
+		return nil, err
+	}
+	client, err := s.clientRepository.ClientOfID(tenantID, clientID)
+	if err != nil {
+		return nil, &Error{
+			Code:    EINTERNAL,
+			Message: "An unexpected error occurred while retrieving client.",
+			Op:      op,
+			Err:     err,
+		}
+	}
+	if client == nil {
+		return nil, &Error{Code: ENOTFOUND, Message: "Client not found.", Op: op}
+	}
+	return client, nil
+}
+
+func (s *clientService) save(op string, client *Client, events Events) error {
+	if err := s.clientRepository.Update(client); err != nil {
+		return &Error{
+			Code:    EINTERNAL,
+			Message: "An unexpected error occurred while updating client.",
+			Op:      op,
+			Err:     err,
+		}
+	}
+	return s.eventPublisher.Publish(events)
+}
@@ -0,0 +1,229 @@
+package iam_test
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+
+	. "github.com/maurofran/iam"
+	"github.com/maurofran/iam/mock"
+)
+
+var _ = Describe("Client", func() {
+	const redirectURI = "https://app.example.com/callback"
+
+	var tenant *Tenant
+
+	BeforeEach(func() {
+		tenant = &Tenant{ID: "tenant", Active: true}
+	})
+
+	DescribeTable("#RegisterClient should validate the registration",
+		func(registration ClientRegistration, valid bool) {
+			registration.Name = "app"
+			_, _, _, err := tenant.RegisterClient(registration)
+			if valid {
+				Expect(err).NotTo(HaveOccurred())
+			} else {
+				Expect(ErrorCode(err)).To(Equal(EINVALID))
+			}
+		},
+		Entry("authorization code", ClientRegistration{
+			RedirectURIs: []string{redirectURI},
+			GrantTypes:   []GrantType{AuthorizationCodeGrant, RefreshTokenGrant},
+		}, true),
+		Entry("no grant type", ClientRegistration{RedirectURIs: []string{redirectURI}}, false),
+		Entry("unknown grant type", ClientRegistration{GrantTypes: []GrantType{"password"}}, false),
+		Entry("authorization code without redirect URI", ClientRegistration{
+			GrantTypes: []GrantType{AuthorizationCodeGrant},
+		}, false),
+		Entry("relative redirect URI", ClientRegistration{
+			RedirectURIs: []string{"/callback"},
+			GrantTypes:   []GrantType{AuthorizationCodeGrant},
+		}, false),
+		Entry("redirect URI with fragment", ClientRegistration{
+			RedirectURIs: []string{redirectURI + "#top"},
+			GrantTypes:   []GrantType{AuthorizationCodeGrant},
+		}, false),
+		Entry("scopes", ClientRegistration{
+			GrantTypes: []GrantType{RefreshTokenGrant},
+			Scopes:     []string{"openid", "profile"},
+		}, true),
+		Entry("scope with spaces", ClientRegistration{
+			GrantTypes: []GrantType{RefreshTokenGrant},
+			Scopes:     []string{"openid profile"},
+		}, false),
+		Entry("client credentials", ClientRegistration{
+			GrantTypes:     []GrantType{ClientCredentialsGrant},
+			ServiceAccount: "ci",
+		}, true),
+		Entry("client credentials without service account", ClientRegistration{
+			GrantTypes: []GrantType{ClientCredentialsGrant},
+		}, false),
+		Entry("public client credentials", ClientRegistration{
+			Public:         true,
+			GrantTypes:     []GrantType{ClientCredentialsGrant},
+			ServiceAccount: "ci",
+		}, false),
+	)
+
+	It("should store the hash of the secret of confidential clients", func() {
+		c, secret, events, err := tenant.RegisterClient(ClientRegistration{
+			Name:         "app",
+			RedirectURIs: []string{redirectURI},
+			GrantTypes:   []GrantType{AuthorizationCodeGrant},
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(c.ID).NotTo(BeEmpty())
+		Expect(c.IsConfidential()).To(BeTrue())
+		Expect(c.Secret).NotTo(Equal(secret))
+		Expect(c.VerifySecret(secret)).To(BeTrue())
+		Expect(c.VerifySecret("wrong")).To(BeFalse())
+		Expect(events[0].Type).To(Equal("ClientRegistered"))
+
+		rotated, _, err := c.RotateSecret()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(c.VerifySecret(secret)).To(BeFalse())
+		Expect(c.VerifySecret(rotated)).To(BeTrue())
+	})
+
+	It("should register public clients without secret", func() {
+		c, secret, _, err := tenant.RegisterClient(ClientRegistration{
+			Name:         "spa",
+			Public:       true,
+			RedirectURIs: []string{redirectURI},
+			GrantTypes:   []GrantType{AuthorizationCodeGrant},
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(secret).To(BeEmpty())
+		Expect(c.IsConfidential()).To(BeFalse())
+		Expect(c.VerifySecret("")).To(BeFalse())
+		_, _, err = c.RotateSecret()
+		Expect(ErrorCode(err)).To(Equal(ECONFLICT))
+	})
+
+	It("should match redirect URIs exactly", func() {
+		c := &Client{RedirectURIs: []string{redirectURI}}
+		Expect(c.AllowsRedirectURI(redirectURI)).To(BeTrue())
+		Expect(c.AllowsRedirectURI(redirectURI + "/")).To(BeFalse())
+		Expect(c.AllowsRedirectURI(redirectURI + "?next=/")).To(BeFalse())
+	})
+
+	It("should allow only the scopes of the client", func() {
+		c := &Client{Scopes: []string{"openid", "profile"}}
+		Expect(c.AllowsScope("")).To(BeTrue())
+		Expect(c.AllowsScope("openid profile")).To(BeTrue())
+		Expect(c.AllowsScope("openid email")).To(BeFalse())
+		Expect((&Client{}).AllowsScope("openid")).To(BeFalse())
+	})
+
+	It("should refuse registrations for inactive tenants", func() {
+		tenant.Active = false
+		_, _, _, err := tenant.RegisterClient(ClientRegistration{Name: "app", GrantTypes: []GrantType{RefreshTokenGrant}})
+		Expect(ErrorCode(err)).To(Equal(ECONFLICT))
+	})
+
+	Describe("client service", func() {
+		var (
+			sar     *mock.ServiceAccountRepository
+			cr      *mock.ClientRepository
+			service ClientService
+		)
+
+		BeforeEach(func() {
+			tr := &mock.TenantRepository{
+				TenantOfIDFn: func(TenantID) (*Tenant, error) {
+					return tenant, nil
+				},
+			}
+			sar = &mock.ServiceAccountRepository{
+				ServiceAccountNamedFn: func(TenantID, string) (*ServiceAccount, error) {
+					return nil, nil
+				},
+			}
+			cr = &mock.ClientRepository{
+				AddFn: func(*Client) error {
+					return nil
+				},
+			}
+			ep := &mock.EventPublisher{
+				PublishFn: func(Events) error {
+					return nil
+				},
+			}
+			service = NewClientService(tr, cr, sar, ep)
+		})
+
+		It("should refuse client credentials for an unknown service account", func() {
+			_, _, err := service.RegisterClient(tenant.ID, ClientRegistration{
+				Name:           "batch",
+				GrantTypes:     []GrantType{ClientCredentialsGrant},
+				ServiceAccount: "ci",
+			})
+			Expect(ErrorCode(err)).To(Equal(EINVALID))
+			Expect(cr.AddInvoked).To(BeFalse())
+		})
+		It("should add the registered client", func() {
+			c, secret, err := service.RegisterClient(tenant.ID, ClientRegistration{
+				Name:         "app",
+				RedirectURIs: []string{redirectURI},
+				GrantTypes:   []GrantType{AuthorizationCodeGrant},
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(cr.AddInvoked).To(BeTrue())
+			Expect(c.VerifySecret(secret)).To(BeTrue())
+		})
+	})
+})
+
+var _ = Describe("Authorization code", func() {
+	const (
+		redirectURI = "https://app.example.com/callback"
+		verifier    = "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+	)
+
+	var code *AuthorizationCode
+
+	BeforeEach(func() {
+		sum := sha256.Sum256([]byte(verifier))
+		var err error
+		code, _, _, err = NewAuthorizationCode(&AuthorizationRequest{
+			ClientID:      "app",
+			RedirectURI:   redirectURI,
+			CodeChallenge: base64.RawURLEncoding.EncodeToString(sum[:]),
+		}, &User{TenantID: "tenant", Username: "jdoe"})
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("should be redeemed once with the verifier of its challenge", func() {
+		events, err := code.Redeem("app", redirectURI, verifier)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(events[0].Type).To(Equal("AuthorizationCodeRedeemed"))
+		_, err = code.Redeem("app", redirectURI, verifier)
+		Expect(OAuthErrorOf(err).Code).To(Equal(OAuthInvalidGrant))
+	})
+
+	DescribeTable("should not be redeemed",
+		func(clientID, uri, v string) {
+			_, err := code.Redeem(clientID, uri, v)
+			Expect(ErrorCode(err)).To(Equal(EUNAUTHORIZED))
+			Expect(OAuthErrorOf(err).Code).To(Equal(OAuthInvalidGrant))
+			Expect(code.Redeemed).To(BeFalse())
+		},
+		Entry("by another client", "other", redirectURI, verifier),
+		Entry("for another redirect URI", "app", redirectURI+"/other", verifier),
+		Entry("with a wrong verifier", "app", redirectURI, strings.Repeat("a", 43)),
+		Entry("with a short verifier", "app", redirectURI, "short"),
+	)
+
+	It("should expire", func() {
+		code.ExpiresAt = time.Now().Add(-time.Second)
+		_, err := code.Redeem("app", redirectURI, verifier)
+		Expect(OAuthErrorOf(err).Code).To(Equal(OAuthInvalidGrant))
+	})
+})
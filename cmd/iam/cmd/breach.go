@@ -0,0 +1,84 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/maurofran/iam"
+	"github.com/spf13/cobra"
+)
+
+var (
+	dumpFile          string
+	filterFile        string
+	falsePositiveRate float64
+	expectedHashes    uint64
+)
+
+func init() {
+	buildFilterCmd.Flags().StringVar(&dumpFile, "dump", "", "breached passwords dump, one SHA-1 hash per line")
+	buildFilterCmd.Flags().StringVar(&filterFile, "output", "breached.bloom", "bloom filter file to write")
+	buildFilterCmd.Flags().Float64Var(&falsePositiveRate, "falsePositiveRate", 0.001, "false positive rate of the filter")
+	buildFilterCmd.Flags().Uint64Var(&expectedHashes, "count", 0, "number of hashes in the dump (default to counting the lines)")
+	buildFilterCmd.MarkFlagRequired("dump")
+	breachCmd.AddCommand(buildFilterCmd)
+	rootCmd.AddCommand(breachCmd)
+}
+
+var breachCmd = &cobra.Command{
+	Use:   "breach",
+	Short: "Manage the breached passwords corpus",
+}
+
+var buildFilterCmd = &cobra.Command{
+	Use:   "build-filter",
+	Short: "Build the bloom filter of breached passwords from a dump file",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if falsePositiveRate <= 0 || falsePositiveRate >= 1 {
+			return fmt.Errorf("false positive rate must be between 0 and 1")
+		}
+		in, err := os.Open(dumpFile)
+		if err != nil {
+			return err
+		}
+		defer in.Close()
+		n := expectedHashes
+		if n == 0 {
+			if n, err = countLines(in); err != nil {
+				return err
+			}
+			if _, err := in.Seek(0, io.SeekStart); err != nil {
+				return err
+			}
+		}
+		filter, err := iam.BuildBloomFilter(in, n, falsePositiveRate)
+		if err != nil {
+			return err
+		}
+		out, err := os.Create(filterFile)
+		if err != nil {
+			return err
+		}
+		size, err := filter.WriteTo(out)
+		if err != nil {
+			out.Close()
+			return err
+		}
+		if err := out.Close(); err != nil {
+			return err
+		}
+		fmt.Printf("Wrote %d hashes to %s (%d bytes)\n", n, filterFile, size)
+		return nil
+	},
+}
+
+func countLines(r io.Reader) (uint64, error) {
+	var n uint64
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		n++
+	}
+	return n, s.Err()
+}
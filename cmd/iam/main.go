@@ -1,7 +1,7 @@
 package main
 
 import (
-	"github.com/spf13/cobra/cobra/cmd"
+	"github.com/maurofran/iam/cmd/iam/cmd"
 )
 
 func main() {
@@ -1,12 +1,21 @@
 package main
 
 import (
+	nethttp "net/http"
+	"os"
+	"time"
+
 	"google.golang.org/grpc"
 	"upper.io/db.v3"
 
 	log "github.com/sirupsen/logrus"
 
 	"github.com/spf13/viper"
+
+	"github.com/maurofran/iam"
+	"github.com/maurofran/iam/http"
+	"github.com/maurofran/iam/jwt"
+	"github.com/maurofran/iam/mongo"
 )
 
 // Injected variables
@@ -19,6 +28,8 @@ var (
 var (
 	mongoDB     string
 	grpcPort    int
+	httpAddr    string
+	baseURL     string
 	environment string
 )
 
@@ -27,9 +38,22 @@ var (
 	grpcServer *grpc.Server
 )
 
+// keyMaintenanceInterval is the interval the signing keys are activated, rotated and retired at.
+const keyMaintenanceInterval = time.Minute
+
 func main() {
 	viper.SetDefault("DatabaseUrl", "mongodb://localhost:27017/iam_db")
 	viper.SetDefault("GrpcServerPort", 3000)
+	viper.SetDefault("HttpServerAddress", ":8080")
+	viper.SetDefault("BaseUrl", "http://localhost:8080")
+	viper.SetDefault("AccessTokenTTL", time.Hour)
+	viper.SetDefault("SessionTTL", 8*time.Hour)
+	viper.SetDefault("SigningAlgorithm", string(iam.RS256))
+	viper.SetDefault("SigningKeyRotationInterval", 30*24*time.Hour)
+	viper.SetDefault("SigningKeyPropagationDelay", time.Hour)
+	viper.SetDefault("SigningKeyRetirementDelay", 24*time.Hour)
+	viper.SetDefault("BreachedPasswordsFilter", "")
+	viper.SetDefault("BreachedPasswordsDir", "")
 	viper.SetDefault("Environment", "dev")
 
 	viper.SetConfigName("config")
@@ -45,4 +69,198 @@ func main() {
 	viper.SetEnvPrefix("iamd")
 	viper.AutomaticEnv()
 
+	mongoDB = viper.GetString("DatabaseUrl")
+	grpcPort = viper.GetInt("GrpcServerPort")
+	httpAddr = viper.GetString("HttpServerAddress")
+	baseURL = viper.GetString("BaseUrl")
+	environment = viper.GetString("Environment")
+
+	client := mongo.NewClient(mongoDB)
+	if err := client.Open(); err != nil {
+		log.Fatal(err)
+	}
+	defer client.Close()
+
+	breachedPasswords, err := breachedPasswordChecker(
+		viper.GetString("BreachedPasswordsFilter"),
+		viper.GetString("BreachedPasswordsDir"),
+	)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	app := newApplication(client, &eventLogger{}, config{
+		BaseURL:                    baseURL,
+		BreachedPasswords:          breachedPasswords,
+		AccessTokenTTL:             viper.GetDuration("AccessTokenTTL"),
+		SessionTTL:                 viper.GetDuration("SessionTTL"),
+		SigningKeyPropagationDelay: viper.GetDuration("SigningKeyPropagationDelay"),
+		SigningKeyRetirementDelay:  viper.GetDuration("SigningKeyRetirementDelay"),
+	})
+
+	algorithm := iam.SigningAlgorithm(viper.GetString("SigningAlgorithm"))
+	rotation := viper.GetDuration("SigningKeyRotationInterval")
+	maintainSigningKeys(app.signingKeys, algorithm, rotation)
+	go func() {
+		for range time.Tick(keyMaintenanceInterval) {
+			maintainSigningKeys(app.signingKeys, algorithm, rotation)
+		}
+	}()
+
+	handler := http.NewHandler(baseURL, app.authentication, app.oauth, app.signingKeys)
+	log.WithFields(log.Fields{
+		"version":     Version,
+		"environment": environment,
+		"address":     httpAddr,
+	}).Info("Starting OAuth 2.0 authorization server")
+	log.Fatal(nethttp.ListenAndServe(httpAddr, handler))
+}
+
+// repositories are the repositories backing the services of the application.
+type repositories interface {
+	TenantRepository() iam.TenantRepository
+	UserRepository() iam.UserRepository
+	GroupRepository() iam.GroupRepository
+	RoleRepository() iam.RoleRepository
+	ServiceAccountRepository() iam.ServiceAccountRepository
+	KeyStore() iam.KeyStore
+	SessionRepository() iam.SessionRepository
+	ClientRepository() iam.ClientRepository
+	AuthorizationCodeRepository() iam.AuthorizationCodeRepository
+}
+
+// config is the configuration of the services of the application.
+type config struct {
+	BaseURL                    string
+	BreachedPasswords          iam.BreachedPasswordChecker
+	AccessTokenTTL             time.Duration
+	SessionTTL                 time.Duration
+	SigningKeyPropagationDelay time.Duration
+	SigningKeyRetirementDelay  time.Duration
+}
+
+// application holds the services of the authorization server.
+type application struct {
+	events         *iam.EventDispatcher
+	signingKeys    iam.SigningKeyService
+	authentication iam.AuthenticationService
+	sessions       iam.SessionService
+	oauth          iam.OAuthService
+	provisioning   iam.TenantProvisioningService
+}
+
+// newApplication will wire the services of the authorization server. Services publish their events through a
+// dispatcher wrapping supplied publisher, so that the session service revokes the sessions of disabled users and
+// deactivated tenants. Services setting passwords reject the ones found by the configured breached password checker.
+func newApplication(r repositories, publisher iam.EventPublisher, c config) *application {
+	events := iam.NewEventDispatcher(publisher)
+	signingKeys := iam.NewSigningKeyService(
+		r.KeyStore(),
+		events,
+		c.SigningKeyPropagationDelay,
+		c.SigningKeyRetirementDelay,
+	)
+	authorization := iam.NewAuthorizationService(
+		r.UserRepository(),
+		r.ServiceAccountRepository(),
+		r.GroupRepository(),
+		r.RoleRepository(),
+	)
+	tokens := jwt.NewTokenService(signingKeys, authorization, c.BaseURL, c.AccessTokenTTL)
+	sessions := iam.NewSessionService(
+		r.TenantRepository(),
+		r.UserRepository(),
+		r.SessionRepository(),
+		events,
+		c.SessionTTL,
+	)
+	events.Subscribe(sessions)
+	return &application{
+		events:         events,
+		signingKeys:    signingKeys,
+		authentication: iam.NewAuthenticationService(r.TenantRepository(), r.UserRepository(), events),
+		sessions:       sessions,
+		oauth: iam.NewOAuthService(
+			r.TenantRepository(),
+			r.UserRepository(),
+			r.ServiceAccountRepository(),
+			r.ClientRepository(),
+			r.AuthorizationCodeRepository(),
+			sessions,
+			tokens,
+			events,
+		),
+		provisioning: iam.NewTenantProvisioningService(
+			r.TenantRepository(),
+			r.UserRepository(),
+			r.RoleRepository(),
+			c.BreachedPasswords,
+			events,
+		),
+	}
+}
+
+// breachedPasswordChecker will load the bloom filter of breached passwords stored in filter or, when missing,
+// check the range files stored in dir. No checker is returned when neither is configured.
+func breachedPasswordChecker(filter, dir string) (iam.BreachedPasswordChecker, error) {
+	switch {
+	case filter != "":
+		f, err := os.Open(filter)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		bloomFilter, err := iam.ReadBloomFilter(f)
+		if err != nil {
+			return nil, err
+		}
+		return bloomFilter, nil
+	case dir != "":
+		return iam.NewRangeFileChecker(dir), nil
+	default:
+		return nil, nil
+	}
+}
+
+// maintainSigningKeys will activate the pending signing keys whose propagation delay elapsed and retire the
+// retiring ones, rotating the active key once older than the rotation interval. A key is created when none is
+// active, so that tokens are signed from the first start.
+func maintainSigningKeys(service iam.SigningKeyService, algorithm iam.SigningAlgorithm, rotation time.Duration) {
+	if err := service.ActivateSigningKeys(); err != nil {
+		log.WithError(err).Error("Error occurred while activating signing keys")
+	}
+	if err := service.RetireSigningKeys(); err != nil {
+		log.WithError(err).Error("Error occurred while retiring signing keys")
+	}
+	keys, err := service.PublishedSigningKeys()
+	if err != nil {
+		log.WithError(err).Error("Error occurred while loading signing keys")
+		return
+	}
+	var active *iam.SigningKey
+	for _, k := range keys {
+		switch {
+		case k.State == iam.PendingSigningKey:
+			return
+		case k.State == iam.ActiveSigningKey && (active == nil || k.CreatedAt.After(active.CreatedAt)):
+			active = k
+		}
+	}
+	if active != nil && time.Since(active.ActivatedAt) < rotation {
+		return
+	}
+	if _, err := service.RotateSigningKey(algorithm); err != nil {
+		log.WithError(err).Error("Error occurred while rotating signing key")
+	}
+}
+
+// eventLogger is the event publisher logging the published domain events.
+type eventLogger struct{}
+
+// Publish will log supplied events.
+func (eventLogger) Publish(events iam.Events) error {
+	for _, e := range events {
+		log.WithFields(log.Fields{"type": e.Type, "timestamp": e.Timestamp}).Info("Event published")
+	}
+	return nil
 }
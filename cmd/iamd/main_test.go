@@ -0,0 +1,134 @@
+package main
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/maurofran/iam"
+	"github.com/maurofran/iam/memory"
+	"github.com/maurofran/iam/mock"
+)
+
+// mockRepositories are the repositories of the wiring tests.
+type mockRepositories struct {
+	tr *mock.TenantRepository
+	ur *mock.UserRepository
+	sr *mock.SessionRepository
+	ks *memory.KeyStore
+}
+
+func (r *mockRepositories) TenantRepository() iam.TenantRepository { return r.tr }
+func (r *mockRepositories) UserRepository() iam.UserRepository     { return r.ur }
+func (r *mockRepositories) GroupRepository() iam.GroupRepository   { return &mock.GroupRepository{} }
+func (r *mockRepositories) RoleRepository() iam.RoleRepository     { return &mock.RoleRepository{} }
+func (r *mockRepositories) ServiceAccountRepository() iam.ServiceAccountRepository {
+	return &mock.ServiceAccountRepository{}
+}
+func (r *mockRepositories) KeyStore() iam.KeyStore                   { return r.ks }
+func (r *mockRepositories) SessionRepository() iam.SessionRepository { return r.sr }
+func (r *mockRepositories) ClientRepository() iam.ClientRepository   { return &mock.ClientRepository{} }
+func (r *mockRepositories) AuthorizationCodeRepository() iam.AuthorizationCodeRepository {
+	return &mock.AuthorizationCodeRepository{}
+}
+
+var _ = Describe("Application", func() {
+	var (
+		tenant    *iam.Tenant
+		user      *iam.User
+		sessions  map[string]*iam.Session
+		published iam.Events
+		app       *application
+	)
+
+	BeforeEach(func() {
+		tenant = &iam.Tenant{ID: "tenant", Active: true}
+		user = &iam.User{TenantID: tenant.ID, Username: "jdoe", Enablement: iam.IndefiniteEnablement()}
+		sessions = map[string]*iam.Session{}
+		published = nil
+		active := func(match func(*iam.Session) bool) (iam.Sessions, error) {
+			var ss iam.Sessions
+			for _, s := range sessions {
+				if s.IsActive(time.Now()) && match(s) {
+					ss = append(ss, s)
+				}
+			}
+			return ss, nil
+		}
+		r := &mockRepositories{
+			tr: &mock.TenantRepository{
+				TenantOfIDFn:  func(iam.TenantID) (*iam.Tenant, error) { return tenant, nil },
+				TenantNamedFn: func(string) (*iam.Tenant, error) { return nil, nil },
+			},
+			ur: &mock.UserRepository{
+				UserWithUsernameFn: func(iam.TenantID, string) (*iam.User, error) { return user, nil },
+			},
+			sr: &mock.SessionRepository{
+				AddFn: func(s *iam.Session) error {
+					sessions[s.ID] = s
+					return nil
+				},
+				UpdateFn: func(*iam.Session) error { return nil },
+				ActiveSessionsOfUserFn: func(tenantID iam.TenantID, username string) (iam.Sessions, error) {
+					return active(func(s *iam.Session) bool { return s.TenantID == tenantID && s.Username == username })
+				},
+				ActiveSessionsOfTenantFn: func(tenantID iam.TenantID) (iam.Sessions, error) {
+					return active(func(s *iam.Session) bool { return s.TenantID == tenantID })
+				},
+			},
+			ks: memory.NewKeyStore(),
+		}
+		ep := &mock.EventPublisher{
+			PublishFn: func(ee iam.Events) error {
+				published = append(published, ee...)
+				return nil
+			},
+		}
+		app = newApplication(r, ep, config{
+			BaseURL:        "https://iam.example.com",
+			AccessTokenTTL: time.Minute,
+			SessionTTL:     time.Hour,
+			BreachedPasswords: &mock.BreachedPasswordChecker{
+				IsBreachedFn: func(string) (bool, error) { return true, nil },
+			},
+		})
+	})
+
+	start := func() *iam.Session {
+		session, _, err := app.sessions.StartSession(&iam.Authentication{Status: iam.Authenticated, User: user}, iam.ClientMetadata{})
+		Expect(err).NotTo(HaveOccurred())
+		return session
+	}
+
+	It("should publish the events of the services through the dispatcher", func() {
+		start()
+		Expect(published).To(HaveLen(1))
+		Expect(published[0].Type).To(Equal("SessionStarted"))
+	})
+
+	It("should reject breached passwords when provisioning tenants", func() {
+		_, _, err := app.provisioning.ProvisionTenant(
+			"Acme",
+			"Acme Inc.",
+			iam.FullName{FirstName: "John", LastName: "Doe"},
+			"jdoe@example.com",
+			iam.PostalAddress{},
+			"",
+			"",
+		)
+		Expect(iam.ErrorCode(err)).To(Equal(iam.EINVALID))
+	})
+
+	It("should revoke the sessions of disabled users", func() {
+		session := start()
+		Expect(app.events.Publish(user.DefineEnablement(iam.Enablement{Enabled: false}))).To(Succeed())
+		Expect(session.RevocationReason).To(Equal(iam.SessionRevokedOnUserDisabled))
+	})
+
+	It("should revoke the sessions of deactivated tenants", func() {
+		session := start()
+		Expect(app.events.Publish(tenant.Deactivate())).To(Succeed())
+		Expect(session.RevocationReason).To(Equal(iam.SessionRevokedOnTenantDeactivated))
+	})
+})
@@ -9,11 +9,13 @@ import (
 // EINTERNAL is the error code for internal errors.
 // EINVALID is the error code for invalid data.
 // ENOTFOUND is the error code for a not found object.
+// EUNAUTHORIZED is the error code for rejected credentials.
 const (
-	ECONFLICT = "conflict"
-	EINTERNAL = "internal"
-	EINVALID  = "invalid"
-	ENOTFOUND = "notfound"
+	ECONFLICT     = "conflict"
+	EINTERNAL     = "internal"
+	EINVALID      = "invalid"
+	ENOTFOUND     = "notfound"
+	EUNAUTHORIZED = "unauthorized"
 )
 
 // Error represents all the IAM related error codes.
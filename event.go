@@ -16,9 +16,17 @@ type Event struct {
 	Payload   interface{}
 }
 
+// EventPublisher is the interface used to publish domain events.
+type EventPublisher interface {
+	Publish(Events) error
+}
+
 // EventWithPayload will return a new event with given payload.
 func EventWithPayload(payload interface{}) *Event {
 	t := reflect.TypeOf(payload)
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
 	return &Event{
 		Version:   1,
 		Timestamp: time.Now(),
@@ -26,3 +34,41 @@ func EventWithPayload(payload interface{}) *Event {
 		Payload:   payload,
 	}
 }
+
+// EventHandler is the interface for reactions to published domain events.
+type EventHandler interface {
+	HandleEvent(*Event) error
+}
+
+// EventDispatcher is the event publisher handing published events to the subscribed handlers,
+// once the wrapped publisher published them. Handlers are meant to be subscribed while wiring the application,
+// before any event is published.
+type EventDispatcher struct {
+	publisher EventPublisher
+	handlers  []EventHandler
+}
+
+// NewEventDispatcher will create a new event dispatcher wrapping supplied publisher.
+func NewEventDispatcher(publisher EventPublisher) *EventDispatcher {
+	return &EventDispatcher{publisher: publisher}
+}
+
+// Subscribe will add supplied handler to the ones receiving published events.
+func (d *EventDispatcher) Subscribe(handler EventHandler) {
+	d.handlers = append(d.handlers, handler)
+}
+
+// Publish will publish the events, then hand each of them to the handlers in subscription order.
+func (d *EventDispatcher) Publish(events Events) error {
+	if err := d.publisher.Publish(events); err != nil {
+		return err
+	}
+	for _, e := range events {
+		for _, h := range d.handlers {
+			if err := h.HandleEvent(e); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
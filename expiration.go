@@ -0,0 +1,125 @@
+package iam
+
+import "time"
+
+// PasswordExpirationService is the service flagging users whose password is older than the tenant maximum age.
+type PasswordExpirationService interface {
+	ExpirePasswords(tenantID TenantID) error
+	ExpireAllPasswords() error
+}
+
+// NewPasswordExpirationService will create a new password expiration service backed by supplied repositories.
+func NewPasswordExpirationService(
+	tenantRepository TenantRepository,
+	userRepository UserRepository,
+	eventPublisher EventPublisher,
+) PasswordExpirationService {
+	return &passwordExpirationService{
+		tenantRepository: tenantRepository,
+		userRepository:   userRepository,
+		eventPublisher:   eventPublisher,
+	}
+}
+
+type passwordExpirationService struct {
+	tenantRepository TenantRepository
+	userRepository   UserRepository
+	eventPublisher   EventPublisher
+}
+
+// ExpirePasswords will force a password change to the users of the tenant with an expired password.
+func (s *passwordExpirationService) ExpirePasswords(tenantID TenantID) error {
+	const op = "ExpirePasswords"
+	tenant, err := s.tenantRepository.TenantOfID(tenantID)
+	if err != nil {
+		return &Error{
+			Code:    EINTERNAL,
+			Message: "An unexpected error occurred while retrieving tenant.",
+			Op:      op,
+			Err:     err,
+		}
+	}
+	if tenant == nil {
+		return &Error{Code: ENOTFOUND, Message: "Tenant not found.", Op: op}
+	}
+	return s.expire(op, tenant)
+}
+
+// ExpireAllPasswords will force a password change to the users of every active tenant with an expired password.
+func (s *passwordExpirationService) ExpireAllPasswords() error {
+	const op = "ExpireAllPasswords"
+	tenants, err := s.tenantRepository.AllTenants()
+	if err != nil {
+		return &Error{
+			Code:    EINTERNAL,
+			Message: "An unexpected error occurred while retrieving tenants.",
+			Op:      op,
+			Err:     err,
+		}
+	}
+	for _, t := range tenants {
+		if err := s.expire(op, t); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// expire will flag the users of the tenant with an expired password. Users are flagged one by one by the
+// repository, only while their password is still expired, so that concurrent changes are never overwritten.
+func (s *passwordExpirationService) expire(op string, tenant *Tenant) error {
+	maxAge := tenant.PasswordPolicy.MaxAge
+	if !tenant.Active || maxAge <= 0 {
+		return nil
+	}
+	changedBefore := time.Now().Add(-maxAge)
+	users, err := s.userRepository.AllUsersWithPasswordChangedBefore(tenant.ID, changedBefore)
+	if err != nil {
+		return &Error{
+			Code:    EINTERNAL,
+			Message: "An unexpected error occurred while retrieving users.",
+			Op:      op,
+			Err:     err,
+		}
+	}
+	var events Events
+	for _, u := range users {
+		expired := u.ExpirePassword(maxAge)
+		if len(expired) == 0 {
+			continue
+		}
+		ok, err := s.userRepository.ExpirePassword(tenant.ID, u.Username, changedBefore)
+		if err != nil {
+			return &Error{
+				Code:    EINTERNAL,
+				Message: "An unexpected error occurred while expiring user password.",
+				Op:      op,
+				Err:     err,
+			}
+		}
+		if ok {
+			events = append(events, expired...)
+		}
+	}
+	if len(events) == 0 {
+		return nil
+	}
+	return s.eventPublisher.Publish(events)
+}
+
+// SweepExpiredPasswords will expire the passwords of every tenant each interval, until done is closed.
+// Sweep failures are reported to onError, when supplied.
+func SweepExpiredPasswords(service PasswordExpirationService, interval time.Duration, done <-chan struct{}, onError func(error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			if err := service.ExpireAllPasswords(); err != nil && onError != nil {
+				onError(err)
+			}
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package iam_test
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/maurofran/iam"
+	"github.com/maurofran/iam/mock"
+)
+
+var _ = Describe("Password expiration service", func() {
+	var (
+		tenant  *Tenant
+		users   Users
+		expired map[string]bool
+		ur      *mock.UserRepository
+		events  Events
+		service PasswordExpirationService
+	)
+
+	BeforeEach(func() {
+		tenant = &Tenant{ID: "tenant", Active: true, PasswordPolicy: PasswordPolicy{MaxAge: 24 * time.Hour}}
+		users = Users{
+			{TenantID: tenant.ID, Username: "jdoe", PasswordChangedAt: time.Now().Add(-48 * time.Hour)},
+			{TenantID: tenant.ID, Username: "jroe", PasswordChangedAt: time.Now().Add(-48 * time.Hour)},
+		}
+		expired = map[string]bool{"jdoe": true}
+		events = nil
+		tr := &mock.TenantRepository{
+			TenantOfIDFn: func(TenantID) (*Tenant, error) { return tenant, nil },
+		}
+		ur = &mock.UserRepository{
+			AllUsersWithPasswordChangedBeforeFn: func(TenantID, time.Time) (Users, error) { return users, nil },
+			ExpirePasswordFn: func(_ TenantID, username string, changedBefore time.Time) (bool, error) {
+				Expect(changedBefore).To(BeTemporally("~", time.Now().Add(-24*time.Hour), time.Second))
+				return expired[username], nil
+			},
+		}
+		ep := &mock.EventPublisher{
+			PublishFn: func(ee Events) error {
+				events = append(events, ee...)
+				return nil
+			},
+		}
+		service = NewPasswordExpirationService(tr, ur, ep)
+	})
+
+	It("should expire the passwords without overwriting the users", func() {
+		Expect(service.ExpirePasswords(tenant.ID)).To(Succeed())
+		Expect(ur.ExpirePasswordInvoked).To(BeTrue())
+		Expect(ur.UpdateInvoked).To(BeFalse())
+		Expect(events).To(HaveLen(1))
+		Expect(events[0].Payload.(*UserPasswordExpired).Username).To(Equal("jdoe"))
+	})
+})
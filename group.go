@@ -8,6 +8,142 @@ type Group struct {
 	Members     GroupMembers `bson:"members"`
 }
 
+// AddUser will add supplied user to the group members.
+func (g *Group) AddUser(user *User) (Events, error) {
+	const op = "AddUser"
+	if user.TenantID != g.TenantID {
+		return nil, &Error{Code: EINVALID, Message: "Wrong tenant for this group.", Op: op}
+	}
+	if !user.IsEnabled() {
+		return nil, &Error{Code: EINVALID, Message: "User is not enabled.", Op: op}
+	}
+	if g.Members.contains(UserGroupMember, user.Username) {
+		return nil, nil
+	}
+	g.Members = append(g.Members, &GroupMember{Type: UserGroupMember, Name: user.Username})
+	return Events{EventWithPayload(&GroupUserAdded{
+		TenantID:  g.TenantID,
+		GroupName: g.Name,
+		Username:  user.Username,
+	})}, nil
+}
+
+// AddServiceAccount will add supplied service account to the group members.
+func (g *Group) AddServiceAccount(account *ServiceAccount) (Events, error) {
+	const op = "AddServiceAccount"
+	if account.TenantID != g.TenantID {
+		return nil, &Error{Code: EINVALID, Message: "Wrong tenant for this group.", Op: op}
+	}
+	if !account.IsEnabled() {
+		return nil, &Error{Code: EINVALID, Message: "Service account is not enabled.", Op: op}
+	}
+	if g.Members.contains(ServiceAccountGroupMember, account.Name) {
+		return nil, nil
+	}
+	g.Members = append(g.Members, account.toGroupMember())
+	return Events{EventWithPayload(&GroupServiceAccountAdded{
+		TenantID:           g.TenantID,
+		GroupName:          g.Name,
+		ServiceAccountName: account.Name,
+	})}, nil
+}
+
+// AddGroup will add supplied group to the group members, refusing to create a nesting cycle.
+func (g *Group) AddGroup(group *Group, groupMemberService GroupMemberService) (Events, error) {
+	const op = "AddGroup"
+	if group.TenantID != g.TenantID {
+		return nil, &Error{Code: EINVALID, Message: "Wrong tenant for this group.", Op: op}
+	}
+	if group.Name == g.Name {
+		return nil, &Error{Code: ECONFLICT, Message: "Group cannot be a member of itself.", Op: op}
+	}
+	nested, err := groupMemberService.IsMemberGroup(group, g.toGroupMember())
+	if err != nil {
+		return nil, err
+	}
+	if nested {
+		return nil, &Error{Code: ECONFLICT, Message: "Group recursion is not allowed.", Op: op}
+	}
+	if g.Members.contains(GroupGroupMember, group.Name) {
+		return nil, nil
+	}
+	g.Members = append(g.Members, group.toGroupMember())
+	return Events{EventWithPayload(&GroupGroupAdded{
+		TenantID:        g.TenantID,
+		GroupName:       g.Name,
+		NestedGroupName: group.Name,
+	})}, nil
+}
+
+// RemoveUser will remove supplied user from the group members.
+func (g *Group) RemoveUser(user *User) (Events, error) {
+	if user.TenantID != g.TenantID {
+		return nil, &Error{Code: EINVALID, Message: "Wrong tenant for this group.", Op: "RemoveUser"}
+	}
+	if !g.Members.remove(UserGroupMember, user.Username) {
+		return nil, nil
+	}
+	return Events{EventWithPayload(&GroupUserRemoved{
+		TenantID:  g.TenantID,
+		GroupName: g.Name,
+		Username:  user.Username,
+	})}, nil
+}
+
+// RemoveServiceAccount will remove supplied service account from the group members.
+func (g *Group) RemoveServiceAccount(account *ServiceAccount) (Events, error) {
+	if account.TenantID != g.TenantID {
+		return nil, &Error{Code: EINVALID, Message: "Wrong tenant for this group.", Op: "RemoveServiceAccount"}
+	}
+	if !g.Members.remove(ServiceAccountGroupMember, account.Name) {
+		return nil, nil
+	}
+	return Events{EventWithPayload(&GroupServiceAccountRemoved{
+		TenantID:           g.TenantID,
+		GroupName:          g.Name,
+		ServiceAccountName: account.Name,
+	})}, nil
+}
+
+// RemoveGroup will remove supplied group from the group members.
+func (g *Group) RemoveGroup(group *Group) (Events, error) {
+	if group.TenantID != g.TenantID {
+		return nil, &Error{Code: EINVALID, Message: "Wrong tenant for this group.", Op: "RemoveGroup"}
+	}
+	if !g.Members.remove(GroupGroupMember, group.Name) {
+		return nil, nil
+	}
+	return Events{EventWithPayload(&GroupGroupRemoved{
+		TenantID:        g.TenantID,
+		GroupName:       g.Name,
+		NestedGroupName: group.Name,
+	})}, nil
+}
+
+// IsMember will check if supplied user is member of the group, either directly or through nested groups.
+func (g *Group) IsMember(user *User, groupMemberService GroupMemberService) (bool, error) {
+	if user.TenantID != g.TenantID || !user.IsEnabled() {
+		return false, nil
+	}
+	if g.Members.contains(UserGroupMember, user.Username) {
+		return true, nil
+	}
+	return groupMemberService.IsUserInNestedGroup(g, user)
+}
+
+// IsServiceAccountMember will check if supplied service account is member of the group, either directly
+// or through nested groups.
+func (g *Group) IsServiceAccountMember(account *ServiceAccount, groupMemberService GroupMemberService) (bool, error) {
+	if account.TenantID != g.TenantID || !account.IsEnabled() {
+		return false, nil
+	}
+	return groupMemberService.IsMemberGroup(g, account.toGroupMember())
+}
+
+func (g *Group) toGroupMember() *GroupMember {
+	return &GroupMember{Type: GroupGroupMember, Name: g.Name}
+}
+
 // Groups is a collection of group.
 type Groups []*Group
 
@@ -20,8 +156,18 @@ type GroupRepository interface {
 	AllGroups(TenantID) (Groups, error)
 }
 
-// GroupMemberType is an enum type for group member.
-type GroupMemberType int
+// GroupMemberType is an enum type for group member, stored by name. Groups stored by previous versions with integer
+// member types are migrated by the repository.
+type GroupMemberType string
+
+// UserGroupMember is the type for members that are users.
+// GroupGroupMember is the type for members that are nested groups.
+// ServiceAccountGroupMember is the type for members that are service accounts.
+const (
+	UserGroupMember           GroupMemberType = "user"
+	GroupGroupMember          GroupMemberType = "group"
+	ServiceAccountGroupMember GroupMemberType = "serviceAccount"
+)
 
 // GroupMember is the value object representing a group member.
 type GroupMember struct {
@@ -31,3 +177,123 @@ type GroupMember struct {
 
 // GroupMembers is the collection of group members
 type GroupMembers []*GroupMember
+
+func (gm GroupMembers) contains(t GroupMemberType, name string) bool {
+	for _, m := range gm {
+		if m.Type == t && m.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func (gm *GroupMembers) remove(t GroupMemberType, name string) bool {
+	for i, m := range *gm {
+		if m.Type == t && m.Name == name {
+			*gm = append((*gm)[:i], (*gm)[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// GroupMemberService is the domain service resolving members of nested groups.
+type GroupMemberService interface {
+	IsMemberGroup(group *Group, member *GroupMember) (bool, error)
+	IsUserInNestedGroup(group *Group, user *User) (bool, error)
+}
+
+// NewGroupMemberService will create a new group member service backed by supplied repository.
+func NewGroupMemberService(groupRepository GroupRepository) GroupMemberService {
+	return &groupMemberService{groupRepository: groupRepository}
+}
+
+type groupMemberService struct {
+	groupRepository GroupRepository
+}
+
+// IsMemberGroup will check if the member is contained, directly or through nested groups, into supplied group.
+func (s *groupMemberService) IsMemberGroup(group *Group, member *GroupMember) (bool, error) {
+	return s.walk("IsMemberGroup", group, map[string]bool{}, func(g *Group) bool {
+		return g.Members.contains(member.Type, member.Name)
+	})
+}
+
+// IsUserInNestedGroup will check if the user is member of any group nested into supplied group.
+func (s *groupMemberService) IsUserInNestedGroup(group *Group, user *User) (bool, error) {
+	return s.walk("IsUserInNestedGroup", group, map[string]bool{}, func(g *Group) bool {
+		return g != group && g.Members.contains(UserGroupMember, user.Username)
+	})
+}
+
+// walk will visit the group and the groups nested into it until match returns true.
+// Visited groups are tracked so that cyclic nesting is walked only once.
+func (s *groupMemberService) walk(op string, group *Group, visited map[string]bool, match func(*Group) bool) (bool, error) {
+	visited[group.Name] = true
+	if match(group) {
+		return true, nil
+	}
+	for _, m := range group.Members {
+		if m.Type != GroupGroupMember || visited[m.Name] {
+			continue
+		}
+		nested, err := s.groupRepository.GroupNamed(group.TenantID, m.Name)
+		if err != nil {
+			return false, &Error{
+				Code:    EINTERNAL,
+				Message: "An unexpected error occurred while retrieving group.",
+				Op:      op,
+				Err:     err,
+			}
+		}
+		if nested == nil {
+			continue
+		}
+		if found, err := s.walk(op, nested, visited, match); found || err != nil {
+			return found, err
+		}
+	}
+	return false, nil
+}
+
+// GroupUserAdded is the event raised when a user is added to a group.
+type GroupUserAdded struct {
+	TenantID  TenantID
+	GroupName string
+	Username  string
+}
+
+// GroupGroupAdded is the event raised when a group is nested into another group.
+type GroupGroupAdded struct {
+	TenantID        TenantID
+	GroupName       string
+	NestedGroupName string
+}
+
+// GroupUserRemoved is the event raised when a user is removed from a group.
+type GroupUserRemoved struct {
+	TenantID  TenantID
+	GroupName string
+	Username  string
+}
+
+// GroupServiceAccountAdded is the event raised when a service account is added to a group.
+type GroupServiceAccountAdded struct {
+	TenantID           TenantID
+	GroupName          string
+	ServiceAccountName string
+}
+
+// GroupServiceAccountRemoved is the event raised when a service account is removed from a group.
+type GroupServiceAccountRemoved struct {
+	TenantID           TenantID
+	GroupName          string
+	ServiceAccountName string
+}
+
+// GroupGroupRemoved is the event raised when a nested group is removed from a group.
+type GroupGroupRemoved struct {
+	TenantID        TenantID
+	GroupName       string
+	NestedGroupName string
+}
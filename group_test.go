@@ -0,0 +1,94 @@
+package iam_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/maurofran/iam"
+	"github.com/maurofran/iam/mock"
+)
+
+var _ = Describe("Group", func() {
+	var (
+		user    *User
+		admins  *Group
+		devs    *Group
+		ops     *Group
+		service GroupMemberService
+	)
+
+	BeforeEach(func() {
+		user = &User{TenantID: "tenant", Username: "jdoe", Enablement: IndefiniteEnablement()}
+		admins = &Group{TenantID: "tenant", Name: "admins"}
+		devs = &Group{TenantID: "tenant", Name: "devs"}
+		ops = &Group{TenantID: "tenant", Name: "ops"}
+		groups := map[string]*Group{"admins": admins, "devs": devs, "ops": ops}
+		service = NewGroupMemberService(&mock.GroupRepository{
+			GroupNamedFn: func(tenantID TenantID, name string) (*Group, error) {
+				return groups[name], nil
+			},
+		})
+	})
+
+	Describe("#AddUser", func() {
+		It("should add the user once", func() {
+			events, err := admins.AddUser(user)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(events).To(HaveLen(1))
+			Expect(events[0].Type).To(Equal("GroupUserAdded"))
+			events, err = admins.AddUser(user)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(events).To(BeEmpty())
+			Expect(admins.Members).To(HaveLen(1))
+		})
+		It("should refuse a user of another tenant", func() {
+			user.TenantID = "other"
+			_, err := admins.AddUser(user)
+			Expect(ErrorCode(err)).To(Equal(EINVALID))
+		})
+	})
+
+	Describe("#AddGroup", func() {
+		It("should nest the group", func() {
+			events, err := admins.AddGroup(devs, service)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(events).To(HaveLen(1))
+			Expect(events[0].Type).To(Equal("GroupGroupAdded"))
+		})
+		It("should refuse to nest the group into itself", func() {
+			_, err := admins.AddGroup(admins, service)
+			Expect(ErrorCode(err)).To(Equal(ECONFLICT))
+		})
+		It("should refuse a transitive cycle", func() {
+			_, err := admins.AddGroup(devs, service)
+			Expect(err).NotTo(HaveOccurred())
+			_, err = devs.AddGroup(ops, service)
+			Expect(err).NotTo(HaveOccurred())
+			_, err = ops.AddGroup(admins, service)
+			Expect(ErrorCode(err)).To(Equal(ECONFLICT))
+		})
+	})
+
+	Describe("#RemoveUser", func() {
+		It("should remove the user", func() {
+			_, err := admins.AddUser(user)
+			Expect(err).NotTo(HaveOccurred())
+			events, err := admins.RemoveUser(user)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(events).To(HaveLen(1))
+			Expect(events[0].Type).To(Equal("GroupUserRemoved"))
+			Expect(admins.Members).To(BeEmpty())
+		})
+	})
+
+	Describe("#IsMember", func() {
+		It("should resolve nested members", func() {
+			_, err := admins.AddGroup(devs, service)
+			Expect(err).NotTo(HaveOccurred())
+			_, err = devs.AddUser(user)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(admins.IsMember(user, service)).To(BeTrue())
+			Expect(ops.IsMember(user, service)).To(BeFalse())
+		})
+	})
+})
@@ -0,0 +1,293 @@
+package iam
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+	"golang.org/x/crypto/pbkdf2"
+	"golang.org/x/crypto/scrypt"
+)
+
+// PasswordHasher is the interface for algorithms hashing passwords in a self describing format.
+type PasswordHasher interface {
+	// Hash will hash supplied password with a random salt.
+	Hash(password string) (string, error)
+	// Verify will check if supplied password matches the encoded hash.
+	Verify(password, encoded string) bool
+	// NeedsRehash will check if the encoded hash was produced with a different algorithm or parameters.
+	NeedsRehash(encoded string) bool
+}
+
+// DefaultPasswordHasher is the hasher used to protect new passwords.
+var DefaultPasswordHasher PasswordHasher = NewArgon2idHasher()
+
+const (
+	argon2idID = "argon2id"
+	scryptID   = "scrypt"
+	pbkdf2ID   = "pbkdf2-sha256"
+	saltLength = 16
+	keyLength  = 32
+)
+
+var b64 = base64.RawStdEncoding
+
+// hasherFor will return the hasher able to verify the encoded hash, or nil if no one is.
+func hasherFor(encoded string) PasswordHasher {
+	switch hashID(encoded) {
+	case argon2idID:
+		return &Argon2idHasher{}
+	case scryptID:
+		return &ScryptHasher{}
+	case pbkdf2ID:
+		return &PBKDF2Hasher{}
+	case "2a", "2b", "2y":
+		return &BcryptHasher{}
+	case "":
+		if _, ok := legacyBcrypt(encoded); ok {
+			return &BcryptHasher{}
+		}
+	}
+	return nil
+}
+
+func hashID(encoded string) string {
+	if !strings.HasPrefix(encoded, "$") {
+		return ""
+	}
+	parts := strings.SplitN(encoded[1:], "$", 2)
+	return parts[0]
+}
+
+func salt() ([]byte, error) {
+	s := make([]byte, saltLength)
+	if _, err := rand.Read(s); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Argon2idHasher is the password hasher using argon2id algorithm.
+type Argon2idHasher struct {
+	Time    uint32
+	Memory  uint32
+	Threads uint8
+}
+
+// NewArgon2idHasher will create a new argon2id hasher with recommended parameters.
+func NewArgon2idHasher() *Argon2idHasher {
+	return &Argon2idHasher{Time: 2, Memory: 19 * 1024, Threads: 1}
+}
+
+// Hash will hash supplied password.
+func (h *Argon2idHasher) Hash(password string) (string, error) {
+	s, err := salt()
+	if err != nil {
+		return "", err
+	}
+	key := argon2.IDKey([]byte(password), s, h.Time, h.Memory, h.Threads, keyLength)
+	return fmt.Sprintf("$%s$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2idID, argon2.Version, h.Memory, h.Time, h.Threads, b64.EncodeToString(s), b64.EncodeToString(key)), nil
+}
+
+// Verify will verify supplied password against encoded hash.
+func (h *Argon2idHasher) Verify(password, encoded string) bool {
+	p, s, key, ok := h.decode(encoded)
+	if !ok {
+		return false
+	}
+	other := argon2.IDKey([]byte(password), s, p.Time, p.Memory, p.Threads, uint32(len(key)))
+	return subtle.ConstantTimeCompare(key, other) == 1
+}
+
+// NeedsRehash will check if encoded hash was produced with different parameters.
+func (h *Argon2idHasher) NeedsRehash(encoded string) bool {
+	p, _, _, ok := h.decode(encoded)
+	return !ok || p.Time != h.Time || p.Memory != h.Memory || p.Threads != h.Threads
+}
+
+func (h *Argon2idHasher) decode(encoded string) (p Argon2idHasher, s, key []byte, ok bool) {
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 || parts[1] != argon2idID {
+		return
+	}
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return
+	}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &p.Memory, &p.Time, &p.Threads); err != nil ||
+		p.Memory == 0 || p.Time == 0 || p.Threads == 0 {
+		return
+	}
+	s, key, ok = decodeSaltAndKey(parts[4], parts[5])
+	return
+}
+
+// ScryptHasher is the password hasher using scrypt algorithm.
+type ScryptHasher struct {
+	LogN uint8
+	R    int
+	P    int
+}
+
+// NewScryptHasher will create a new scrypt hasher with recommended parameters.
+func NewScryptHasher() *ScryptHasher {
+	return &ScryptHasher{LogN: 15, R: 8, P: 1}
+}
+
+// Hash will hash supplied password.
+func (h *ScryptHasher) Hash(password string) (string, error) {
+	s, err := salt()
+	if err != nil {
+		return "", err
+	}
+	key, err := scrypt.Key([]byte(password), s, 1<<h.LogN, h.R, h.P, keyLength)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("$%s$ln=%d,r=%d,p=%d$%s$%s",
+		scryptID, h.LogN, h.R, h.P, b64.EncodeToString(s), b64.EncodeToString(key)), nil
+}
+
+// Verify will verify supplied password against encoded hash.
+func (h *ScryptHasher) Verify(password, encoded string) bool {
+	p, s, key, ok := h.decode(encoded)
+	if !ok {
+		return false
+	}
+	other, err := scrypt.Key([]byte(password), s, 1<<p.LogN, p.R, p.P, len(key))
+	if err != nil {
+		return false
+	}
+	return subtle.ConstantTimeCompare(key, other) == 1
+}
+
+// NeedsRehash will check if encoded hash was produced with different parameters.
+func (h *ScryptHasher) NeedsRehash(encoded string) bool {
+	p, _, _, ok := h.decode(encoded)
+	return !ok || p.LogN != h.LogN || p.R != h.R || p.P != h.P
+}
+
+func (h *ScryptHasher) decode(encoded string) (p ScryptHasher, s, key []byte, ok bool) {
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 5 || parts[1] != scryptID {
+		return
+	}
+	if _, err := fmt.Sscanf(parts[2], "ln=%d,r=%d,p=%d", &p.LogN, &p.R, &p.P); err != nil {
+		return
+	}
+	s, key, ok = decodeSaltAndKey(parts[3], parts[4])
+	return
+}
+
+// PBKDF2Hasher is the password hasher using PBKDF2 algorithm with SHA-256.
+type PBKDF2Hasher struct {
+	Iterations int
+}
+
+// NewPBKDF2Hasher will create a new PBKDF2 hasher with recommended parameters.
+func NewPBKDF2Hasher() *PBKDF2Hasher {
+	return &PBKDF2Hasher{Iterations: 600000}
+}
+
+// Hash will hash supplied password.
+func (h *PBKDF2Hasher) Hash(password string) (string, error) {
+	s, err := salt()
+	if err != nil {
+		return "", err
+	}
+	key := pbkdf2.Key([]byte(password), s, h.Iterations, keyLength, sha256.New)
+	return fmt.Sprintf("$%s$i=%d$%s$%s",
+		pbkdf2ID, h.Iterations, b64.EncodeToString(s), b64.EncodeToString(key)), nil
+}
+
+// Verify will verify supplied password against encoded hash.
+func (h *PBKDF2Hasher) Verify(password, encoded string) bool {
+	p, s, key, ok := h.decode(encoded)
+	if !ok {
+		return false
+	}
+	other := pbkdf2.Key([]byte(password), s, p.Iterations, len(key), sha256.New)
+	return subtle.ConstantTimeCompare(key, other) == 1
+}
+
+// NeedsRehash will check if encoded hash was produced with different parameters.
+func (h *PBKDF2Hasher) NeedsRehash(encoded string) bool {
+	p, _, _, ok := h.decode(encoded)
+	return !ok || p.Iterations != h.Iterations
+}
+
+func (h *PBKDF2Hasher) decode(encoded string) (p PBKDF2Hasher, s, key []byte, ok bool) {
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 5 || parts[1] != pbkdf2ID {
+		return
+	}
+	if _, err := fmt.Sscanf(parts[2], "i=%d", &p.Iterations); err != nil || p.Iterations <= 0 {
+		return
+	}
+	s, key, ok = decodeSaltAndKey(parts[3], parts[4])
+	return
+}
+
+// BcryptHasher is the password hasher using bcrypt algorithm.
+type BcryptHasher struct {
+	Cost int
+}
+
+// NewBcryptHasher will create a new bcrypt hasher with default cost.
+func NewBcryptHasher() *BcryptHasher {
+	return &BcryptHasher{Cost: bcrypt.DefaultCost}
+}
+
+// Hash will hash supplied password.
+func (h *BcryptHasher) Hash(password string) (string, error) {
+	enc, err := bcrypt.GenerateFromPassword([]byte(password), h.Cost)
+	if err != nil {
+		return "", err
+	}
+	return string(enc), nil
+}
+
+// Verify will verify supplied password against encoded hash, either in modular crypt or legacy base64 format.
+func (h *BcryptHasher) Verify(password, encoded string) bool {
+	enc, ok := legacyBcrypt(encoded)
+	if !ok {
+		enc = []byte(encoded)
+	}
+	return bcrypt.CompareHashAndPassword(enc, []byte(password)) == nil
+}
+
+// NeedsRehash will check if encoded hash is in legacy format or was produced with a different cost.
+func (h *BcryptHasher) NeedsRehash(encoded string) bool {
+	if _, ok := legacyBcrypt(encoded); ok {
+		return true
+	}
+	cost, err := bcrypt.Cost([]byte(encoded))
+	return err != nil || cost != h.Cost
+}
+
+// legacyBcrypt will decode the base64 wrapped bcrypt hashes stored by previous versions.
+func legacyBcrypt(encoded string) ([]byte, bool) {
+	enc, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil || !strings.HasPrefix(string(enc), "$2") {
+		return nil, false
+	}
+	return enc, true
+}
+
+func decodeSaltAndKey(encodedSalt, encodedKey string) ([]byte, []byte, bool) {
+	s, err := b64.DecodeString(encodedSalt)
+	if err != nil {
+		return nil, nil, false
+	}
+	key, err := b64.DecodeString(encodedKey)
+	if err != nil || len(key) == 0 {
+		return nil, nil, false
+	}
+	return s, key, true
+}
@@ -0,0 +1,57 @@
+package iam_test
+
+import (
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+
+	. "github.com/maurofran/iam"
+)
+
+var _ = Describe("Password hashers", func() {
+	DescribeTable("#Hash and #Verify",
+		func(h PasswordHasher, prefix string) {
+			enc, err := h.Hash("gV7#pLq2!wZx")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(enc).To(HavePrefix(prefix))
+			Expect(h.Verify("gV7#pLq2!wZx", enc)).To(BeTrue())
+			Expect(h.Verify("wrong", enc)).To(BeFalse())
+			Expect(h.NeedsRehash(enc)).To(BeFalse())
+			other, err := h.Hash("gV7#pLq2!wZx")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(other).NotTo(Equal(enc))
+		},
+		Entry("argon2id", &Argon2idHasher{Time: 1, Memory: 1024, Threads: 1}, "$argon2id$v=19$m=1024,t=1,p=1$"),
+		Entry("scrypt", &ScryptHasher{LogN: 10, R: 8, P: 1}, "$scrypt$ln=10,r=8,p=1$"),
+		Entry("pbkdf2", &PBKDF2Hasher{Iterations: 1000}, "$pbkdf2-sha256$i=1000$"),
+		Entry("bcrypt", &BcryptHasher{Cost: 4}, "$2a$04$"),
+	)
+
+	DescribeTable("argon2id should refuse hashes with null parameters",
+		func(parameters string) {
+			enc, err := (&Argon2idHasher{Time: 1, Memory: 1024, Threads: 1}).Hash("gV7#pLq2!wZx")
+			Expect(err).NotTo(HaveOccurred())
+			enc = strings.Replace(enc, "m=1024,t=1,p=1", parameters, 1)
+			Expect(NewArgon2idHasher().Verify("gV7#pLq2!wZx", enc)).To(BeFalse())
+			Expect(NewArgon2idHasher().NeedsRehash(enc)).To(BeTrue())
+		},
+		Entry("memory", "m=0,t=1,p=1"),
+		Entry("time", "m=1024,t=0,p=1"),
+		Entry("threads", "m=1024,t=1,p=0"),
+	)
+
+	Describe("#NeedsRehash", func() {
+		It("should require rehash when parameters change", func() {
+			enc, err := (&PBKDF2Hasher{Iterations: 1000}).Hash("gV7#pLq2!wZx")
+			Expect(err).NotTo(HaveOccurred())
+			Expect((&PBKDF2Hasher{Iterations: 2000}).NeedsRehash(enc)).To(BeTrue())
+		})
+		It("should require rehash when algorithm changes", func() {
+			enc, err := (&BcryptHasher{Cost: 4}).Hash("gV7#pLq2!wZx")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(NewArgon2idHasher().NeedsRehash(enc)).To(BeTrue())
+		})
+	})
+})
@@ -0,0 +1,239 @@
+package http
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"html/template"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/maurofran/iam"
+)
+
+// csrfCookie is the name of the cookie holding the secret the CSRF tokens of the login form are derived from.
+const csrfCookie = "iam_csrf"
+
+// loginTemplate is the form authenticating the user of an authorization request, asking the second factor
+// when a challenge is pending. Parameters of the request and the CSRF token are carried along as hidden fields.
+var loginTemplate = template.Must(template.New("login").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>Sign in to {{.Client}}</title></head>
+<body>
+<h1>Sign in to {{.Client}}</h1>
+{{if .Error}}<p role="alert">{{.Error}}</p>
+{{end}}<form method="post" action="{{.Action}}">
+{{range $name, $value := .Params}}<input type="hidden" name="{{$name}}" value="{{$value}}">
+{{end}}<input type="hidden" name="csrf_token" value="{{.CSRFToken}}">
+{{if .Challenge}}<input type="hidden" name="username" value="{{.Username}}">
+<input type="hidden" name="challenge" value="{{.Challenge}}">
+<label>Verification code <input name="otp" autocomplete="one-time-code" required autofocus></label>
+{{else}}<label>Username <input name="username" value="{{.Username}}" autocomplete="username" required autofocus></label>
+<label>Password <input type="password" name="password" autocomplete="current-password" required></label>
+{{end}}<button type="submit">Sign in</button>
+</form>
+</body>
+</html>
+`))
+
+// loginPage is the data of the login template.
+type loginPage struct {
+	Client    string
+	Action    string
+	Params    map[string]string
+	Username  string
+	Challenge string
+	CSRFToken string
+	Error     string
+}
+
+// handleAuthorize will validate an authorization request, rendering the login form on GET and authenticating
+// the user on POST. Once authenticated the user agent is redirected to the client with an authorization code.
+// Errors are reported to the client by redirection, unless the client or its redirect URI cannot be trusted.
+// Logins are accepted only from the origin of the handler with the CSRF token of the form of the same request,
+// and the form cannot be framed by other sites.
+func (h *Handler) handleAuthorize(w http.ResponseWriter, r *http.Request, tenantID iam.TenantID) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		methodNotAllowed(w, http.MethodGet, http.MethodPost)
+		return
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("X-Frame-Options", "DENY")
+	w.Header().Set("Content-Security-Policy", "frame-ancestors 'none'")
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Malformed request.", http.StatusBadRequest)
+		return
+	}
+	request := &iam.AuthorizationRequest{
+		TenantID:            tenantID,
+		ResponseType:        r.Form.Get("response_type"),
+		ClientID:            r.Form.Get("client_id"),
+		RedirectURI:         r.Form.Get("redirect_uri"),
+		Scope:               r.Form.Get("scope"),
+		State:               r.Form.Get("state"),
+		CodeChallenge:       r.Form.Get("code_challenge"),
+		CodeChallengeMethod: r.Form.Get("code_challenge_method"),
+		Nonce:               r.Form.Get("nonce"),
+	}
+	client, err := h.OAuthService.ValidateAuthorizationRequest(request)
+	if err != nil {
+		if client == nil {
+			http.Error(w, iam.OAuthErrorOf(err).Description, http.StatusBadRequest)
+			return
+		}
+		redirectError(w, r, request, iam.OAuthErrorOf(err))
+		return
+	}
+	params := authorizationParams(request)
+	token, found, err := h.csrfToken(w, r, params)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	page := &loginPage{Client: client.Name, Action: r.URL.Path, Params: params, CSRFToken: token}
+	if r.Method == http.MethodGet {
+		renderLogin(w, http.StatusOK, page)
+		return
+	}
+	if !found || !h.sameOrigin(r) || !hmac.Equal([]byte(r.PostForm.Get("csrf_token")), []byte(token)) {
+		page.Error = "The sign in form expired, please try again."
+		renderLogin(w, http.StatusForbidden, page)
+		return
+	}
+	h.login(w, r, request, page)
+}
+
+// csrfToken will return the CSRF token of the login form of an authorization request, binding its parameters to
+// a random secret kept in a cookie of the user agent. The cookie is set when missing, found reports if it was sent.
+func (h *Handler) csrfToken(w http.ResponseWriter, r *http.Request, params map[string]string) (string, bool, error) {
+	var secret string
+	if c, err := r.Cookie(csrfCookie); err == nil && c.Value != "" {
+		secret = c.Value
+	}
+	found := secret != ""
+	if !found {
+		b := make([]byte, 32)
+		if _, err := rand.Read(b); err != nil {
+			return "", false, err
+		}
+		secret = base64.RawURLEncoding.EncodeToString(b)
+		http.SetCookie(w, &http.Cookie{
+			Name:     csrfCookie,
+			Value:    secret,
+			Path:     r.URL.Path,
+			Secure:   strings.HasPrefix(h.BaseURL, "https:"),
+			HttpOnly: true,
+			SameSite: http.SameSiteStrictMode,
+		})
+	}
+	values := url.Values{}
+	for name, value := range params {
+		values.Set(name, value)
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(values.Encode()))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil)), found, nil
+}
+
+// sameOrigin will check that the Origin header, when sent, is the one of the base URL.
+func (h *Handler) sameOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(h.BaseURL)
+	return err == nil && strings.EqualFold(origin, u.Scheme+"://"+u.Host)
+}
+
+func (h *Handler) login(w http.ResponseWriter, r *http.Request, request *iam.AuthorizationRequest, page *loginPage) {
+	page.Username = r.PostForm.Get("username")
+	var authentication *iam.Authentication
+	var err error
+	if challenge := r.PostForm.Get("challenge"); challenge != "" {
+		authentication, err = h.AuthenticationService.VerifySecondFactor(
+			request.TenantID,
+			page.Username,
+			challenge,
+			r.PostForm.Get("otp"),
+		)
+	} else {
+		authentication, err = h.AuthenticationService.Authenticate(request.TenantID, page.Username, r.PostForm.Get("password"))
+	}
+	if err != nil {
+		if iam.ErrorCode(err) == iam.EINTERNAL {
+			redirectError(w, r, request, iam.OAuthErrorOf(err))
+			return
+		}
+		page.Error = iam.ErrorMessage(err)
+		renderLogin(w, http.StatusUnauthorized, page)
+		return
+	}
+	switch authentication.Status {
+	case iam.Authenticated:
+		code, err := h.OAuthService.Authorize(request, authentication)
+		if err != nil {
+			redirectError(w, r, request, iam.OAuthErrorOf(err))
+			return
+		}
+		redirect(w, r, request, url.Values{"code": {code}})
+	case iam.MFARequired:
+		page.Challenge = authentication.Challenge
+		renderLogin(w, http.StatusOK, page)
+	default:
+		redirectError(w, r, request, &iam.OAuthError{
+			Code:        iam.OAuthAccessDenied,
+			Description: "User must complete the account setup before signing in.",
+		})
+	}
+}
+
+// authorizationParams will return the non empty parameters of the request, to carry along the login form.
+func authorizationParams(request *iam.AuthorizationRequest) map[string]string {
+	params := map[string]string{}
+	for name, value := range map[string]string{
+		"response_type":         request.ResponseType,
+		"client_id":             request.ClientID,
+		"redirect_uri":          request.RedirectURI,
+		"scope":                 request.Scope,
+		"state":                 request.State,
+		"code_challenge":        request.CodeChallenge,
+		"code_challenge_method": request.CodeChallengeMethod,
+		"nonce":                 request.Nonce,
+	} {
+		if value != "" {
+			params[name] = value
+		}
+	}
+	return params
+}
+
+func renderLogin(w http.ResponseWriter, status int, page *loginPage) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	loginTemplate.Execute(w, page)
+}
+
+func redirectError(w http.ResponseWriter, r *http.Request, request *iam.AuthorizationRequest, e *iam.OAuthError) {
+	redirect(w, r, request, url.Values{"error": {e.Code}, "error_description": {e.Description}})
+}
+
+// redirect will send the user agent back to the verified redirect URI of the request, adding supplied parameters
+// and the state of the request to its query.
+func redirect(w http.ResponseWriter, r *http.Request, request *iam.AuthorizationRequest, params url.Values) {
+	u, err := url.Parse(request.RedirectURI)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	query := u.Query()
+	for name, values := range params {
+		query[name] = values
+	}
+	if request.State != "" {
+		query.Set("state", request.State)
+	}
+	u.RawQuery = query.Encode()
+	http.Redirect(w, r, u.String(), http.StatusFound)
+}
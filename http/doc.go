@@ -0,0 +1,4 @@
+// Package http will hold the HTTP transport of the OAuth 2.0 authorization server and OpenID Connect provider:
+// the discovery document, the authorize endpoint with its login form, the token and userinfo endpoints and the
+// key set publishing the keys that verify tokens.
+package http
@@ -0,0 +1,102 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/maurofran/iam"
+	"github.com/maurofran/iam/jwt"
+)
+
+// KeySetPath is the path of the JSON web key set publishing the keys that verify access tokens.
+const KeySetPath = "/.well-known/jwks.json"
+
+// Handler is the HTTP handler of the authorization server and OpenID Connect provider. Endpoints are scoped by
+// tenant, each tenant being its own issuer under BaseURL, the external URL the handler is served at:
+//
+//	GET       /{tenantID}/.well-known/openid-configuration
+//	GET, POST /{tenantID}/oauth2/authorize
+//	POST      /{tenantID}/oauth2/token
+//	GET, POST /{tenantID}/oauth2/userinfo
+//	GET       /.well-known/jwks.json
+type Handler struct {
+	BaseURL               string
+	AuthenticationService iam.AuthenticationService
+	OAuthService          iam.OAuthService
+	SigningKeyService     iam.SigningKeyService
+}
+
+// NewHandler will create a new handler served at supplied base URL, backed by supplied services.
+func NewHandler(
+	baseURL string,
+	authenticationService iam.AuthenticationService,
+	oauthService iam.OAuthService,
+	signingKeyService iam.SigningKeyService,
+) *Handler {
+	return &Handler{
+		BaseURL:               strings.TrimSuffix(baseURL, "/"),
+		AuthenticationService: authenticationService,
+		OAuthService:          oauthService,
+		SigningKeyService:     signingKeyService,
+	}
+}
+
+// ServeHTTP will route the request to the endpoint of its path.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == KeySetPath {
+		h.handleKeySet(w, r)
+		return
+	}
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(parts) != 3 || parts[0] == "" {
+		http.NotFound(w, r)
+		return
+	}
+	tenantID := iam.TenantID(parts[0])
+	switch parts[1] + "/" + parts[2] {
+	case ".well-known/openid-configuration":
+		h.handleDiscovery(w, r, tenantID)
+	case "oauth2/authorize":
+		h.handleAuthorize(w, r, tenantID)
+	case "oauth2/token":
+		h.handleToken(w, r, tenantID)
+	case "oauth2/userinfo":
+		h.handleUserInfo(w, r, tenantID)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (h *Handler) handleKeySet(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w, http.MethodGet)
+		return
+	}
+	set, err := h.keySet()
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, set)
+}
+
+// keySet will return the key set of the published signing keys.
+func (h *Handler) keySet() (*jwt.KeySet, error) {
+	keys, err := h.SigningKeyService.PublishedSigningKeys()
+	if err != nil {
+		return nil, err
+	}
+	return jwt.NewKeySet(keys)
+}
+
+func methodNotAllowed(w http.ResponseWriter, methods ...string) {
+	w.Header().Set("Allow", strings.Join(methods, ", "))
+	http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
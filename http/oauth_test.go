@@ -0,0 +1,644 @@
+package http_test
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/maurofran/iam"
+	iamhttp "github.com/maurofran/iam/http"
+	"github.com/maurofran/iam/jwt"
+	"github.com/maurofran/iam/memory"
+	"github.com/maurofran/iam/mock"
+)
+
+var _ = Describe("OAuth 2.0 authorization server", func() {
+	const (
+		baseURL     = "https://iam.example.com"
+		issuer      = baseURL + "/acme"
+		password    = "gV7#pLq2!wZx"
+		redirectURI = "https://app.example.com/callback"
+		verifier    = "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+	)
+
+	var (
+		tenant    *iam.Tenant
+		user      *iam.User
+		clients   map[string]*iam.Client
+		secrets   map[string]string
+		codes     map[string]*iam.AuthorizationCode
+		sessions  map[string]*iam.Session
+		server    *httptest.Server
+		client    *http.Client
+		challenge string
+		scope     string
+	)
+
+	register := func(name string, registration iam.ClientRegistration) {
+		registration.Name = name
+		c, secret, _, err := tenant.RegisterClient(registration)
+		Expect(err).NotTo(HaveOccurred())
+		c.ID = name
+		clients[name] = c
+		secrets[name] = secret
+	}
+
+	BeforeEach(func() {
+		enc, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+		Expect(err).NotTo(HaveOccurred())
+		tenant = &iam.Tenant{ID: "acme", Name: "ACME", Active: true}
+		user = &iam.User{
+			TenantID:   tenant.ID,
+			Username:   "jdoe",
+			Password:   base64.StdEncoding.EncodeToString(enc),
+			Enablement: iam.IndefiniteEnablement(),
+		}
+		account := &iam.ServiceAccount{TenantID: tenant.ID, Name: "ci", Enablement: iam.IndefiniteEnablement()}
+		sum := sha256.Sum256([]byte(verifier))
+		challenge = base64.RawURLEncoding.EncodeToString(sum[:])
+
+		clients = map[string]*iam.Client{}
+		secrets = map[string]string{}
+		scopes := []string{iam.OpenIDScope, iam.ProfileScope, iam.EmailScope, iam.PhoneScope, iam.AddressScope}
+		register("web", iam.ClientRegistration{
+			RedirectURIs: []string{redirectURI},
+			GrantTypes:   []iam.GrantType{iam.AuthorizationCodeGrant, iam.RefreshTokenGrant},
+			Scopes:       scopes,
+		})
+		register("spa", iam.ClientRegistration{
+			Public:       true,
+			RedirectURIs: []string{redirectURI},
+			GrantTypes:   []iam.GrantType{iam.AuthorizationCodeGrant, iam.RefreshTokenGrant},
+			Scopes:       scopes,
+		})
+		register("batch", iam.ClientRegistration{
+			GrantTypes:     []iam.GrantType{iam.ClientCredentialsGrant},
+			Scopes:         []string{"reports", iam.OpenIDScope},
+			ServiceAccount: account.Name,
+		})
+		codes = map[string]*iam.AuthorizationCode{}
+		sessions = map[string]*iam.Session{}
+		scope = ""
+
+		tr := &mock.TenantRepository{
+			TenantOfIDFn: func(tenantID iam.TenantID) (*iam.Tenant, error) {
+				if tenantID == tenant.ID {
+					return tenant, nil
+				}
+				return nil, nil
+			},
+		}
+		ur := &mock.UserRepository{
+			UserWithUsernameFn: func(tenantID iam.TenantID, username string) (*iam.User, error) {
+				if tenantID == user.TenantID && username == user.Username {
+					return user, nil
+				}
+				return nil, nil
+			},
+			UpdateFn: func(*iam.User) error {
+				return nil
+			},
+			IncrementFailedAttemptsFn: func(iam.TenantID, string) (int, error) {
+				return user.FailedAttempts + 1, nil
+			},
+			UpdateLockoutFn: func(*iam.User) error {
+				return nil
+			},
+		}
+		sar := &mock.ServiceAccountRepository{
+			ServiceAccountNamedFn: func(tenantID iam.TenantID, name string) (*iam.ServiceAccount, error) {
+				if tenantID == account.TenantID && name == account.Name {
+					return account, nil
+				}
+				return nil, nil
+			},
+		}
+		cr := &mock.ClientRepository{
+			ClientOfIDFn: func(tenantID iam.TenantID, clientID string) (*iam.Client, error) {
+				return clients[clientID], nil
+			},
+		}
+		// Codes are copied in and out, as a database would, so that redemption is only seen once stored.
+		acr := &mock.AuthorizationCodeRepository{
+			AddFn: func(code *iam.AuthorizationCode) error {
+				c := *code
+				codes[code.Code] = &c
+				return nil
+			},
+			RedeemFn: func(code *iam.AuthorizationCode) (bool, error) {
+				stored := codes[code.Code]
+				if stored.Redeemed {
+					return false, nil
+				}
+				stored.Redeemed = true
+				return true, nil
+			},
+			AuthorizationCodeWithCodeFn: func(_ iam.TenantID, code string) (*iam.AuthorizationCode, error) {
+				stored, ok := codes[code]
+				if !ok {
+					return nil, nil
+				}
+				c := *stored
+				return &c, nil
+			},
+		}
+		sr := &mock.SessionRepository{
+			AddFn: func(s *iam.Session) error {
+				sessions[s.ID] = s
+				return nil
+			},
+			UpdateFn: func(*iam.Session) error {
+				return nil
+			},
+			RotateFn: func(*iam.Session, string) (bool, error) {
+				return true, nil
+			},
+			SessionOfIDFn: func(_ iam.TenantID, id string) (*iam.Session, error) {
+				return sessions[id], nil
+			},
+			SessionWithRefreshTokenFn: func(_ iam.TenantID, token string) (*iam.Session, error) {
+				for _, s := range sessions {
+					if s.RefreshToken == token {
+						return s, nil
+					}
+					for _, t := range s.RotatedRefreshTokens {
+						if t == token {
+							return s, nil
+						}
+					}
+				}
+				return nil, nil
+			},
+		}
+		rr := &mock.RoleRepository{
+			AllRolesFn: func(iam.TenantID) (iam.Roles, error) {
+				return nil, nil
+			},
+		}
+		ep := &mock.EventPublisher{
+			PublishFn: func(iam.Events) error {
+				return nil
+			},
+		}
+
+		signingKeyService := iam.NewSigningKeyService(memory.NewKeyStore(), ep, time.Minute, time.Hour)
+		_, err = signingKeyService.RotateSigningKey(iam.ES256)
+		Expect(err).NotTo(HaveOccurred())
+		authorizationService := iam.NewAuthorizationService(ur, sar, &mock.GroupRepository{}, rr)
+		tokenService := jwt.NewTokenService(signingKeyService, authorizationService, baseURL, 5*time.Minute)
+		sessionService := iam.NewSessionService(tr, ur, sr, ep, time.Hour)
+		oauthService := iam.NewOAuthService(tr, ur, sar, cr, acr, sessionService, tokenService, ep)
+		authenticationService := iam.NewAuthenticationService(tr, ur, ep)
+
+		server = httptest.NewServer(iamhttp.NewHandler(baseURL, authenticationService, oauthService, signingKeyService))
+		client = &http.Client{
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		}
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	params := func(clientID string) url.Values {
+		values := url.Values{
+			"response_type":         {"code"},
+			"client_id":             {clientID},
+			"redirect_uri":          {redirectURI},
+			"state":                 {"xyz"},
+			"code_challenge":        {challenge},
+			"code_challenge_method": {"S256"},
+		}
+		if scope != "" {
+			values.Set("scope", scope)
+			values.Set("nonce", "n-0S6_WzA2Mj")
+		}
+		return values
+	}
+
+	body := func(res *http.Response) string {
+		defer res.Body.Close()
+		b, err := ioutil.ReadAll(res.Body)
+		Expect(err).NotTo(HaveOccurred())
+		return string(b)
+	}
+
+	authorize := func(values url.Values) *http.Response {
+		res, err := client.Get(server.URL + "/acme/oauth2/authorize?" + values.Encode())
+		Expect(err).NotTo(HaveOccurred())
+		return res
+	}
+
+	// form will render the login form of the request, returning its CSRF token and cookie.
+	form := func(values url.Values) (string, *http.Cookie) {
+		request := url.Values{}
+		for name := range values {
+			switch name {
+			case "username", "password", "challenge", "otp", "csrf_token":
+			default:
+				request.Set(name, values.Get(name))
+			}
+		}
+		res := authorize(request)
+		Expect(res.StatusCode).To(Equal(http.StatusOK))
+		Expect(res.Cookies()).To(HaveLen(1))
+		page := body(res)
+		start := strings.Index(page, `name="csrf_token" value="`) + len(`name="csrf_token" value="`)
+		return page[start : start+strings.Index(page[start:], `"`)], res.Cookies()[0]
+	}
+
+	post := func(values url.Values, cookie *http.Cookie, origin string) *http.Response {
+		req, err := http.NewRequest(http.MethodPost, server.URL+"/acme/oauth2/authorize", strings.NewReader(values.Encode()))
+		Expect(err).NotTo(HaveOccurred())
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		if cookie != nil {
+			req.AddCookie(cookie)
+		}
+		if origin != "" {
+			req.Header.Set("Origin", origin)
+		}
+		res, err := client.Do(req)
+		Expect(err).NotTo(HaveOccurred())
+		return res
+	}
+
+	login := func(values url.Values) *http.Response {
+		csrfToken, cookie := form(values)
+		values.Set("csrf_token", csrfToken)
+		return post(values, cookie, baseURL)
+	}
+
+	redirected := func(res *http.Response) url.Values {
+		Expect(res.StatusCode).To(Equal(http.StatusFound))
+		location, err := url.Parse(res.Header.Get("Location"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(location.Scheme + "://" + location.Host + location.Path).To(Equal(redirectURI))
+		return location.Query()
+	}
+
+	code := func(clientID string) string {
+		values := params(clientID)
+		values.Set("username", "jdoe")
+		values.Set("password", password)
+		query := redirected(login(values))
+		Expect(query.Get("state")).To(Equal("xyz"))
+		Expect(query.Get("code")).NotTo(BeEmpty())
+		return query.Get("code")
+	}
+
+	token := func(values url.Values, clientID, secret string) (int, map[string]interface{}) {
+		if secret == "" {
+			values.Set("client_id", clientID)
+		}
+		req, err := http.NewRequest(http.MethodPost, server.URL+"/acme/oauth2/token", strings.NewReader(values.Encode()))
+		Expect(err).NotTo(HaveOccurred())
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		if secret != "" {
+			req.SetBasicAuth(url.QueryEscape(clientID), url.QueryEscape(secret))
+		}
+		res, err := client.Do(req)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(res.Header.Get("Cache-Control")).To(Equal("no-store"))
+		var payload map[string]interface{}
+		Expect(json.Unmarshal([]byte(body(res)), &payload)).To(Succeed())
+		return res.StatusCode, payload
+	}
+
+	exchange := func(code, clientID, secret string) (int, map[string]interface{}) {
+		return token(url.Values{
+			"grant_type":    {"authorization_code"},
+			"code":          {code},
+			"redirect_uri":  {redirectURI},
+			"code_verifier": {verifier},
+		}, clientID, secret)
+	}
+
+	refresh := func(refreshToken, clientID, secret string) (int, map[string]interface{}) {
+		return token(url.Values{"grant_type": {"refresh_token"}, "refresh_token": {refreshToken}}, clientID, secret)
+	}
+
+	verify := func(accessToken string) *jwt.Claims {
+		claims, err := jwt.NewVerifier(issuer, jwt.NewRemoteKeySet(server.URL+iamhttp.KeySetPath)).Verify(accessToken)
+		Expect(err).NotTo(HaveOccurred())
+		return claims
+	}
+
+	Describe("authorize endpoint", func() {
+		It("should render the login form carrying the request", func() {
+			res := authorize(params("web"))
+			Expect(res.StatusCode).To(Equal(http.StatusOK))
+			Expect(res.Header.Get("X-Frame-Options")).To(Equal("DENY"))
+			Expect(res.Header.Get("Content-Security-Policy")).To(Equal("frame-ancestors 'none'"))
+			page := body(res)
+			Expect(page).To(ContainSubstring("Sign in to web"))
+			Expect(page).To(ContainSubstring(`name="code_challenge" value="` + challenge + `"`))
+		})
+		It("should not redirect to unknown clients", func() {
+			res := authorize(params("unknown"))
+			Expect(res.StatusCode).To(Equal(http.StatusBadRequest))
+			Expect(res.Header.Get("Location")).To(BeEmpty())
+		})
+		It("should not redirect to unregistered redirect URIs", func() {
+			values := params("web")
+			values.Set("redirect_uri", "https://evil.example.com/callback")
+			res := authorize(values)
+			Expect(res.StatusCode).To(Equal(http.StatusBadRequest))
+			Expect(res.Header.Get("Location")).To(BeEmpty())
+		})
+		It("should redirect requests without PKCE with an error", func() {
+			values := params("web")
+			values.Del("code_challenge")
+			query := redirected(authorize(values))
+			Expect(query.Get("error")).To(Equal("invalid_request"))
+			Expect(query.Get("state")).To(Equal("xyz"))
+		})
+		It("should redirect requests of scopes not allowed for the client with an error", func() {
+			values := params("web")
+			values.Set("scope", "openid reports")
+			Expect(redirected(authorize(values)).Get("error")).To(Equal("invalid_scope"))
+		})
+		It("should redirect requests of another response type with an error", func() {
+			values := params("web")
+			values.Set("response_type", "token")
+			Expect(redirected(authorize(values)).Get("error")).To(Equal("unsupported_response_type"))
+		})
+		It("should refuse logins without the CSRF token of the request", func() {
+			values := params("web")
+			values.Set("username", "jdoe")
+			values.Set("password", password)
+			csrfToken, cookie := form(values)
+
+			res := post(values, cookie, "")
+			Expect(res.StatusCode).To(Equal(http.StatusForbidden))
+			Expect(res.Header.Get("Location")).To(BeEmpty())
+
+			values.Set("csrf_token", csrfToken)
+			res = post(values, nil, "")
+			Expect(res.StatusCode).To(Equal(http.StatusForbidden))
+			Expect(res.Cookies()).To(HaveLen(1))
+
+			values.Set("state", "other")
+			Expect(post(values, cookie, "").StatusCode).To(Equal(http.StatusForbidden))
+			values.Set("state", "xyz")
+			Expect(post(values, cookie, "https://evil.example.com").StatusCode).To(Equal(http.StatusForbidden))
+			Expect(redirected(post(values, cookie, "")).Get("code")).NotTo(BeEmpty())
+		})
+		It("should set the CSRF cookie for the authorize endpoint only", func() {
+			_, cookie := form(params("web"))
+			Expect(cookie.Path).To(Equal("/acme/oauth2/authorize"))
+			Expect(cookie.HttpOnly).To(BeTrue())
+			Expect(cookie.Secure).To(BeTrue())
+		})
+		It("should render the login form again on invalid credentials", func() {
+			values := params("web")
+			values.Set("username", "jdoe")
+			values.Set("password", "wrong")
+			res := login(values)
+			Expect(res.StatusCode).To(Equal(http.StatusUnauthorized))
+			Expect(body(res)).To(ContainSubstring("Invalid username or password."))
+		})
+		It("should ask the second factor before issuing a code", func() {
+			provisioning, _, err := user.EnrollTOTP("ACME")
+			Expect(err).NotTo(HaveOccurred())
+			previous, _ := iam.GenerateTOTPCode(provisioning.Secret, time.Now().Add(-30*time.Second))
+			_, err = user.ConfirmTOTP(previous)
+			Expect(err).NotTo(HaveOccurred())
+
+			values := params("web")
+			values.Set("username", "jdoe")
+			values.Set("password", password)
+			res := login(values)
+			Expect(res.StatusCode).To(Equal(http.StatusOK))
+			page := body(res)
+			Expect(page).To(ContainSubstring(`name="otp"`))
+			start := strings.Index(page, `name="challenge" value="`) + len(`name="challenge" value="`)
+			values.Del("password")
+			values.Set("challenge", page[start:start+strings.Index(page[start:], `"`)])
+			current, _ := iam.GenerateTOTPCode(provisioning.Secret, time.Now())
+			values.Set("otp", current)
+			Expect(redirected(login(values)).Get("code")).NotTo(BeEmpty())
+		})
+	})
+
+	Describe("authorization code grant", func() {
+		It("should exchange the code of a confidential client for tokens", func() {
+			status, payload := exchange(code("web"), "web", secrets["web"])
+			Expect(status).To(Equal(http.StatusOK))
+			Expect(payload["token_type"]).To(Equal("Bearer"))
+			Expect(payload["expires_in"]).To(BeNumerically("~", 300, 1))
+			Expect(payload["refresh_token"]).NotTo(BeEmpty())
+			claims := verify(payload["access_token"].(string))
+			Expect(claims.TenantID).To(Equal(tenant.ID))
+			Expect(claims.Username).To(Equal("jdoe"))
+			Expect(claims.ClientID).To(Equal("web"))
+		})
+		It("should exchange the code of a public client without secret", func() {
+			status, payload := exchange(code("spa"), "spa", "")
+			Expect(status).To(Equal(http.StatusOK))
+			Expect(verify(payload["access_token"].(string)).ClientID).To(Equal("spa"))
+		})
+		It("should redeem a code only once", func() {
+			c := code("web")
+			status, _ := exchange(c, "web", secrets["web"])
+			Expect(status).To(Equal(http.StatusOK))
+			status, payload := exchange(c, "web", secrets["web"])
+			Expect(status).To(Equal(http.StatusBadRequest))
+			Expect(payload["error"]).To(Equal("invalid_grant"))
+		})
+		It("should reject a wrong code verifier", func() {
+			status, payload := token(url.Values{
+				"grant_type":    {"authorization_code"},
+				"code":          {code("web")},
+				"redirect_uri":  {redirectURI},
+				"code_verifier": {strings.Repeat("a", 43)},
+			}, "web", secrets["web"])
+			Expect(status).To(Equal(http.StatusBadRequest))
+			Expect(payload["error"]).To(Equal("invalid_grant"))
+		})
+		It("should reject a code issued to another client", func() {
+			status, payload := exchange(code("spa"), "web", secrets["web"])
+			Expect(status).To(Equal(http.StatusBadRequest))
+			Expect(payload["error"]).To(Equal("invalid_grant"))
+		})
+		It("should challenge clients with a wrong secret", func() {
+			status, payload := exchange(code("web"), "web", "wrong")
+			Expect(status).To(Equal(http.StatusUnauthorized))
+			Expect(payload["error"]).To(Equal("invalid_client"))
+		})
+	})
+
+	Describe("refresh token grant", func() {
+		var refreshToken string
+
+		BeforeEach(func() {
+			_, payload := exchange(code("web"), "web", secrets["web"])
+			refreshToken = payload["refresh_token"].(string)
+		})
+
+		It("should rotate the refresh token", func() {
+			status, payload := refresh(refreshToken, "web", secrets["web"])
+			Expect(status).To(Equal(http.StatusOK))
+			Expect(payload["refresh_token"]).NotTo(Equal(refreshToken))
+			Expect(verify(payload["access_token"].(string)).Username).To(Equal("jdoe"))
+		})
+		It("should revoke the session when a rotated refresh token is reused", func() {
+			_, payload := refresh(refreshToken, "web", secrets["web"])
+			status, reused := refresh(refreshToken, "web", secrets["web"])
+			Expect(status).To(Equal(http.StatusBadRequest))
+			Expect(reused["error"]).To(Equal("invalid_grant"))
+			status, _ = refresh(payload["refresh_token"].(string), "web", secrets["web"])
+			Expect(status).To(Equal(http.StatusBadRequest))
+		})
+		It("should revoke the session when another client presents the refresh token", func() {
+			status, payload := refresh(refreshToken, "spa", "")
+			Expect(status).To(Equal(http.StatusBadRequest))
+			Expect(payload["error"]).To(Equal("invalid_grant"))
+			for _, s := range sessions {
+				Expect(s.IsActive(time.Now())).To(BeFalse())
+			}
+		})
+	})
+
+	Describe("client credentials grant", func() {
+		It("should issue a token to the service account of the client", func() {
+			status, payload := token(url.Values{"grant_type": {"client_credentials"}}, "batch", secrets["batch"])
+			Expect(status).To(Equal(http.StatusOK))
+			Expect(payload).NotTo(HaveKey("refresh_token"))
+			claims := verify(payload["access_token"].(string))
+			Expect(claims.Account).To(Equal("ci"))
+			Expect(claims.ClientID).To(Equal("batch"))
+		})
+		It("should refuse scopes not allowed for the client", func() {
+			status, payload := token(url.Values{"grant_type": {"client_credentials"}, "scope": {"reports"}}, "batch", secrets["batch"])
+			Expect(status).To(Equal(http.StatusOK))
+			Expect(payload["scope"]).To(Equal("reports"))
+			status, payload = token(url.Values{"grant_type": {"client_credentials"}, "scope": {"reports admin"}}, "batch", secrets["batch"])
+			Expect(status).To(Equal(http.StatusBadRequest))
+			Expect(payload["error"]).To(Equal("invalid_scope"))
+		})
+		It("should refuse clients not registered for the grant", func() {
+			status, payload := token(url.Values{"grant_type": {"client_credentials"}}, "spa", "")
+			Expect(status).To(Equal(http.StatusBadRequest))
+			Expect(payload["error"]).To(Equal("unauthorized_client"))
+		})
+	})
+
+	It("should refuse unsupported grant types", func() {
+		status, payload := token(url.Values{"grant_type": {"password"}}, "web", secrets["web"])
+		Expect(status).To(Equal(http.StatusBadRequest))
+		Expect(payload["error"]).To(Equal("unsupported_grant_type"))
+	})
+
+	Describe("OpenID Connect", func() {
+		userInfo := func(accessToken string) (int, map[string]interface{}) {
+			req, err := http.NewRequest(http.MethodGet, server.URL+"/acme/oauth2/userinfo", nil)
+			Expect(err).NotTo(HaveOccurred())
+			req.Header.Set("Authorization", "Bearer "+accessToken)
+			res, err := client.Do(req)
+			Expect(err).NotTo(HaveOccurred())
+			var payload map[string]interface{}
+			if res.StatusCode == http.StatusOK {
+				Expect(json.Unmarshal([]byte(body(res)), &payload)).To(Succeed())
+			}
+			return res.StatusCode, payload
+		}
+
+		BeforeEach(func() {
+			scope = "openid profile email"
+			user.Person = &iam.Person{
+				FullName: iam.FullName{FirstName: "John", LastName: "Doe"},
+				ContactInformation: iam.ContactInformation{
+					EmailAddress:     "jdoe@example.com",
+					PrimaryTelephone: "+39 02 1234567",
+					PostalAddress:    iam.PostalAddress{StreetName: "Via Roma", BuildingNumber: "1", Town: "Milano"},
+				},
+			}
+		})
+
+		It("should publish the metadata of the tenant issuer", func() {
+			res, err := client.Get(server.URL + "/acme/.well-known/openid-configuration")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(res.StatusCode).To(Equal(http.StatusOK))
+			var metadata map[string]interface{}
+			Expect(json.Unmarshal([]byte(body(res)), &metadata)).To(Succeed())
+			Expect(metadata["issuer"]).To(Equal(issuer))
+			Expect(metadata["authorization_endpoint"]).To(Equal(issuer + "/oauth2/authorize"))
+			Expect(metadata["token_endpoint"]).To(Equal(issuer + "/oauth2/token"))
+			Expect(metadata["userinfo_endpoint"]).To(Equal(issuer + "/oauth2/userinfo"))
+			Expect(metadata["jwks_uri"]).To(Equal(baseURL + iamhttp.KeySetPath))
+			Expect(metadata["code_challenge_methods_supported"]).To(ConsistOf("S256"))
+		})
+		It("should issue an ID token carrying the nonce and the claims of the scope", func() {
+			status, payload := exchange(code("web"), "web", secrets["web"])
+			Expect(status).To(Equal(http.StatusOK))
+			Expect(payload["scope"]).To(Equal(scope))
+			claims := new(jwt.IDClaims)
+			keys := jwt.NewRemoteKeySet(server.URL + iamhttp.KeySetPath)
+			Expect(jwt.NewVerifier(issuer, keys).VerifyInto(payload["id_token"].(string), claims)).To(Succeed())
+			Expect(claims.Issuer).To(Equal(issuer))
+			Expect(claims.Audience).To(Equal(jwt.Audience{"web"}))
+			Expect(claims.Subject).To(Equal("jdoe"))
+			Expect(claims.Nonce).To(Equal("n-0S6_WzA2Mj"))
+			Expect(claims.AuthTime).To(BeNumerically("~", time.Now().Unix(), 5))
+			Expect(claims.Name).To(Equal("John Doe"))
+			Expect(claims.Email).To(Equal("jdoe@example.com"))
+			Expect(claims.PhoneNumber).To(BeEmpty())
+			Expect(claims.Address).To(BeNil())
+		})
+		It("should not issue ID tokens without the openid scope", func() {
+			scope = "profile"
+			_, payload := exchange(code("web"), "web", secrets["web"])
+			Expect(payload).NotTo(HaveKey("id_token"))
+			status, _ := userInfo(payload["access_token"].(string))
+			Expect(status).To(Equal(http.StatusUnauthorized))
+		})
+		It("should keep the scope when refreshing", func() {
+			_, payload := exchange(code("web"), "web", secrets["web"])
+			status, refreshed := refresh(payload["refresh_token"].(string), "web", secrets["web"])
+			Expect(status).To(Equal(http.StatusOK))
+			Expect(refreshed["scope"]).To(Equal(scope))
+			Expect(refreshed["id_token"]).NotTo(BeEmpty())
+			Expect(verify(refreshed["access_token"].(string)).Scope).To(Equal(scope))
+		})
+		It("should release the claims of the scope from the userinfo endpoint", func() {
+			scope = "openid phone address"
+			_, payload := exchange(code("web"), "web", secrets["web"])
+			status, info := userInfo(payload["access_token"].(string))
+			Expect(status).To(Equal(http.StatusOK))
+			Expect(info["sub"]).To(Equal("jdoe"))
+			Expect(info["phone_number"]).To(Equal("+39 02 1234567"))
+			Expect(info["address"]).To(HaveKeyWithValue("street_address", "Via Roma 1"))
+			Expect(info["address"]).To(HaveKeyWithValue("locality", "Milano"))
+			Expect(info).NotTo(HaveKey("email"))
+			Expect(info).NotTo(HaveKey("name"))
+		})
+		It("should refuse ID tokens, service account tokens and tokens of other tenants at the userinfo endpoint", func() {
+			_, payload := exchange(code("web"), "web", secrets["web"])
+			status, _ := userInfo(payload["id_token"].(string))
+			Expect(status).To(Equal(http.StatusUnauthorized))
+
+			_, payload = token(url.Values{"grant_type": {"client_credentials"}, "scope": {"openid"}}, "batch", secrets["batch"])
+			status, _ = userInfo(payload["access_token"].(string))
+			Expect(status).To(Equal(http.StatusUnauthorized))
+
+			_, payload = exchange(code("web"), "web", secrets["web"])
+			req, _ := http.NewRequest(http.MethodGet, server.URL+"/other/oauth2/userinfo", nil)
+			req.Header.Set("Authorization", "Bearer "+payload["access_token"].(string))
+			res, err := client.Do(req)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(res.StatusCode).To(Equal(http.StatusUnauthorized))
+		})
+	})
+})
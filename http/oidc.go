@@ -0,0 +1,103 @@
+package http
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/maurofran/iam"
+	"github.com/maurofran/iam/jwt"
+)
+
+// discovery is the OpenID Connect provider metadata of a tenant.
+type discovery struct {
+	Issuer                            string                 `json:"issuer"`
+	AuthorizationEndpoint             string                 `json:"authorization_endpoint"`
+	TokenEndpoint                     string                 `json:"token_endpoint"`
+	UserInfoEndpoint                  string                 `json:"userinfo_endpoint"`
+	JWKSURI                           string                 `json:"jwks_uri"`
+	ScopesSupported                   []string               `json:"scopes_supported"`
+	ResponseTypesSupported            []string               `json:"response_types_supported"`
+	GrantTypesSupported               []iam.GrantType        `json:"grant_types_supported"`
+	SubjectTypesSupported             []string               `json:"subject_types_supported"`
+	IDTokenSigningAlgValuesSupported  []iam.SigningAlgorithm `json:"id_token_signing_alg_values_supported"`
+	TokenEndpointAuthMethodsSupported []string               `json:"token_endpoint_auth_methods_supported"`
+	CodeChallengeMethodsSupported     []string               `json:"code_challenge_methods_supported"`
+	ClaimsSupported                   []string               `json:"claims_supported"`
+}
+
+// handleDiscovery will publish the metadata of the tenant issuer, so that OpenID Connect clients configure
+// themselves from the issuer URL alone.
+func (h *Handler) handleDiscovery(w http.ResponseWriter, r *http.Request, tenantID iam.TenantID) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w, http.MethodGet)
+		return
+	}
+	issuer := jwt.Issuer(h.BaseURL, tenantID)
+	writeJSON(w, http.StatusOK, &discovery{
+		Issuer:                 issuer,
+		AuthorizationEndpoint:  issuer + "/oauth2/authorize",
+		TokenEndpoint:          issuer + "/oauth2/token",
+		UserInfoEndpoint:       issuer + "/oauth2/userinfo",
+		JWKSURI:                h.BaseURL + KeySetPath,
+		ScopesSupported:        []string{iam.OpenIDScope, iam.ProfileScope, iam.EmailScope, iam.PhoneScope, iam.AddressScope},
+		ResponseTypesSupported: []string{"code"},
+		GrantTypesSupported: []iam.GrantType{
+			iam.AuthorizationCodeGrant,
+			iam.ClientCredentialsGrant,
+			iam.RefreshTokenGrant,
+		},
+		SubjectTypesSupported:             []string{"public"},
+		IDTokenSigningAlgValuesSupported:  []iam.SigningAlgorithm{iam.RS256, iam.ES256, iam.EdDSA},
+		TokenEndpointAuthMethodsSupported: []string{"client_secret_basic", "client_secret_post", "none"},
+		CodeChallengeMethodsSupported:     []string{iam.S256CodeChallenge},
+		ClaimsSupported: []string{
+			"iss", "sub", "aud", "exp", "iat", "auth_time", "nonce", "azp",
+			"name", "given_name", "family_name", "preferred_username", "email", "phone_number", "address",
+		},
+	})
+}
+
+// handleUserInfo will return the claims about the user of the bearer access token that its scope releases.
+// Only access tokens issued by the tenant to a user with the openid scope are accepted.
+func (h *Handler) handleUserInfo(w http.ResponseWriter, r *http.Request, tenantID iam.TenantID) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		methodNotAllowed(w, http.MethodGet, http.MethodPost)
+		return
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	token, ok := bearerToken(r)
+	if !ok {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="userinfo"`)
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+	keys, err := h.keySet()
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	claims, err := jwt.NewVerifier(jwt.Issuer(h.BaseURL, tenantID), keys).Verify(token)
+	var info *iam.UserInfo
+	if err == nil && claims.Username != "" {
+		info, err = h.OAuthService.UserInfo(tenantID, claims.Username, claims.Scope)
+	}
+	switch {
+	case err != nil && iam.ErrorCode(err) == iam.EINTERNAL:
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	case info == nil:
+		w.Header().Set("WWW-Authenticate", `Bearer realm="userinfo", error="invalid_token"`)
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+	default:
+		writeJSON(w, http.StatusOK, info)
+	}
+}
+
+// bearerToken will return the access token of the Authorization header.
+func bearerToken(r *http.Request) (string, bool) {
+	const prefix = "bearer "
+	header := r.Header.Get("Authorization")
+	if len(header) <= len(prefix) || !strings.EqualFold(header[:len(prefix)], prefix) {
+		return "", false
+	}
+	return header[len(prefix):], true
+}
@@ -0,0 +1,93 @@
+package http
+
+import (
+	"net"
+	"net/http"
+	"net/url"
+
+	"github.com/maurofran/iam"
+)
+
+// oauthErrorResponse is the body of the error responses of the token endpoint, as defined by RFC 6749.
+type oauthErrorResponse struct {
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description,omitempty"`
+}
+
+// handleToken will perform the grant of a token request. Clients authenticate either with HTTP basic
+// authentication or with the client_id and client_secret parameters, but not both.
+func (h *Handler) handleToken(w http.ResponseWriter, r *http.Request, tenantID iam.TenantID) {
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w, http.MethodPost)
+		return
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Pragma", "no-cache")
+	if err := r.ParseForm(); err != nil {
+		writeOAuthError(w, &iam.OAuthError{Code: iam.OAuthInvalidRequest, Description: "Malformed request."}, false)
+		return
+	}
+	form := r.PostForm
+	request := &iam.TokenRequest{
+		TenantID:     tenantID,
+		GrantType:    iam.GrantType(form.Get("grant_type")),
+		ClientID:     form.Get("client_id"),
+		ClientSecret: form.Get("client_secret"),
+		Code:         form.Get("code"),
+		RedirectURI:  form.Get("redirect_uri"),
+		CodeVerifier: form.Get("code_verifier"),
+		RefreshToken: form.Get("refresh_token"),
+		Scope:        form.Get("scope"),
+		Client:       iam.ClientMetadata{UserAgent: r.UserAgent(), IPAddress: remoteIP(r)},
+	}
+	id, secret, basic := r.BasicAuth()
+	if basic {
+		if request.ClientSecret != "" {
+			writeOAuthError(w, &iam.OAuthError{
+				Code:        iam.OAuthInvalidRequest,
+				Description: "Only one client authentication method may be used.",
+			}, basic)
+			return
+		}
+		// Credentials are form encoded before being put in the header, as RFC 6749 requires.
+		var err error
+		if request.ClientID, err = url.QueryUnescape(id); err == nil {
+			request.ClientSecret, err = url.QueryUnescape(secret)
+		}
+		if err != nil {
+			writeOAuthError(w, &iam.OAuthError{Code: iam.OAuthInvalidClient, Description: "Malformed client credentials."}, basic)
+			return
+		}
+	}
+	response, err := h.OAuthService.Token(request)
+	if err != nil {
+		writeOAuthError(w, iam.OAuthErrorOf(err), basic)
+		return
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
+// writeOAuthError will write the error response of the token endpoint, challenging clients that authenticated
+// with HTTP basic authentication when their credentials are rejected.
+func writeOAuthError(w http.ResponseWriter, e *iam.OAuthError, basic bool) {
+	status := http.StatusBadRequest
+	switch e.Code {
+	case iam.OAuthInvalidClient:
+		status = http.StatusUnauthorized
+		if basic {
+			w.Header().Set("WWW-Authenticate", `Basic realm="oauth2"`)
+		}
+	case iam.OAuthServerError:
+		status = http.StatusInternalServerError
+	}
+	writeJSON(w, status, &oauthErrorResponse{Error: e.Code, ErrorDescription: e.Description})
+}
+
+// remoteIP will return the address of the peer. Forwarding headers are ignored, as they can be forged.
+func remoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,120 @@
+package jwt
+
+import (
+	"encoding/json"
+
+	"github.com/maurofran/iam"
+)
+
+// UserSubjectPrefix and ServiceAccountSubjectPrefix namespace the subject of access tokens, so that a user and a
+// service account of the same name are never the same principal.
+const (
+	UserSubjectPrefix           = "user:"
+	ServiceAccountSubjectPrefix = "sa:"
+)
+
+// AccessTokenUse is the use of access tokens, that the verifier requires so that other tokens signed with the same
+// keys, such as ID tokens, are refused where an access token is expected.
+const AccessTokenUse = "access"
+
+// UserSubject will return the subject of the access tokens of the user with supplied username.
+func UserSubject(username string) string {
+	return UserSubjectPrefix + username
+}
+
+// ServiceAccountSubject will return the subject of the access tokens of the service account with supplied name.
+func ServiceAccountSubject(name string) string {
+	return ServiceAccountSubjectPrefix + name
+}
+
+// Claims is the payload of the tokens issued by the identity and access management service.
+// Times are seconds since the epoch, as RFC 7519 requires. Tokens of service accounts carry Account instead of
+// Username, the subject being the namespaced one of the principal.
+type Claims struct {
+	Issuer    string       `json:"iss,omitempty"`
+	Subject   string       `json:"sub,omitempty"`
+	Audience  Audience     `json:"aud,omitempty"`
+	ExpiresAt int64        `json:"exp,omitempty"`
+	NotBefore int64        `json:"nbf,omitempty"`
+	IssuedAt  int64        `json:"iat,omitempty"`
+	ID        string       `json:"jti,omitempty"`
+	TokenUse  string       `json:"token_use,omitempty"`
+	TenantID  iam.TenantID `json:"tid,omitempty"`
+	ClientID  string       `json:"client_id,omitempty"`
+	Username  string       `json:"username,omitempty"`
+	Account   string       `json:"service_account,omitempty"`
+	Scope     string       `json:"scope,omitempty"`
+	Roles     []string     `json:"roles,omitempty"`
+}
+
+// hasPrincipalSubject will check that the claims carry either a username or a service account, the subject being
+// the one of that principal.
+func (c *Claims) hasPrincipalSubject() bool {
+	switch {
+	case c.Username != "" && c.Account == "":
+		return c.Subject == UserSubject(c.Username)
+	case c.Account != "" && c.Username == "":
+		return c.Subject == ServiceAccountSubject(c.Account)
+	default:
+		return false
+	}
+}
+
+// HasRole will check if the claims carry supplied role name.
+func (c *Claims) HasRole(roleName string) bool {
+	for _, r := range c.Roles {
+		if r == roleName {
+			return true
+		}
+	}
+	return false
+}
+
+// IDClaims is the payload of ID tokens, as defined by OpenID Connect. Unlike access tokens they carry no token use,
+// so that they are refused where an access token is expected.
+type IDClaims struct {
+	iam.UserInfo
+	Issuer          string   `json:"iss"`
+	Audience        Audience `json:"aud"`
+	ExpiresAt       int64    `json:"exp"`
+	IssuedAt        int64    `json:"iat"`
+	AuthTime        int64    `json:"auth_time,omitempty"`
+	Nonce           string   `json:"nonce,omitempty"`
+	AuthorizedParty string   `json:"azp,omitempty"`
+}
+
+// Audience is the audience claim, encoded as a single string when it holds one value.
+type Audience []string
+
+// Contains will check if the audience holds supplied value.
+func (a Audience) Contains(value string) bool {
+	for _, v := range a {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// MarshalJSON will encode the audience as a string or an array of strings.
+func (a Audience) MarshalJSON() ([]byte, error) {
+	if len(a) == 1 {
+		return json.Marshal(a[0])
+	}
+	return json.Marshal([]string(a))
+}
+
+// UnmarshalJSON will decode the audience from a string or an array of strings.
+func (a *Audience) UnmarshalJSON(b []byte) error {
+	var single string
+	if err := json.Unmarshal(b, &single); err == nil {
+		*a = Audience{single}
+		return nil
+	}
+	var multiple []string
+	if err := json.Unmarshal(b, &multiple); err != nil {
+		return err
+	}
+	*a = multiple
+	return nil
+}
@@ -0,0 +1,3 @@
+// Package jwt will hold the JSON web token implementation of the token service, together with the verifier
+// that services consuming the tokens can embed.
+package jwt
@@ -0,0 +1,194 @@
+package jwt
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/maurofran/iam"
+)
+
+// JSONWebKey is the public part of a signing key, as defined by RFC 7517 and RFC 8037.
+type JSONWebKey struct {
+	KeyType   string               `json:"kty"`
+	KeyID     string               `json:"kid"`
+	Use       string               `json:"use,omitempty"`
+	Algorithm iam.SigningAlgorithm `json:"alg"`
+	Curve     string               `json:"crv,omitempty"`
+	N         string               `json:"n,omitempty"`
+	E         string               `json:"e,omitempty"`
+	X         string               `json:"x,omitempty"`
+	Y         string               `json:"y,omitempty"`
+}
+
+// PublicKey will decode the public key.
+func (k *JSONWebKey) PublicKey() (crypto.PublicKey, error) {
+	switch {
+	case k.KeyType == "RSA":
+		n, err := b64.DecodeString(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := b64.DecodeString(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+	case k.KeyType == "EC" && k.Curve == "P-256":
+		x, err := b64.DecodeString(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := b64.DecodeString(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		key := &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !key.Curve.IsOnCurve(key.X, key.Y) {
+			return nil, fmt.Errorf("key %s is not on curve P-256", k.KeyID)
+		}
+		return key, nil
+	case k.KeyType == "OKP" && k.Curve == "Ed25519":
+		x, err := b64.DecodeString(k.X)
+		if err != nil {
+			return nil, err
+		}
+		if len(x) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("key %s has a wrong Ed25519 key size", k.KeyID)
+		}
+		return ed25519.PublicKey(x), nil
+	}
+	return nil, fmt.Errorf("key %s has unsupported type %s", k.KeyID, k.KeyType)
+}
+
+// newJSONWebKey will encode the public part of the signing key.
+func newJSONWebKey(key *iam.SigningKey) (*JSONWebKey, error) {
+	public, err := key.PublicKey()
+	if err != nil {
+		return nil, err
+	}
+	jwk := &JSONWebKey{KeyID: key.ID, Use: "sig", Algorithm: key.Algorithm}
+	switch public := public.(type) {
+	case *rsa.PublicKey:
+		jwk.KeyType = "RSA"
+		jwk.N = b64.EncodeToString(public.N.Bytes())
+		jwk.E = b64.EncodeToString(big.NewInt(int64(public.E)).Bytes())
+	case *ecdsa.PublicKey:
+		jwk.KeyType, jwk.Curve = "EC", "P-256"
+		x, y := make([]byte, es256Size), make([]byte, es256Size)
+		jwk.X = b64.EncodeToString(public.X.FillBytes(x))
+		jwk.Y = b64.EncodeToString(public.Y.FillBytes(y))
+	case ed25519.PublicKey:
+		jwk.KeyType, jwk.Curve = "OKP", "Ed25519"
+		jwk.X = b64.EncodeToString(public)
+	default:
+		return nil, &iam.Error{Code: iam.EINVALID, Message: "Unsupported signing algorithm.", Op: "KeySet"}
+	}
+	return jwk, nil
+}
+
+// KeySource is the interface for sources of the public keys verifying tokens.
+// Key returns nil when no key with supplied id is known.
+type KeySource interface {
+	Key(keyID string) (*JSONWebKey, error)
+}
+
+// KeySet is the JSON web key set document publishing the keys that verify tokens.
+type KeySet struct {
+	Keys []*JSONWebKey `json:"keys"`
+}
+
+// NewKeySet will create the key set publishing the public part of supplied keys.
+func NewKeySet(keys iam.SigningKeys) (*KeySet, error) {
+	set := &KeySet{Keys: make([]*JSONWebKey, 0, len(keys))}
+	for _, k := range keys {
+		jwk, err := newJSONWebKey(k)
+		if err != nil {
+			return nil, err
+		}
+		set.Keys = append(set.Keys, jwk)
+	}
+	return set, nil
+}
+
+// Key will return the key with supplied id, or nil.
+func (s *KeySet) Key(keyID string) (*JSONWebKey, error) {
+	for _, k := range s.Keys {
+		if k.KeyID == keyID {
+			return k, nil
+		}
+	}
+	return nil, nil
+}
+
+// defaultKeySetMaxAge is the default time a remote key set is cached for.
+// minKeySetRefresh is the minimum time between fetches triggered by unknown key ids.
+const (
+	defaultKeySetMaxAge = 15 * time.Minute
+	minKeySetRefresh    = 30 * time.Second
+)
+
+// RemoteKeySet is the key source fetching and caching the key set published at an URL, safe for concurrent use.
+// The key set is fetched again once older than MaxAge, or when an unknown key id shows up, so that rotated
+// keys are picked up promptly.
+type RemoteKeySet struct {
+	URL    string
+	Client *http.Client
+	MaxAge time.Duration
+
+	mu        sync.Mutex
+	keys      *KeySet
+	fetchedAt time.Time
+}
+
+// NewRemoteKeySet will create a new remote key set fetched from supplied URL with the default HTTP client.
+func NewRemoteKeySet(url string) *RemoteKeySet {
+	return &RemoteKeySet{URL: url, Client: http.DefaultClient, MaxAge: defaultKeySetMaxAge}
+}
+
+// Key will return the key with supplied id, or nil.
+func (r *RemoteKeySet) Key(keyID string) (*JSONWebKey, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	age := time.Since(r.fetchedAt)
+	if r.keys != nil && age < r.MaxAge {
+		if k, _ := r.keys.Key(keyID); k != nil || age < minKeySetRefresh {
+			return k, nil
+		}
+	}
+	keys, err := r.fetch()
+	if err != nil {
+		return nil, &iam.Error{
+			Code:    iam.EINTERNAL,
+			Message: "An unexpected error occurred while fetching key set.",
+			Op:      "Key",
+			Err:     err,
+		}
+	}
+	r.keys, r.fetchedAt = keys, time.Now()
+	return keys.Key(keyID)
+}
+
+func (r *RemoteKeySet) fetch() (*KeySet, error) {
+	resp, err := r.Client.Get(r.URL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetching %s returned status %d", r.URL, resp.StatusCode)
+	}
+	keys := new(KeySet)
+	if err := json.NewDecoder(resp.Body).Decode(keys); err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
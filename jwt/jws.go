@@ -0,0 +1,128 @@
+package jwt
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"strings"
+
+	"github.com/maurofran/iam"
+)
+
+// es256Size is the size of each of the r and s values of an ES256 signature.
+const es256Size = 32
+
+var b64 = base64.RawURLEncoding
+
+// header is the JOSE header of a signed token.
+type header struct {
+	Algorithm iam.SigningAlgorithm `json:"alg"`
+	Type      string               `json:"typ,omitempty"`
+	KeyID     string               `json:"kid,omitempty"`
+}
+
+// Sign will encode supplied claims as a compact JSON web signature with the signing key.
+func Sign(claims interface{}, key *iam.SigningKey) (string, error) {
+	const op = "Sign"
+	h, err := json.Marshal(&header{Algorithm: key.Algorithm, Type: "JWT", KeyID: key.ID})
+	if err != nil {
+		return "", signError(op, err)
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", signError(op, err)
+	}
+	input := b64.EncodeToString(h) + "." + b64.EncodeToString(payload)
+	signer, err := key.Signer()
+	if err != nil {
+		return "", err
+	}
+	signature, err := sign(signer, []byte(input))
+	if err != nil {
+		return "", signError(op, err)
+	}
+	return input + "." + b64.EncodeToString(signature), nil
+}
+
+func signError(op string, err error) error {
+	return &iam.Error{
+		Code:    iam.EINTERNAL,
+		Message: "An unexpected error occurred while signing token.",
+		Op:      op,
+		Err:     err,
+	}
+}
+
+func sign(signer crypto.Signer, input []byte) ([]byte, error) {
+	switch key := signer.(type) {
+	case *rsa.PrivateKey:
+		digest := sha256.Sum256(input)
+		return rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	case *ecdsa.PrivateKey:
+		digest := sha256.Sum256(input)
+		r, s, err := ecdsa.Sign(rand.Reader, key, digest[:])
+		if err != nil {
+			return nil, err
+		}
+		signature := make([]byte, 2*es256Size)
+		r.FillBytes(signature[:es256Size])
+		s.FillBytes(signature[es256Size:])
+		return signature, nil
+	case ed25519.PrivateKey:
+		return ed25519.Sign(key, input), nil
+	}
+	return nil, &iam.Error{Code: iam.EINVALID, Message: "Unsupported signing algorithm.", Op: "Sign"}
+}
+
+// verify will check the signature of the input with the public key, as supplied algorithm requires.
+func verify(algorithm iam.SigningAlgorithm, public crypto.PublicKey, input, signature []byte) bool {
+	switch algorithm {
+	case iam.RS256:
+		key, ok := public.(*rsa.PublicKey)
+		digest := sha256.Sum256(input)
+		return ok && rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature) == nil
+	case iam.ES256:
+		key, ok := public.(*ecdsa.PublicKey)
+		if !ok || key.Curve != elliptic.P256() || len(signature) != 2*es256Size {
+			return false
+		}
+		digest := sha256.Sum256(input)
+		r := new(big.Int).SetBytes(signature[:es256Size])
+		s := new(big.Int).SetBytes(signature[es256Size:])
+		return ecdsa.Verify(key, digest[:], r, s)
+	case iam.EdDSA:
+		key, ok := public.(ed25519.PublicKey)
+		return ok && ed25519.Verify(key, input, signature)
+	}
+	return false
+}
+
+// split will decode the parts of a compact JSON web signature.
+func split(token string) (h *header, input string, payload, signature []byte, ok bool) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, "", nil, nil, false
+	}
+	rawHeader, err := b64.DecodeString(parts[0])
+	if err != nil {
+		return nil, "", nil, nil, false
+	}
+	h = new(header)
+	if err := json.Unmarshal(rawHeader, h); err != nil {
+		return nil, "", nil, nil, false
+	}
+	if payload, err = b64.DecodeString(parts[1]); err != nil {
+		return nil, "", nil, nil, false
+	}
+	if signature, err = b64.DecodeString(parts[2]); err != nil {
+		return nil, "", nil, nil, false
+	}
+	return h, parts[0] + "." + parts[1], payload, signature, true
+}
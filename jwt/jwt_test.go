@@ -0,0 +1,255 @@
+package jwt_test
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+
+	"github.com/maurofran/iam"
+	"github.com/maurofran/iam/jwt"
+	"github.com/maurofran/iam/memory"
+	"github.com/maurofran/iam/mock"
+)
+
+var _ = Describe("JSON web tokens", func() {
+	const (
+		baseURL = "https://iam.example.com"
+		issuer  = baseURL + "/tenant"
+	)
+
+	var (
+		user              *iam.User
+		signingKeyService iam.SigningKeyService
+		tokenService      iam.TokenService
+		authenticated     *iam.Authentication
+	)
+
+	BeforeEach(func() {
+		user = &iam.User{TenantID: "tenant", Username: "jdoe", Enablement: iam.IndefiniteEnablement()}
+		authenticated = &iam.Authentication{Status: iam.Authenticated, User: user}
+		ep := &mock.EventPublisher{
+			PublishFn: func(iam.Events) error {
+				return nil
+			},
+		}
+		signingKeyService = iam.NewSigningKeyService(memory.NewKeyStore(), ep, 0, 0)
+		as := &mock.AuthorizationService{
+			UserRolesFn: func(*iam.User) ([]string, error) {
+				return []string{"deployer", "auditor"}, nil
+			},
+			ServiceAccountRolesFn: func(*iam.ServiceAccount) ([]string, error) {
+				return []string{"deployer"}, nil
+			},
+		}
+		tokenService = jwt.NewTokenService(signingKeyService, as, baseURL, time.Minute)
+	})
+
+	verifier := func() *jwt.Verifier {
+		keys, err := signingKeyService.PublishedSigningKeys()
+		Expect(err).NotTo(HaveOccurred())
+		set, err := jwt.NewKeySet(keys)
+		Expect(err).NotTo(HaveOccurred())
+		return jwt.NewVerifier(issuer, set)
+	}
+
+	issue := func() string {
+		token, err := tokenService.IssueAccessToken(authenticated, "client", "openid")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(token.TokenType).To(Equal("Bearer"))
+		return token.Token
+	}
+
+	DescribeTable("should issue tokens carrying tenant, username and roles",
+		func(algorithm iam.SigningAlgorithm) {
+			signingKeyService.RotateSigningKey(algorithm)
+			claims, err := verifier().Verify(issue())
+			Expect(err).NotTo(HaveOccurred())
+			Expect(claims.Issuer).To(Equal(issuer))
+			Expect(claims.TenantID).To(Equal(iam.TenantID("tenant")))
+			Expect(claims.Subject).To(Equal("user:jdoe"))
+			Expect(claims.TokenUse).To(Equal(jwt.AccessTokenUse))
+			Expect(claims.Username).To(Equal("jdoe"))
+			Expect(claims.ClientID).To(Equal("client"))
+			Expect(claims.Scope).To(Equal("openid"))
+			Expect(claims.Roles).To(ConsistOf("deployer", "auditor"))
+			Expect(claims.HasRole("deployer")).To(BeTrue())
+			Expect(claims.ExpiresAt).To(BeNumerically("~", time.Now().Add(time.Minute).Unix(), 1))
+		},
+		Entry("RS256", iam.RS256),
+		Entry("ES256", iam.ES256),
+		Entry("EdDSA", iam.EdDSA),
+	)
+
+	It("should refuse incomplete authentications", func() {
+		signingKeyService.RotateSigningKey(iam.EdDSA)
+		_, err := tokenService.IssueAccessToken(&iam.Authentication{Status: iam.MFARequired}, "", "")
+		Expect(iam.ErrorCode(err)).To(Equal(iam.EUNAUTHORIZED))
+	})
+
+	It("should issue tokens to enabled service accounts only", func() {
+		signingKeyService.RotateSigningKey(iam.EdDSA)
+		account := &iam.ServiceAccount{TenantID: "tenant", Name: "ci", Enablement: iam.IndefiniteEnablement()}
+		token, err := tokenService.IssueServiceAccountAccessToken(account, "client", "")
+		Expect(err).NotTo(HaveOccurred())
+		claims, err := verifier().Verify(token.Token)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(claims.Subject).To(Equal("sa:ci"))
+		Expect(claims.Account).To(Equal("ci"))
+		Expect(claims.Username).To(BeEmpty())
+		Expect(claims.Roles).To(ConsistOf("deployer"))
+
+		account.Enablement = iam.Enablement{}
+		_, err = tokenService.IssueServiceAccountAccessToken(account, "client", "")
+		Expect(iam.ErrorCode(err)).To(Equal(iam.EUNAUTHORIZED))
+	})
+
+	It("should issue ID tokens for the client carrying the claims released by the scope", func() {
+		signingKeyService.RotateSigningKey(iam.RS256)
+		user.Person = &iam.Person{
+			FullName:           iam.FullName{FirstName: "John", LastName: "Doe"},
+			ContactInformation: iam.ContactInformation{EmailAddress: "jdoe@example.com", PrimaryTelephone: "+39 02 1234567"},
+		}
+		authTime := time.Now().Add(-time.Minute)
+		token, err := tokenService.IssueIDToken(&iam.IDTokenGrant{
+			User:     user,
+			ClientID: "client",
+			Scope:    "openid email",
+			Nonce:    "n-0S6_WzA2Mj",
+			AuthTime: authTime,
+		})
+		Expect(err).NotTo(HaveOccurred())
+		claims := new(jwt.IDClaims)
+		Expect(verifier().VerifyInto(token, claims)).To(Succeed())
+		Expect(claims.Issuer).To(Equal(issuer))
+		Expect(claims.Subject).To(Equal("jdoe"))
+		Expect(claims.Audience).To(Equal(jwt.Audience{"client"}))
+		Expect(claims.Nonce).To(Equal("n-0S6_WzA2Mj"))
+		Expect(claims.AuthTime).To(Equal(authTime.Unix()))
+		Expect(claims.Email).To(Equal("jdoe@example.com"))
+		Expect(claims.Name).To(BeEmpty())
+		Expect(claims.PhoneNumber).To(BeEmpty())
+	})
+
+	Describe("verifier", func() {
+		BeforeEach(func() {
+			signingKeyService.RotateSigningKey(iam.ES256)
+		})
+
+		It("should reject a tampered payload", func() {
+			parts := strings.Split(issue(), ".")
+			payload, _ := json.Marshal(&jwt.Claims{Issuer: issuer, Username: "admin", ExpiresAt: time.Now().Add(time.Hour).Unix()})
+			parts[1] = base64.RawURLEncoding.EncodeToString(payload)
+			_, err := verifier().Verify(strings.Join(parts, "."))
+			Expect(iam.ErrorCode(err)).To(Equal(iam.EUNAUTHORIZED))
+		})
+		It("should reject a downgraded algorithm", func() {
+			parts := strings.Split(issue(), ".")
+			header, _ := base64.RawURLEncoding.DecodeString(parts[0])
+			header = []byte(strings.Replace(string(header), `"ES256"`, `"none"`, 1))
+			parts[0] = base64.RawURLEncoding.EncodeToString(header)
+			_, err := verifier().Verify(parts[0] + "." + parts[1] + ".")
+			Expect(iam.ErrorCode(err)).To(Equal(iam.EUNAUTHORIZED))
+		})
+		It("should reject an expired token", func() {
+			tokenService = jwt.NewTokenService(signingKeyService, &mock.AuthorizationService{
+				UserRolesFn: func(*iam.User) ([]string, error) { return nil, nil },
+			}, baseURL, -time.Minute)
+			_, err := verifier().Verify(issue())
+			Expect(err).To(MatchError(ContainSubstring("expired")))
+		})
+		It("should reject another issuer", func() {
+			v := verifier()
+			v.Issuer = "https://other.example.com"
+			_, err := v.Verify(issue())
+			Expect(iam.ErrorCode(err)).To(Equal(iam.EUNAUTHORIZED))
+		})
+		It("should check the audience when required", func() {
+			v := verifier()
+			v.Audience = "client"
+			claims, err := v.Verify(issue())
+			Expect(err).NotTo(HaveOccurred())
+			Expect(claims.Audience).To(Equal(jwt.Audience{"client"}))
+			v.Audience = "other"
+			_, err = v.Verify(issue())
+			Expect(iam.ErrorCode(err)).To(Equal(iam.EUNAUTHORIZED))
+		})
+		DescribeTable("should reject tokens whose subject is not the one of their principal",
+			func(claims *jwt.Claims) {
+				key, err := signingKeyService.ActiveSigningKey()
+				Expect(err).NotTo(HaveOccurred())
+				claims.Issuer = issuer
+				claims.ExpiresAt = time.Now().Add(time.Minute).Unix()
+				token, err := jwt.Sign(claims, key)
+				Expect(err).NotTo(HaveOccurred())
+				_, err = verifier().Verify(token)
+				Expect(iam.ErrorCode(err)).To(Equal(iam.EUNAUTHORIZED))
+			},
+			Entry("bare username", &jwt.Claims{TokenUse: "access", Subject: "jdoe", Username: "jdoe"}),
+			Entry("service account named as a user", &jwt.Claims{TokenUse: "access", Subject: "user:jdoe", Account: "jdoe"}),
+			Entry("both principals", &jwt.Claims{TokenUse: "access", Subject: "user:jdoe", Username: "jdoe", Account: "jdoe"}),
+			Entry("no principal", &jwt.Claims{TokenUse: "access", Subject: "user:jdoe"}),
+			Entry("no token use", &jwt.Claims{Subject: "user:jdoe", Username: "jdoe"}),
+		)
+		It("should reject ID tokens as access tokens", func() {
+			token, err := tokenService.IssueIDToken(&iam.IDTokenGrant{User: user, ClientID: "client", Scope: "openid"})
+			Expect(err).NotTo(HaveOccurred())
+			_, err = verifier().Verify(token)
+			Expect(iam.ErrorCode(err)).To(Equal(iam.EUNAUTHORIZED))
+		})
+		It("should verify tokens of a retiring key until it is retired", func() {
+			token := issue()
+			signingKeyService.RotateSigningKey(iam.RS256)
+			Expect(verifier().Verify(token)).NotTo(BeNil())
+			Expect(signingKeyService.RetireSigningKeys()).To(Succeed())
+			_, err := verifier().Verify(token)
+			Expect(iam.ErrorCode(err)).To(Equal(iam.EUNAUTHORIZED))
+		})
+	})
+
+	Describe("remote key set", func() {
+		var (
+			server  *httptest.Server
+			fetches int
+		)
+
+		BeforeEach(func() {
+			fetches = 0
+			server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				fetches++
+				keys, _ := signingKeyService.PublishedSigningKeys()
+				set, _ := jwt.NewKeySet(keys)
+				json.NewEncoder(w).Encode(set)
+			}))
+		})
+
+		AfterEach(func() {
+			server.Close()
+		})
+
+		It("should fetch the published keys once", func() {
+			signingKeyService.RotateSigningKey(iam.EdDSA)
+			v := jwt.NewVerifier(issuer, jwt.NewRemoteKeySet(server.URL))
+			for i := 0; i < 3; i++ {
+				_, err := v.Verify(issue())
+				Expect(err).NotTo(HaveOccurred())
+			}
+			Expect(fetches).To(Equal(1))
+		})
+		It("should not fetch again for unknown keys right after a fetch", func() {
+			signingKeyService.RotateSigningKey(iam.EdDSA)
+			v := jwt.NewVerifier(issuer, jwt.NewRemoteKeySet(server.URL))
+			v.Verify(issue())
+			signingKeyService.RotateSigningKey(iam.EdDSA)
+			_, err := v.Verify(issue())
+			Expect(iam.ErrorCode(err)).To(Equal(iam.EUNAUTHORIZED))
+			Expect(fetches).To(Equal(1))
+		})
+	})
+})
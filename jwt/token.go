@@ -0,0 +1,147 @@
+package jwt
+
+import (
+	"crypto/rand"
+	"strings"
+	"time"
+
+	"github.com/maurofran/iam"
+)
+
+// jtiLength is the number of random bytes of token ids.
+const jtiLength = 16
+
+// Issuer will return the issuer of the tokens of the tenant, each tenant being its own issuer under the base URL.
+func Issuer(baseURL string, tenantID iam.TenantID) string {
+	return strings.TrimSuffix(baseURL, "/") + "/" + string(tenantID)
+}
+
+// NewTokenService will create a new token service signing tokens valid for ttl with the active key, issued by
+// the tenant issuer under supplied base URL.
+func NewTokenService(
+	signingKeyService iam.SigningKeyService,
+	authorizationService iam.AuthorizationService,
+	baseURL string,
+	ttl time.Duration,
+) iam.TokenService {
+	return &tokenService{
+		signingKeyService:    signingKeyService,
+		authorizationService: authorizationService,
+		baseURL:              baseURL,
+		ttl:                  ttl,
+	}
+}
+
+type tokenService struct {
+	signingKeyService    iam.SigningKeyService
+	authorizationService iam.AuthorizationService
+	baseURL              string
+	ttl                  time.Duration
+}
+
+// IssueAccessToken will sign an access token for the user of a complete authentication, carrying its roles.
+func (s *tokenService) IssueAccessToken(authentication *iam.Authentication, clientID, scope string) (*iam.AccessToken, error) {
+	const op = "IssueAccessToken"
+	if authentication == nil || !authentication.IsComplete() {
+		return nil, &iam.Error{Code: iam.EUNAUTHORIZED, Message: "Authentication is not complete.", Op: op}
+	}
+	user := authentication.User
+	roles, err := s.authorizationService.UserRoles(user)
+	if err != nil {
+		return nil, err
+	}
+	return s.issue(op, &Claims{
+		Subject:  UserSubject(user.Username),
+		TenantID: user.TenantID,
+		ClientID: clientID,
+		Username: user.Username,
+		Scope:    scope,
+		Roles:    roles,
+	})
+}
+
+// IssueServiceAccountAccessToken will sign an access token for an enabled service account, carrying its roles.
+func (s *tokenService) IssueServiceAccountAccessToken(account *iam.ServiceAccount, clientID, scope string) (*iam.AccessToken, error) {
+	const op = "IssueServiceAccountAccessToken"
+	if account == nil || !account.IsEnabled() {
+		return nil, &iam.Error{Code: iam.EUNAUTHORIZED, Message: "Service account is not enabled.", Op: op}
+	}
+	roles, err := s.authorizationService.ServiceAccountRoles(account)
+	if err != nil {
+		return nil, err
+	}
+	return s.issue(op, &Claims{
+		Subject:  ServiceAccountSubject(account.Name),
+		TenantID: account.TenantID,
+		ClientID: clientID,
+		Account:  account.Name,
+		Scope:    scope,
+		Roles:    roles,
+	})
+}
+
+// IssueIDToken will sign an ID token for the client, carrying the claims about the user its scope releases.
+func (s *tokenService) IssueIDToken(grant *iam.IDTokenGrant) (string, error) {
+	const op = "IssueIDToken"
+	if grant.User == nil || !grant.User.IsEnabled() {
+		return "", &iam.Error{Code: iam.EUNAUTHORIZED, Message: "User is not enabled.", Op: op}
+	}
+	key, err := s.signingKeyService.ActiveSigningKey()
+	if err != nil {
+		return "", err
+	}
+	now := time.Now()
+	claims := &IDClaims{
+		UserInfo:        *iam.NewUserInfo(grant.User, grant.Scope),
+		Issuer:          Issuer(s.baseURL, grant.User.TenantID),
+		Audience:        Audience{grant.ClientID},
+		ExpiresAt:       now.Add(s.ttl).Unix(),
+		IssuedAt:        now.Unix(),
+		Nonce:           grant.Nonce,
+		AuthorizedParty: grant.ClientID,
+	}
+	if !grant.AuthTime.IsZero() {
+		claims.AuthTime = grant.AuthTime.Unix()
+	}
+	return Sign(claims, key)
+}
+
+// issue will sign an access token with the active key, the client it is issued to being its audience.
+func (s *tokenService) issue(op string, claims *Claims) (*iam.AccessToken, error) {
+	key, err := s.signingKeyService.ActiveSigningKey()
+	if err != nil {
+		return nil, err
+	}
+	id, err := newID(op)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	expiresAt := now.Add(s.ttl)
+	claims.Issuer = Issuer(s.baseURL, claims.TenantID)
+	if claims.ClientID != "" {
+		claims.Audience = Audience{claims.ClientID}
+	}
+	claims.IssuedAt = now.Unix()
+	claims.ExpiresAt = expiresAt.Unix()
+	claims.ID = id
+	claims.TokenUse = AccessTokenUse
+	token, err := Sign(claims, key)
+	if err != nil {
+		return nil, err
+	}
+	return &iam.AccessToken{Token: token, TokenType: iam.BearerTokenType, ExpiresAt: expiresAt}, nil
+}
+
+func newID(op string) (string, error) {
+	b := make([]byte, jtiLength)
+	if _, err := rand.Read(b); err != nil {
+		return "", &iam.Error{
+			Code:    iam.EINTERNAL,
+			Message: "An unexpected error occurred while generating token id.",
+			Op:      op,
+			Err:     err,
+		}
+	}
+	return b64.EncodeToString(b), nil
+}
@@ -0,0 +1,76 @@
+package jwt
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/maurofran/iam"
+)
+
+// defaultLeeway is the default clock skew tolerated when checking token times.
+const defaultLeeway = 30 * time.Second
+
+// Verifier is the verifier of the access tokens issued by the identity and access management service, meant to be
+// embedded by the services consuming them. Audience is checked only when not empty, the access token use always.
+type Verifier struct {
+	Issuer   string
+	Audience string
+	Keys     KeySource
+	Leeway   time.Duration
+}
+
+// NewVerifier will create a new verifier for the tokens of supplied issuer, checked with the keys of the source.
+func NewVerifier(issuer string, keys KeySource) *Verifier {
+	return &Verifier{Issuer: issuer, Keys: keys, Leeway: defaultLeeway}
+}
+
+// Verify will check the signature, issuer, audience, use, principal and validity time of the access token,
+// returning its claims.
+func (v *Verifier) Verify(token string) (*Claims, error) {
+	claims := new(Claims)
+	if err := v.VerifyInto(token, claims); err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	switch {
+	case v.Issuer != "" && claims.Issuer != v.Issuer:
+		return nil, invalidToken()
+	case v.Audience != "" && !claims.Audience.Contains(v.Audience):
+		return nil, invalidToken()
+	case claims.TokenUse != AccessTokenUse || !claims.hasPrincipalSubject():
+		return nil, invalidToken()
+	case claims.ExpiresAt == 0 || now.Add(-v.Leeway).Unix() >= claims.ExpiresAt:
+		return nil, &iam.Error{Code: iam.EUNAUTHORIZED, Message: "Token is expired.", Op: "Verify"}
+	case claims.NotBefore != 0 && now.Add(v.Leeway).Unix() < claims.NotBefore:
+		return nil, invalidToken()
+	}
+	return claims, nil
+}
+
+// VerifyInto will check the signature of the token, decoding its payload into supplied claims.
+// Checking the claims is up to the caller.
+func (v *Verifier) VerifyInto(token string, claims interface{}) error {
+	h, input, payload, signature, ok := split(token)
+	if !ok || h.KeyID == "" {
+		return invalidToken()
+	}
+	jwk, err := v.Keys.Key(h.KeyID)
+	if err != nil {
+		return err
+	}
+	if jwk == nil || jwk.Algorithm != h.Algorithm {
+		return invalidToken()
+	}
+	public, err := jwk.PublicKey()
+	if err != nil || !verify(h.Algorithm, public, []byte(input), signature) {
+		return invalidToken()
+	}
+	if err := json.Unmarshal(payload, claims); err != nil {
+		return invalidToken()
+	}
+	return nil
+}
+
+func invalidToken() error {
+	return &iam.Error{Code: iam.EUNAUTHORIZED, Message: "Invalid token.", Op: "Verify"}
+}
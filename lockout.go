@@ -0,0 +1,170 @@
+package iam
+
+import "time"
+
+// LockoutPolicy is the value object holding the brute force protection rules of a tenant.
+// A user is locked out after MaxFailedAttempts consecutive failed authentications, for Duration the first time
+// and twice as long for each following lockout, up to MaxDuration. A zero MaxFailedAttempts disables lockouts.
+type LockoutPolicy struct {
+	MaxFailedAttempts int           `bson:"maxFailedAttempts"`
+	Duration          time.Duration `bson:"duration"`
+	MaxDuration       time.Duration `bson:"maxDuration"`
+}
+
+// DefaultLockoutPolicy will return the lockout policy applied to new tenants.
+func DefaultLockoutPolicy() LockoutPolicy {
+	return LockoutPolicy{
+		MaxFailedAttempts: 5,
+		Duration:          5 * time.Minute,
+		MaxDuration:       time.Hour,
+	}
+}
+
+// duration will return the duration of the nth consecutive lockout.
+func (p LockoutPolicy) duration(lockouts int) time.Duration {
+	d := p.Duration
+	for i := 1; i < lockouts && (p.MaxDuration <= 0 || d < p.MaxDuration); i++ {
+		d *= 2
+	}
+	if p.MaxDuration > 0 && d > p.MaxDuration {
+		return p.MaxDuration
+	}
+	return d
+}
+
+// IsLockedOut will check if the user is locked out.
+func (u *User) IsLockedOut() bool {
+	return u.LockedUntil.After(time.Now())
+}
+
+// FailAuthentication will record the count of consecutive failed authentications,
+// locking the user out when it reaches the policy threshold.
+func (u *User) FailAuthentication(attempts int, policy LockoutPolicy) Events {
+	u.FailedAttempts = attempts
+	if policy.MaxFailedAttempts <= 0 || attempts < policy.MaxFailedAttempts {
+		return nil
+	}
+	u.Lockouts++
+	u.FailedAttempts = 0
+	u.LockedUntil = time.Now().Add(policy.duration(u.Lockouts))
+	return Events{EventWithPayload(&UserLockedOut{
+		TenantID:    u.TenantID,
+		Username:    u.Username,
+		LockedUntil: u.LockedUntil,
+	})}
+}
+
+// ExpireLockout will unlock the user when the lockout elapsed.
+// Consecutive lockouts are still counted, so that the next one lasts longer.
+func (u *User) ExpireLockout() Events {
+	if u.LockedUntil.IsZero() || u.IsLockedOut() {
+		return nil
+	}
+	u.LockedUntil = time.Time{}
+	return Events{EventWithPayload(&UserUnlocked{TenantID: u.TenantID, Username: u.Username})}
+}
+
+// Unlock will unlock the user, resetting failed authentications and lockouts.
+func (u *User) Unlock() Events {
+	locked := !u.LockedUntil.IsZero()
+	u.FailedAttempts = 0
+	u.Lockouts = 0
+	u.LockedUntil = time.Time{}
+	if !locked {
+		return nil
+	}
+	return Events{EventWithPayload(&UserUnlocked{TenantID: u.TenantID, Username: u.Username})}
+}
+
+// resetFailedAuthentications will forget failed authentications and lockouts after a successful one,
+// returning true if anything was reset.
+func (u *User) resetFailedAuthentications() bool {
+	if u.FailedAttempts == 0 && u.Lockouts == 0 {
+		return false
+	}
+	u.FailedAttempts = 0
+	u.Lockouts = 0
+	return true
+}
+
+// UserLockedOut is the event raised when a user is locked out after repeated failed authentications.
+type UserLockedOut struct {
+	TenantID    TenantID
+	Username    string
+	LockedUntil time.Time
+}
+
+// UserUnlocked is the event raised when a user lockout ends or is lifted.
+type UserUnlocked struct {
+	TenantID TenantID
+	Username string
+}
+
+// LockoutService is the service lifting user lockouts.
+type LockoutService interface {
+	UnlockUser(tenantID TenantID, username string) error
+}
+
+// NewLockoutService will create a new lockout service backed by supplied repositories.
+func NewLockoutService(
+	tenantRepository TenantRepository,
+	userRepository UserRepository,
+	eventPublisher EventPublisher,
+) LockoutService {
+	return &lockoutService{
+		tenantRepository: tenantRepository,
+		userRepository:   userRepository,
+		eventPublisher:   eventPublisher,
+	}
+}
+
+type lockoutService struct {
+	tenantRepository TenantRepository
+	userRepository   UserRepository
+	eventPublisher   EventPublisher
+}
+
+// UnlockUser will unlock the user of the tenant, as an administrative operation.
+func (s *lockoutService) UnlockUser(tenantID TenantID, username string) error {
+	const op = "UnlockUser"
+	tenant, err := s.tenantRepository.TenantOfID(tenantID)
+	if err != nil {
+		return &Error{
+			Code:    EINTERNAL,
+			Message: "An unexpected error occurred while retrieving tenant.",
+			Op:      op,
+			Err:     err,
+		}
+	}
+	if tenant == nil {
+		return &Error{Code: ENOTFOUND, Message: "Tenant not found.", Op: op}
+	}
+	if err := tenant.assertActive(op); err != nil {
+		return err
+	}
+	user, err := s.userRepository.UserWithUsername(tenantID, username)
+	if err != nil {
+		return &Error{
+			Code:    EINTERNAL,
+			Message: "An unexpected error occurred while retrieving user.",
+			Op:      op,
+			Err:     err,
+		}
+	}
+	if user == nil {
+		return &Error{Code: ENOTFOUND, Message: "User not found.", Op: op}
+	}
+	events := user.Unlock()
+	if err := s.userRepository.UpdateLockout(user); err != nil {
+		return &Error{
+			Code:    EINTERNAL,
+			Message: "An unexpected error occurred while updating user.",
+			Op:      op,
+			Err:     err,
+		}
+	}
+	if len(events) == 0 {
+		return nil
+	}
+	return s.eventPublisher.Publish(events)
+}
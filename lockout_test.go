@@ -0,0 +1,112 @@
+package iam_test
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/maurofran/iam"
+	"github.com/maurofran/iam/mock"
+)
+
+var _ = Describe("User lockout", func() {
+	var (
+		u      *User
+		policy LockoutPolicy
+	)
+
+	BeforeEach(func() {
+		u = &User{TenantID: "tenant", Username: "jdoe"}
+		policy = LockoutPolicy{MaxFailedAttempts: 3, Duration: time.Minute, MaxDuration: 3 * time.Minute}
+	})
+
+	It("should double the lockout duration up to the maximum", func() {
+		for _, expected := range []time.Duration{time.Minute, 2 * time.Minute, 3 * time.Minute, 3 * time.Minute} {
+			u.LockedUntil = time.Time{}
+			events := u.FailAuthentication(3, policy)
+			Expect(events).To(HaveLen(1))
+			Expect(u.LockedUntil).To(BeTemporally("~", time.Now().Add(expected), time.Second))
+		}
+		Expect(u.Lockouts).To(Equal(4))
+	})
+
+	It("should keep counting lockouts after the cooldown", func() {
+		u.FailAuthentication(3, policy)
+		u.LockedUntil = time.Now().Add(-time.Second)
+		Expect(u.ExpireLockout()).To(HaveLen(1))
+		Expect(u.Lockouts).To(Equal(1))
+		Expect(u.ExpireLockout()).To(BeEmpty())
+	})
+
+	Describe("#Unlock", func() {
+		It("should reset failures and lockouts", func() {
+			u.FailAuthentication(3, policy)
+			events := u.Unlock()
+			Expect(events).To(HaveLen(1))
+			Expect(events[0].Type).To(Equal("UserUnlocked"))
+			Expect(u.IsLockedOut()).To(BeFalse())
+			Expect(u.Lockouts).To(BeZero())
+		})
+		It("should not raise events for users not locked out", func() {
+			u.FailAuthentication(1, policy)
+			Expect(u.Unlock()).To(BeEmpty())
+			Expect(u.FailedAttempts).To(BeZero())
+		})
+	})
+})
+
+var _ = Describe("Lockout service", func() {
+	var (
+		tenant  *Tenant
+		user    *User
+		ur      *mock.UserRepository
+		events  Events
+		service LockoutService
+	)
+
+	BeforeEach(func() {
+		tenant = &Tenant{ID: "tenant", Active: true}
+		user = &User{TenantID: tenant.ID, Username: "jdoe", Lockouts: 2, LockedUntil: time.Now().Add(time.Hour)}
+		tr := &mock.TenantRepository{
+			TenantOfIDFn: func(TenantID) (*Tenant, error) {
+				return tenant, nil
+			},
+		}
+		ur = &mock.UserRepository{
+			UserWithUsernameFn: func(_ TenantID, username string) (*User, error) {
+				if username == user.Username {
+					return user, nil
+				}
+				return nil, nil
+			},
+			UpdateLockoutFn: func(*User) error {
+				return nil
+			},
+		}
+		events = nil
+		ep := &mock.EventPublisher{
+			PublishFn: func(ee Events) error {
+				events = append(events, ee...)
+				return nil
+			},
+		}
+		service = NewLockoutService(tr, ur, ep)
+	})
+
+	It("should unlock the user", func() {
+		Expect(service.UnlockUser(tenant.ID, "jdoe")).To(Succeed())
+		Expect(user.IsLockedOut()).To(BeFalse())
+		Expect(ur.UpdateLockoutInvoked).To(BeTrue())
+		Expect(events).To(HaveLen(1))
+	})
+
+	It("should reject unknown users", func() {
+		Expect(ErrorCode(service.UnlockUser(tenant.ID, "unknown"))).To(Equal(ENOTFOUND))
+	})
+
+	It("should reject inactive tenants", func() {
+		tenant.Active = false
+		Expect(ErrorCode(service.UnlockUser(tenant.ID, "jdoe"))).To(Equal(ECONFLICT))
+	})
+})
@@ -0,0 +1,2 @@
+// Package memory will hold the in-memory implementations of services, meant for tests and development.
+package memory
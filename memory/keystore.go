@@ -0,0 +1,55 @@
+package memory
+
+import (
+	"sync"
+
+	"github.com/maurofran/iam"
+)
+
+// KeyStore is the key store keeping signing keys in memory, safe for concurrent use.
+type KeyStore struct {
+	mu   sync.Mutex
+	keys []iam.SigningKey
+}
+
+// NewKeyStore will create a new empty in-memory key store.
+func NewKeyStore() *KeyStore {
+	return &KeyStore{}
+}
+
+// Add will store a copy of supplied key.
+func (s *KeyStore) Add(key *iam.SigningKey) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.keys = append(s.keys, *key)
+	return nil
+}
+
+// Update will replace the stored key with the same id.
+func (s *KeyStore) Update(key *iam.SigningKey) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := range s.keys {
+		if s.keys[i].ID == key.ID {
+			s.keys[i] = *key
+		}
+	}
+	return nil
+}
+
+// SigningKeysInState will return copies of the keys in any of supplied states, oldest first.
+func (s *KeyStore) SigningKeysInState(states ...iam.SigningKeyState) (iam.SigningKeys, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var keys iam.SigningKeys
+	for _, k := range s.keys {
+		for _, state := range states {
+			if k.State == state {
+				k := k
+				keys = append(keys, &k)
+				break
+			}
+		}
+	}
+	return keys, nil
+}
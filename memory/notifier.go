@@ -0,0 +1,55 @@
+package memory
+
+import (
+	"sync"
+
+	"github.com/maurofran/iam"
+)
+
+// Notifier is the notifier keeping delivered notifications in memory, safe for concurrent use.
+type Notifier struct {
+	mu            sync.Mutex
+	notifications []iam.Notification
+}
+
+// NewNotifier will create a new empty in-memory notifier.
+func NewNotifier() *Notifier {
+	return &Notifier{}
+}
+
+// Notify will record supplied notification.
+func (n *Notifier) Notify(notification iam.Notification) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.notifications = append(n.notifications, notification)
+	return nil
+}
+
+// Notifications will return the notifications delivered to supplied email address, oldest first.
+func (n *Notifier) Notifications(emailAddress iam.EmailAddress) []iam.Notification {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	var nn []iam.Notification
+	for _, notification := range n.notifications {
+		if notification.EmailAddress == emailAddress {
+			nn = append(nn, notification)
+		}
+	}
+	return nn
+}
+
+// Last will return the last notification delivered to supplied email address, false if none.
+func (n *Notifier) Last(emailAddress iam.EmailAddress) (iam.Notification, bool) {
+	nn := n.Notifications(emailAddress)
+	if len(nn) == 0 {
+		return iam.Notification{}, false
+	}
+	return nn[len(nn)-1], true
+}
+
+// Reset will forget every notification delivered.
+func (n *Notifier) Reset() {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.notifications = nil
+}
@@ -0,0 +1,320 @@
+package iam
+
+import "time"
+
+// recoveryCodeCount is the number of recovery codes generated on enrollment.
+// recoveryCodeLength is the number of characters of a recovery code, split in two halves by a dash.
+// secondFactorChallengeTTL is the time allowed to complete an authentication with the second factor.
+const (
+	recoveryCodeCount        = 10
+	recoveryCodeLength       = 10
+	secondFactorChallengeTTL = 5 * time.Minute
+)
+
+var recoveryCodeAlphabet = []rune("abcdefghjkmnpqrstuvwxyz23456789")
+
+// SecondFactor is the value object holding the time based one time password (TOTP) enrollment of a user.
+// RecoveryCodes are hashed with the password hasher and removed once used. LastUsedStep is the time step
+// of the last accepted code, so that codes cannot be replayed. Challenge is the hash of the pending
+// second factor challenge, if any.
+type SecondFactor struct {
+	Secret             string    `bson:"secret"`
+	Confirmed          bool      `bson:"confirmed"`
+	LastUsedStep       int64     `bson:"lastUsedStep"`
+	RecoveryCodes      []string  `bson:"recoveryCodes"`
+	Challenge          string    `bson:"challenge,omitempty"`
+	ChallengeExpiresAt time.Time `bson:"challengeExpiresAt,omitempty"`
+}
+
+// TOTPProvisioning is the value object holding what a user needs to set up an authenticator app.
+// It is returned once on enrollment and never stored as such.
+type TOTPProvisioning struct {
+	Secret        string
+	URI           string
+	RecoveryCodes []string
+}
+
+// HasMFA will check if the user confirmed the enrollment of a second factor.
+func (u *User) HasMFA() bool {
+	return u.SecondFactor != nil && u.SecondFactor.Confirmed
+}
+
+// EnrollTOTP will generate a new TOTP secret and recovery codes for the user, to be confirmed with a first code.
+// Issuer is the name shown by authenticator apps, usually the tenant name.
+func (u *User) EnrollTOTP(issuer string) (*TOTPProvisioning, Events, error) {
+	const op = "EnrollTOTP"
+	if u.HasMFA() {
+		return nil, nil, &Error{Code: ECONFLICT, Message: "Multi-factor authentication is already enabled.", Op: op}
+	}
+	secret, err := newTOTPSecret(op)
+	if err != nil {
+		return nil, nil, err
+	}
+	codes := make([]string, recoveryCodeCount)
+	hashed := make([]string, recoveryCodeCount)
+	for i := range codes {
+		if codes[i], err = newRecoveryCode(); err != nil {
+			return nil, nil, &Error{
+				Code:    EINTERNAL,
+				Message: "An unexpected error occurred while generating recovery codes.",
+				Op:      op,
+				Err:     err,
+			}
+		}
+		if hashed[i], err = encrypt(codes[i]); err != nil {
+			return nil, nil, err
+		}
+	}
+	u.SecondFactor = &SecondFactor{Secret: secret, RecoveryCodes: hashed}
+	provisioning := &TOTPProvisioning{
+		Secret:        secret,
+		URI:           totpURI(issuer, u.Username, secret),
+		RecoveryCodes: codes,
+	}
+	return provisioning, Events{EventWithPayload(&UserTOTPEnrollmentStarted{
+		TenantID: u.TenantID,
+		Username: u.Username,
+	})}, nil
+}
+
+func newRecoveryCode() (string, error) {
+	code := make([]rune, recoveryCodeLength)
+	for i := range code {
+		n, err := randomInt(len(recoveryCodeAlphabet))
+		if err != nil {
+			return "", err
+		}
+		code[i] = recoveryCodeAlphabet[n]
+	}
+	return string(code[:recoveryCodeLength/2]) + "-" + string(code[recoveryCodeLength/2:]), nil
+}
+
+// ConfirmTOTP will enable the pending TOTP enrollment, proving the authenticator app with a first code.
+func (u *User) ConfirmTOTP(code string) (Events, error) {
+	const op = "ConfirmTOTP"
+	if u.SecondFactor == nil {
+		return nil, &Error{Code: ECONFLICT, Message: "No multi-factor enrollment is pending.", Op: op}
+	}
+	if u.SecondFactor.Confirmed {
+		return nil, &Error{Code: ECONFLICT, Message: "Multi-factor authentication is already enabled.", Op: op}
+	}
+	step, ok := verifyTOTP(u.SecondFactor.Secret, code, u.SecondFactor.LastUsedStep, time.Now())
+	if !ok {
+		return nil, &Error{Code: EINVALID, Message: "Invalid second factor code.", Op: op}
+	}
+	u.SecondFactor.LastUsedStep = step
+	u.SecondFactor.Confirmed = true
+	return Events{EventWithPayload(&UserMFAEnabled{TenantID: u.TenantID, Username: u.Username})}, nil
+}
+
+// DisableMFA will remove the second factor of the user.
+func (u *User) DisableMFA() Events {
+	if u.SecondFactor == nil {
+		return nil
+	}
+	confirmed := u.SecondFactor.Confirmed
+	u.SecondFactor = nil
+	if !confirmed {
+		return nil
+	}
+	return Events{EventWithPayload(&UserMFADisabled{TenantID: u.TenantID, Username: u.Username})}
+}
+
+// ChallengeSecondFactor will issue a short lived challenge, to be completed with a second factor code.
+// Only the hash of the returned challenge is kept.
+func (u *User) ChallengeSecondFactor() (string, error) {
+	const op = "ChallengeSecondFactor"
+	if !u.HasMFA() {
+		return "", &Error{Code: ECONFLICT, Message: "Multi-factor authentication is not enabled.", Op: op}
+	}
+	challenge, err := newToken(op)
+	if err != nil {
+		return "", err
+	}
+	u.SecondFactor.Challenge = hashToken(challenge)
+	u.SecondFactor.ChallengeExpiresAt = time.Now().Add(secondFactorChallengeTTL)
+	return challenge, nil
+}
+
+// HasSecondFactorChallenge will check if supplied challenge is pending and not expired.
+func (u *User) HasSecondFactorChallenge(challenge string) bool {
+	return u.HasMFA() &&
+		u.SecondFactor.ChallengeExpiresAt.After(time.Now()) &&
+		tokenMatches(challenge, u.SecondFactor.Challenge)
+}
+
+// VerifySecondFactor will check supplied TOTP or recovery code, clearing the pending challenge when it matches.
+// Recovery codes are consumed once used.
+func (u *User) VerifySecondFactor(code string) (Events, bool) {
+	if !u.HasMFA() {
+		return nil, false
+	}
+	var events Events
+	if step, ok := verifyTOTP(u.SecondFactor.Secret, code, u.SecondFactor.LastUsedStep, time.Now()); ok {
+		u.SecondFactor.LastUsedStep = step
+	} else if i := u.recoveryCode(code); i >= 0 {
+		codes := u.SecondFactor.RecoveryCodes
+		u.SecondFactor.RecoveryCodes = append(codes[:i:i], codes[i+1:]...)
+		events = append(events, EventWithPayload(&UserRecoveryCodeRedeemed{
+			TenantID:  u.TenantID,
+			Username:  u.Username,
+			Remaining: len(u.SecondFactor.RecoveryCodes),
+		}))
+	} else {
+		return nil, false
+	}
+	u.SecondFactor.Challenge = ""
+	u.SecondFactor.ChallengeExpiresAt = time.Time{}
+	return events, true
+}
+
+// recoveryCode will return the index of the recovery code matching supplied one, or -1.
+func (u *User) recoveryCode(code string) int {
+	if len(code) != recoveryCodeLength+1 {
+		return -1
+	}
+	for i, hashed := range u.SecondFactor.RecoveryCodes {
+		if matches(code, hashed) {
+			return i
+		}
+	}
+	return -1
+}
+
+// UserTOTPEnrollmentStarted is the event raised when a TOTP secret is generated for a user.
+type UserTOTPEnrollmentStarted struct {
+	TenantID TenantID
+	Username string
+}
+
+// UserMFAEnabled is the event raised when a user confirms the enrollment of a second factor.
+type UserMFAEnabled struct {
+	TenantID TenantID
+	Username string
+}
+
+// UserMFADisabled is the event raised when the second factor of a user is removed.
+type UserMFADisabled struct {
+	TenantID TenantID
+	Username string
+}
+
+// UserRecoveryCodeRedeemed is the event raised when a user authenticates with a recovery code.
+type UserRecoveryCodeRedeemed struct {
+	TenantID  TenantID
+	Username  string
+	Remaining int
+}
+
+// MFAService is the service managing the second factor of users.
+type MFAService interface {
+	EnrollTOTP(tenantID TenantID, username string) (*TOTPProvisioning, error)
+	ConfirmTOTP(tenantID TenantID, username, code string) error
+	DisableMFA(tenantID TenantID, username string) error
+}
+
+// NewMFAService will create a new multi-factor authentication service backed by supplied repositories.
+func NewMFAService(
+	tenantRepository TenantRepository,
+	userRepository UserRepository,
+	eventPublisher EventPublisher,
+) MFAService {
+	return &mfaService{
+		tenantRepository: tenantRepository,
+		userRepository:   userRepository,
+		eventPublisher:   eventPublisher,
+	}
+}
+
+type mfaService struct {
+	tenantRepository TenantRepository
+	userRepository   UserRepository
+	eventPublisher   EventPublisher
+}
+
+// EnrollTOTP will start the TOTP enrollment of the user, using the tenant name as issuer.
+func (s *mfaService) EnrollTOTP(tenantID TenantID, username string) (*TOTPProvisioning, error) {
+	const op = "EnrollTOTP"
+	tenant, user, err := s.user(op, tenantID, username)
+	if err != nil {
+		return nil, err
+	}
+	provisioning, events, err := user.EnrollTOTP(tenant.Name)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.save(op, user, events); err != nil {
+		return nil, err
+	}
+	return provisioning, nil
+}
+
+// ConfirmTOTP will confirm the pending TOTP enrollment of the user.
+func (s *mfaService) ConfirmTOTP(tenantID TenantID, username, code string) error {
+	const op = "ConfirmTOTP"
+	_, user, err := s.user(op, tenantID, username)
+	if err != nil {
+		return err
+	}
+	events, err := user.ConfirmTOTP(code)
+	if err != nil {
+		return err
+	}
+	return s.save(op, user, events)
+}
+
+// DisableMFA will remove the second factor of the user.
+func (s *mfaService) DisableMFA(tenantID TenantID, username string) error {
+	const op = "DisableMFA"
+	_, user, err := s.user(op, tenantID, username)
+	if err != nil {
+		return err
+	}
+	return s.save(op, user, user.DisableMFA())
+}
+
+func (s *mfaService) user(op string, tenantID TenantID, username string) (*Tenant, *User, error) {
+	tenant, err := s.tenantRepository.TenantOfID(tenantID)
+	if err != nil {
+		return nil, nil, &Error{
+			Code:    EINTERNAL,
+			Message: "An unexpected error occurred while retrieving tenant.",
+			Op:      op,
+			Err:     err,
+		}
+	}
+	if tenant == nil {
+		return nil, nil, &Error{Code: ENOTFOUND, Message: "Tenant not found.", Op: op}
+	}
+	if err := tenant.assertActive(op); err != nil {
+		return nil, nil, err
+	}
+	user, err := s.userRepository.UserWithUsername(tenantID, username)
+	if err != nil {
+		return nil, nil, &Error{
+			Code:    EINTERNAL,
+			Message: "An unexpected error occurred while retrieving user.",
+			Op:      op,
+			Err:     err,
+		}
+	}
+	if user == nil {
+		return nil, nil, &Error{Code: ENOTFOUND, Message: "User not found.", Op: op}
+	}
+	return tenant, user, nil
+}
+
+func (s *mfaService) save(op string, user *User, events Events) error {
+	if err := s.userRepository.Update(user); err != nil {
+		return &Error{
+			Code:    EINTERNAL,
+			Message: "An unexpected error occurred while updating user.",
+			Op:      op,
+			Err:     err,
+		}
+	}
+	if len(events) == 0 {
+		return nil
+	}
+	return s.eventPublisher.Publish(events)
+}
@@ -0,0 +1,218 @@
+package iam_test
+
+import (
+	"net/url"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+
+	. "github.com/maurofran/iam"
+	"github.com/maurofran/iam/mock"
+)
+
+var _ = Describe("TOTP", func() {
+	// Test vectors of RFC 6238, truncated to 6 digits.
+	DescribeTable("GenerateTOTPCode",
+		func(unix int64, code string) {
+			Expect(GenerateTOTPCode("GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", time.Unix(unix, 0))).To(Equal(code))
+		},
+		Entry("59", int64(59), "287082"),
+		Entry("1111111109", int64(1111111109), "081804"),
+		Entry("1234567890", int64(1234567890), "005924"),
+		Entry("20000000000", int64(20000000000), "353130"),
+	)
+})
+
+var _ = Describe("User second factor", func() {
+	var (
+		u            *User
+		provisioning *TOTPProvisioning
+	)
+
+	code := func(offset time.Duration) string {
+		c, err := GenerateTOTPCode(provisioning.Secret, time.Now().Add(offset))
+		Expect(err).NotTo(HaveOccurred())
+		return c
+	}
+
+	BeforeEach(func() {
+		var err error
+		u = &User{TenantID: "tenant", Username: "jdoe"}
+		provisioning, _, err = u.EnrollTOTP("ACME Corp")
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	Describe("#EnrollTOTP", func() {
+		It("should return a provisioning URI", func() {
+			uri, err := url.Parse(provisioning.URI)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(uri.Scheme).To(Equal("otpauth"))
+			Expect(uri.Host).To(Equal("totp"))
+			Expect(uri.Path).To(Equal("/ACME Corp:jdoe"))
+			Expect(uri.Query().Get("secret")).To(Equal(provisioning.Secret))
+			Expect(uri.Query().Get("issuer")).To(Equal("ACME Corp"))
+		})
+		It("should store recovery codes hashed", func() {
+			Expect(provisioning.RecoveryCodes).To(HaveLen(10))
+			Expect(u.SecondFactor.RecoveryCodes).To(HaveLen(10))
+			Expect(u.SecondFactor.RecoveryCodes).NotTo(ContainElement(provisioning.RecoveryCodes[0]))
+		})
+		It("should not be enabled before confirmation", func() {
+			Expect(u.HasMFA()).To(BeFalse())
+		})
+		It("should reject a second enrollment once enabled", func() {
+			_, err := u.ConfirmTOTP(code(0))
+			Expect(err).NotTo(HaveOccurred())
+			_, _, err = u.EnrollTOTP("ACME Corp")
+			Expect(ErrorCode(err)).To(Equal(ECONFLICT))
+		})
+	})
+
+	Describe("#ConfirmTOTP", func() {
+		It("should enable the second factor", func() {
+			events, err := u.ConfirmTOTP(code(0))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(events[0].Type).To(Equal("UserMFAEnabled"))
+			Expect(u.HasMFA()).To(BeTrue())
+		})
+		It("should reject a wrong code", func() {
+			_, err := u.ConfirmTOTP("000000x")
+			Expect(ErrorCode(err)).To(Equal(EINVALID))
+		})
+	})
+
+	Describe("#VerifySecondFactor", func() {
+		BeforeEach(func() {
+			_, err := u.ConfirmTOTP(code(-30 * time.Second))
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("should accept codes within the drift window", func() {
+			_, ok := u.VerifySecondFactor(code(30 * time.Second))
+			Expect(ok).To(BeTrue())
+		})
+		It("should reject codes outside the drift window", func() {
+			_, ok := u.VerifySecondFactor(code(2 * time.Minute))
+			Expect(ok).To(BeFalse())
+		})
+		It("should reject replayed codes", func() {
+			c := code(0)
+			_, ok := u.VerifySecondFactor(c)
+			Expect(ok).To(BeTrue())
+			_, ok = u.VerifySecondFactor(c)
+			Expect(ok).To(BeFalse())
+		})
+		It("should accept a recovery code only once", func() {
+			events, ok := u.VerifySecondFactor(provisioning.RecoveryCodes[3])
+			Expect(ok).To(BeTrue())
+			Expect(events[0].Type).To(Equal("UserRecoveryCodeRedeemed"))
+			Expect(u.SecondFactor.RecoveryCodes).To(HaveLen(9))
+			_, ok = u.VerifySecondFactor(provisioning.RecoveryCodes[3])
+			Expect(ok).To(BeFalse())
+		})
+	})
+
+	Describe("#DisableMFA", func() {
+		It("should remove the second factor", func() {
+			u.ConfirmTOTP(code(0))
+			events := u.DisableMFA()
+			Expect(events[0].Type).To(Equal("UserMFADisabled"))
+			Expect(u.HasMFA()).To(BeFalse())
+		})
+	})
+})
+
+var _ = Describe("Authentication service with second factor", func() {
+	var (
+		tenant  *Tenant
+		user    *User
+		ur      *mock.UserRepository
+		secret  string
+		service AuthenticationService
+	)
+
+	BeforeEach(func() {
+		var err error
+		tenant = &Tenant{ID: "tenant", Active: true}
+		user, _, err = NewUser("tenant", "jdoe", "gV7#pLq2!wZx", nil, DefaultPasswordPolicy(), nil)
+		Expect(err).NotTo(HaveOccurred())
+		tr := &mock.TenantRepository{
+			TenantOfIDFn: func(TenantID) (*Tenant, error) {
+				return tenant, nil
+			},
+		}
+		ur = &mock.UserRepository{
+			UserWithUsernameFn: func(TenantID, string) (*User, error) {
+				return user, nil
+			},
+			UpdateFn: func(*User) error {
+				return nil
+			},
+			IncrementFailedAttemptsFn: func(TenantID, string) (int, error) {
+				return user.FailedAttempts + 1, nil
+			},
+			UpdateLockoutFn: func(*User) error {
+				return nil
+			},
+		}
+		ep := &mock.EventPublisher{
+			PublishFn: func(Events) error {
+				return nil
+			},
+		}
+		service = NewAuthenticationService(tr, ur, ep)
+	})
+
+	enroll := func() {
+		provisioning, _, err := user.EnrollTOTP("tenant")
+		Expect(err).NotTo(HaveOccurred())
+		secret = provisioning.Secret
+		code, _ := GenerateTOTPCode(secret, time.Now().Add(-30*time.Second))
+		_, err = user.ConfirmTOTP(code)
+		Expect(err).NotTo(HaveOccurred())
+	}
+
+	It("should challenge users with a second factor", func() {
+		enroll()
+		a, err := service.Authenticate(tenant.ID, "jdoe", "gV7#pLq2!wZx")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(a.Status).To(Equal(MFARequired))
+		Expect(a.User).To(BeNil())
+		Expect(a.Challenge).NotTo(BeEmpty())
+
+		code, _ := GenerateTOTPCode(secret, time.Now())
+		a, err = service.VerifySecondFactor(tenant.ID, "jdoe", a.Challenge, code)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(a.IsComplete()).To(BeTrue())
+		Expect(a.User).To(Equal(user))
+	})
+
+	It("should not accept a challenge twice", func() {
+		enroll()
+		a, _ := service.Authenticate(tenant.ID, "jdoe", "gV7#pLq2!wZx")
+		code, _ := GenerateTOTPCode(secret, time.Now())
+		_, err := service.VerifySecondFactor(tenant.ID, "jdoe", a.Challenge, code)
+		Expect(err).NotTo(HaveOccurred())
+		code, _ = GenerateTOTPCode(secret, time.Now().Add(30*time.Second))
+		_, err = service.VerifySecondFactor(tenant.ID, "jdoe", a.Challenge, code)
+		Expect(ErrorCode(err)).To(Equal(EUNAUTHORIZED))
+	})
+
+	It("should count wrong codes as failed authentications", func() {
+		enroll()
+		a, _ := service.Authenticate(tenant.ID, "jdoe", "gV7#pLq2!wZx")
+		_, err := service.VerifySecondFactor(tenant.ID, "jdoe", a.Challenge, "000000x")
+		Expect(ErrorCode(err)).To(Equal(EUNAUTHORIZED))
+		Expect(ur.IncrementFailedAttemptsInvoked).To(BeTrue())
+	})
+
+	It("should require an enrollment when the tenant requires it", func() {
+		tenant.MFARequired = true
+		a, err := service.Authenticate(tenant.ID, "jdoe", "gV7#pLq2!wZx")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(a.Status).To(Equal(MFAEnrollmentRequired))
+		Expect(a.IsComplete()).To(BeFalse())
+	})
+})
@@ -4,12 +4,20 @@ import "github.com/maurofran/iam"
 
 // AuthenticationService is the mock implementation of authentication service interface.
 type AuthenticationService struct {
-	AuthenticateFn      func(iam.TenantID, string, string) (*iam.User, error)
-	AuthenticateInvoked bool
+	AuthenticateFn            func(iam.TenantID, string, string) (*iam.Authentication, error)
+	AuthenticateInvoked       bool
+	VerifySecondFactorFn      func(iam.TenantID, string, string, string) (*iam.Authentication, error)
+	VerifySecondFactorInvoked bool
 }
 
 // Authenticate function mock the authenticate service call.
-func (a *AuthenticationService) Authenticate(tenantID iam.TenantID, username, password string) (*iam.User, error) {
+func (a *AuthenticationService) Authenticate(tenantID iam.TenantID, username, password string) (*iam.Authentication, error) {
 	a.AuthenticateInvoked = true
 	return a.AuthenticateFn(tenantID, username, password)
 }
+
+// VerifySecondFactor function mock the second factor verification service call.
+func (a *AuthenticationService) VerifySecondFactor(tenantID iam.TenantID, username, challenge, code string) (*iam.Authentication, error) {
+	a.VerifySecondFactorInvoked = true
+	return a.VerifySecondFactorFn(tenantID, username, challenge, code)
+}
@@ -4,10 +4,18 @@ import "github.com/maurofran/iam"
 
 // AuthorizationService is the mock authorization service implementaation.
 type AuthorizationService struct {
-	IsUsernameInRoleFn      func(iam.TenantID, string, string) (bool, error)
-	IsUsernameInRoleInvoked bool
-	IsUserInRoleFn          func(*iam.User, string) (bool, error)
-	IsUserInRoleInvoked     bool
+	IsUsernameInRoleFn                func(iam.TenantID, string, string) (bool, error)
+	IsUsernameInRoleInvoked           bool
+	IsUserInRoleFn                    func(*iam.User, string) (bool, error)
+	IsUserInRoleInvoked               bool
+	UserRolesFn                       func(*iam.User) ([]string, error)
+	UserRolesInvoked                  bool
+	IsServiceAccountNameInRoleFn      func(iam.TenantID, string, string) (bool, error)
+	IsServiceAccountNameInRoleInvoked bool
+	IsServiceAccountInRoleFn          func(*iam.ServiceAccount, string) (bool, error)
+	IsServiceAccountInRoleInvoked     bool
+	ServiceAccountRolesFn             func(*iam.ServiceAccount) ([]string, error)
+	ServiceAccountRolesInvoked        bool
 }
 
 // IsUsernameInRole is the mock implementation of service method.
@@ -21,3 +29,27 @@ func (a *AuthorizationService) IsUserInRole(user *iam.User, roleName string) (bo
 	a.IsUserInRoleInvoked = true
 	return a.IsUserInRoleFn(user, roleName)
 }
+
+// UserRoles is the mock implementation of service method.
+func (a *AuthorizationService) UserRoles(user *iam.User) ([]string, error) {
+	a.UserRolesInvoked = true
+	return a.UserRolesFn(user)
+}
+
+// IsServiceAccountNameInRole is the mock implementation of service method.
+func (a *AuthorizationService) IsServiceAccountNameInRole(tenantID iam.TenantID, name, roleName string) (bool, error) {
+	a.IsServiceAccountNameInRoleInvoked = true
+	return a.IsServiceAccountNameInRoleFn(tenantID, name, roleName)
+}
+
+// IsServiceAccountInRole is the mock implementation of service method.
+func (a *AuthorizationService) IsServiceAccountInRole(account *iam.ServiceAccount, roleName string) (bool, error) {
+	a.IsServiceAccountInRoleInvoked = true
+	return a.IsServiceAccountInRoleFn(account, roleName)
+}
+
+// ServiceAccountRoles is the mock implementation of service method.
+func (a *AuthorizationService) ServiceAccountRoles(account *iam.ServiceAccount) ([]string, error) {
+	a.ServiceAccountRolesInvoked = true
+	return a.ServiceAccountRolesFn(account)
+}
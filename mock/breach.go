@@ -0,0 +1,13 @@
+package mock
+
+// BreachedPasswordChecker is the mock breached password checker implementation.
+type BreachedPasswordChecker struct {
+	IsBreachedFn      func(string) (bool, error)
+	IsBreachedInvoked bool
+}
+
+// IsBreached is the mock implementation of checker method.
+func (c *BreachedPasswordChecker) IsBreached(password string) (bool, error) {
+	c.IsBreachedInvoked = true
+	return c.IsBreachedFn(password)
+}
@@ -0,0 +1,75 @@
+package mock
+
+import "github.com/maurofran/iam"
+
+// ClientRepository is the mock struct for client repository.
+type ClientRepository struct {
+	AddFn             func(*iam.Client) error
+	AddInvoked        bool
+	UpdateFn          func(*iam.Client) error
+	UpdateInvoked     bool
+	RemoveFn          func(*iam.Client) error
+	RemoveInvoked     bool
+	ClientOfIDFn      func(iam.TenantID, string) (*iam.Client, error)
+	ClientOfIDInvoked bool
+	AllClientsFn      func(iam.TenantID) (iam.Clients, error)
+	AllClientsInvoked bool
+}
+
+// Add is the mock method.
+func (r *ClientRepository) Add(client *iam.Client) error {
+	r.AddInvoked = true
+	return r.AddFn(client)
+}
+
+// Update is the mock method.
+func (r *ClientRepository) Update(client *iam.Client) error {
+	r.UpdateInvoked = true
+	return r.UpdateFn(client)
+}
+
+// Remove is the mock method.
+func (r *ClientRepository) Remove(client *iam.Client) error {
+	r.RemoveInvoked = true
+	return r.RemoveFn(client)
+}
+
+// ClientOfID is the mock method.
+func (r *ClientRepository) ClientOfID(tenantID iam.TenantID, clientID string) (*iam.Client, error) {
+	r.ClientOfIDInvoked = true
+	return r.ClientOfIDFn(tenantID, clientID)
+}
+
+// AllClients is the mock method.
+func (r *ClientRepository) AllClients(tenantID iam.TenantID) (iam.Clients, error) {
+	r.AllClientsInvoked = true
+	return r.AllClientsFn(tenantID)
+}
+
+// ClientService is the mock client service implementation.
+type ClientService struct {
+	RegisterClientFn            func(iam.TenantID, iam.ClientRegistration) (*iam.Client, string, error)
+	RegisterClientInvoked       bool
+	RotateClientSecretFn        func(iam.TenantID, string) (string, error)
+	RotateClientSecretInvoked   bool
+	RedefineRedirectURIsFn      func(iam.TenantID, string, []string) error
+	RedefineRedirectURIsInvoked bool
+}
+
+// RegisterClient is the mock implementation of service method.
+func (s *ClientService) RegisterClient(tenantID iam.TenantID, registration iam.ClientRegistration) (*iam.Client, string, error) {
+	s.RegisterClientInvoked = true
+	return s.RegisterClientFn(tenantID, registration)
+}
+
+// RotateClientSecret is the mock implementation of service method.
+func (s *ClientService) RotateClientSecret(tenantID iam.TenantID, clientID string) (string, error) {
+	s.RotateClientSecretInvoked = true
+	return s.RotateClientSecretFn(tenantID, clientID)
+}
+
+// RedefineRedirectURIs is the mock implementation of service method.
+func (s *ClientService) RedefineRedirectURIs(tenantID iam.TenantID, clientID string, redirectURIs []string) error {
+	s.RedefineRedirectURIsInvoked = true
+	return s.RedefineRedirectURIsFn(tenantID, clientID, redirectURIs)
+}
@@ -0,0 +1,15 @@
+package mock
+
+import "github.com/maurofran/iam"
+
+// EventPublisher is the mock event publisher implementation.
+type EventPublisher struct {
+	PublishFn      func(iam.Events) error
+	PublishInvoked bool
+}
+
+// Publish is the mock implementation of publisher method.
+func (p *EventPublisher) Publish(events iam.Events) error {
+	p.PublishInvoked = true
+	return p.PublishFn(events)
+}
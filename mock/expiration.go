@@ -0,0 +1,23 @@
+package mock
+
+import "github.com/maurofran/iam"
+
+// PasswordExpirationService is the mock password expiration service implementation.
+type PasswordExpirationService struct {
+	ExpirePasswordsFn         func(iam.TenantID) error
+	ExpirePasswordsInvoked    bool
+	ExpireAllPasswordsFn      func() error
+	ExpireAllPasswordsInvoked bool
+}
+
+// ExpirePasswords is the mock implementation of service method.
+func (s *PasswordExpirationService) ExpirePasswords(tenantID iam.TenantID) error {
+	s.ExpirePasswordsInvoked = true
+	return s.ExpirePasswordsFn(tenantID)
+}
+
+// ExpireAllPasswords is the mock implementation of service method.
+func (s *PasswordExpirationService) ExpireAllPasswords() error {
+	s.ExpireAllPasswordsInvoked = true
+	return s.ExpireAllPasswordsFn()
+}
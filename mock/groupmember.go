@@ -0,0 +1,23 @@
+package mock
+
+import "github.com/maurofran/iam"
+
+// GroupMemberService is the mock group member service implementation.
+type GroupMemberService struct {
+	IsMemberGroupFn            func(*iam.Group, *iam.GroupMember) (bool, error)
+	IsMemberGroupInvoked       bool
+	IsUserInNestedGroupFn      func(*iam.Group, *iam.User) (bool, error)
+	IsUserInNestedGroupInvoked bool
+}
+
+// IsMemberGroup is the mock implementation of service method.
+func (s *GroupMemberService) IsMemberGroup(group *iam.Group, member *iam.GroupMember) (bool, error) {
+	s.IsMemberGroupInvoked = true
+	return s.IsMemberGroupFn(group, member)
+}
+
+// IsUserInNestedGroup is the mock implementation of service method.
+func (s *GroupMemberService) IsUserInNestedGroup(group *iam.Group, user *iam.User) (bool, error) {
+	s.IsUserInNestedGroupInvoked = true
+	return s.IsUserInNestedGroupFn(group, user)
+}
@@ -0,0 +1,15 @@
+package mock
+
+import "github.com/maurofran/iam"
+
+// LockoutService is the mock lockout service implementation.
+type LockoutService struct {
+	UnlockUserFn      func(iam.TenantID, string) error
+	UnlockUserInvoked bool
+}
+
+// UnlockUser is the mock implementation of service method.
+func (s *LockoutService) UnlockUser(tenantID iam.TenantID, username string) error {
+	s.UnlockUserInvoked = true
+	return s.UnlockUserFn(tenantID, username)
+}
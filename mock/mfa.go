@@ -0,0 +1,31 @@
+package mock
+
+import "github.com/maurofran/iam"
+
+// MFAService is the mock multi-factor authentication service implementation.
+type MFAService struct {
+	EnrollTOTPFn       func(iam.TenantID, string) (*iam.TOTPProvisioning, error)
+	EnrollTOTPInvoked  bool
+	ConfirmTOTPFn      func(iam.TenantID, string, string) error
+	ConfirmTOTPInvoked bool
+	DisableMFAFn       func(iam.TenantID, string) error
+	DisableMFAInvoked  bool
+}
+
+// EnrollTOTP is the mock implementation of service method.
+func (s *MFAService) EnrollTOTP(tenantID iam.TenantID, username string) (*iam.TOTPProvisioning, error) {
+	s.EnrollTOTPInvoked = true
+	return s.EnrollTOTPFn(tenantID, username)
+}
+
+// ConfirmTOTP is the mock implementation of service method.
+func (s *MFAService) ConfirmTOTP(tenantID iam.TenantID, username, code string) error {
+	s.ConfirmTOTPInvoked = true
+	return s.ConfirmTOTPFn(tenantID, username, code)
+}
+
+// DisableMFA is the mock implementation of service method.
+func (s *MFAService) DisableMFA(tenantID iam.TenantID, username string) error {
+	s.DisableMFAInvoked = true
+	return s.DisableMFAFn(tenantID, username)
+}
@@ -0,0 +1,15 @@
+package mock
+
+import "github.com/maurofran/iam"
+
+// Notifier is the mock notifier implementation.
+type Notifier struct {
+	NotifyFn      func(iam.Notification) error
+	NotifyInvoked bool
+}
+
+// Notify is the mock implementation of notifier method.
+func (n *Notifier) Notify(notification iam.Notification) error {
+	n.NotifyInvoked = true
+	return n.NotifyFn(notification)
+}
@@ -0,0 +1,67 @@
+package mock
+
+import "github.com/maurofran/iam"
+
+// AuthorizationCodeRepository is the mock struct for authorization code repository.
+type AuthorizationCodeRepository struct {
+	AddFn                            func(*iam.AuthorizationCode) error
+	AddInvoked                       bool
+	RedeemFn                         func(*iam.AuthorizationCode) (bool, error)
+	RedeemInvoked                    bool
+	AuthorizationCodeWithCodeFn      func(iam.TenantID, string) (*iam.AuthorizationCode, error)
+	AuthorizationCodeWithCodeInvoked bool
+}
+
+// Add is the mock method.
+func (r *AuthorizationCodeRepository) Add(code *iam.AuthorizationCode) error {
+	r.AddInvoked = true
+	return r.AddFn(code)
+}
+
+// Redeem is the mock method.
+func (r *AuthorizationCodeRepository) Redeem(code *iam.AuthorizationCode) (bool, error) {
+	r.RedeemInvoked = true
+	return r.RedeemFn(code)
+}
+
+// AuthorizationCodeWithCode is the mock method.
+func (r *AuthorizationCodeRepository) AuthorizationCodeWithCode(tenantID iam.TenantID, code string) (*iam.AuthorizationCode, error) {
+	r.AuthorizationCodeWithCodeInvoked = true
+	return r.AuthorizationCodeWithCodeFn(tenantID, code)
+}
+
+// OAuthService is the mock OAuth service implementation.
+type OAuthService struct {
+	ValidateAuthorizationRequestFn      func(*iam.AuthorizationRequest) (*iam.Client, error)
+	ValidateAuthorizationRequestInvoked bool
+	AuthorizeFn                         func(*iam.AuthorizationRequest, *iam.Authentication) (string, error)
+	AuthorizeInvoked                    bool
+	TokenFn                             func(*iam.TokenRequest) (*iam.TokenResponse, error)
+	TokenInvoked                        bool
+	UserInfoFn                          func(iam.TenantID, string, string) (*iam.UserInfo, error)
+	UserInfoInvoked                     bool
+}
+
+// ValidateAuthorizationRequest is the mock implementation of service method.
+func (s *OAuthService) ValidateAuthorizationRequest(request *iam.AuthorizationRequest) (*iam.Client, error) {
+	s.ValidateAuthorizationRequestInvoked = true
+	return s.ValidateAuthorizationRequestFn(request)
+}
+
+// Authorize is the mock implementation of service method.
+func (s *OAuthService) Authorize(request *iam.AuthorizationRequest, authentication *iam.Authentication) (string, error) {
+	s.AuthorizeInvoked = true
+	return s.AuthorizeFn(request, authentication)
+}
+
+// Token is the mock implementation of service method.
+func (s *OAuthService) Token(request *iam.TokenRequest) (*iam.TokenResponse, error) {
+	s.TokenInvoked = true
+	return s.TokenFn(request)
+}
+
+// UserInfo is the mock implementation of service method.
+func (s *OAuthService) UserInfo(tenantID iam.TenantID, username, scope string) (*iam.UserInfo, error) {
+	s.UserInfoInvoked = true
+	return s.UserInfoFn(tenantID, username, scope)
+}
@@ -0,0 +1,87 @@
+package mock
+
+import (
+	"time"
+
+	"github.com/maurofran/iam"
+)
+
+// LoginChallengeRepository is the mock login challenge repository implementation.
+type LoginChallengeRepository struct {
+	AddFn                            func(*iam.LoginChallenge) error
+	AddInvoked                       bool
+	RedeemFn                         func(*iam.LoginChallenge) (bool, error)
+	RedeemInvoked                    bool
+	RecordFailedAttemptFn            func(*iam.LoginChallenge) (bool, error)
+	RecordFailedAttemptInvoked       bool
+	LoginChallengeWithTokenFn        func(iam.TenantID, string) (*iam.LoginChallenge, error)
+	LoginChallengeWithTokenInvoked   bool
+	LatestLoginChallengeFn           func(iam.TenantID, string) (*iam.LoginChallenge, error)
+	LatestLoginChallengeInvoked      bool
+	CountLoginChallengesSinceFn      func(iam.TenantID, string, time.Time) (int, error)
+	CountLoginChallengesSinceInvoked bool
+}
+
+// Add is the mock of add method.
+func (r *LoginChallengeRepository) Add(challenge *iam.LoginChallenge) error {
+	r.AddInvoked = true
+	return r.AddFn(challenge)
+}
+
+// Redeem is the mock of redeem method.
+func (r *LoginChallengeRepository) Redeem(challenge *iam.LoginChallenge) (bool, error) {
+	r.RedeemInvoked = true
+	return r.RedeemFn(challenge)
+}
+
+// RecordFailedAttempt is the mock of record failed attempt method.
+func (r *LoginChallengeRepository) RecordFailedAttempt(challenge *iam.LoginChallenge) (bool, error) {
+	r.RecordFailedAttemptInvoked = true
+	return r.RecordFailedAttemptFn(challenge)
+}
+
+// LoginChallengeWithToken is the mock of find method.
+func (r *LoginChallengeRepository) LoginChallengeWithToken(tenantID iam.TenantID, token string) (*iam.LoginChallenge, error) {
+	r.LoginChallengeWithTokenInvoked = true
+	return r.LoginChallengeWithTokenFn(tenantID, token)
+}
+
+// LatestLoginChallenge is the mock of find method.
+func (r *LoginChallengeRepository) LatestLoginChallenge(tenantID iam.TenantID, username string) (*iam.LoginChallenge, error) {
+	r.LatestLoginChallengeInvoked = true
+	return r.LatestLoginChallengeFn(tenantID, username)
+}
+
+// CountLoginChallengesSince is the mock of count method.
+func (r *LoginChallengeRepository) CountLoginChallengesSince(tenantID iam.TenantID, username string, since time.Time) (int, error) {
+	r.CountLoginChallengesSinceInvoked = true
+	return r.CountLoginChallengesSinceFn(tenantID, username, since)
+}
+
+// PasswordlessService is the mock passwordless login service implementation.
+type PasswordlessService struct {
+	RequestLoginChallengeFn      func(iam.TenantID, iam.EmailAddress) error
+	RequestLoginChallengeInvoked bool
+	RedeemLoginLinkFn            func(iam.TenantID, string) (*iam.Authentication, error)
+	RedeemLoginLinkInvoked       bool
+	RedeemLoginCodeFn            func(iam.TenantID, iam.EmailAddress, string) (*iam.Authentication, error)
+	RedeemLoginCodeInvoked       bool
+}
+
+// RequestLoginChallenge is the mock implementation of service method.
+func (s *PasswordlessService) RequestLoginChallenge(tenantID iam.TenantID, emailAddress iam.EmailAddress) error {
+	s.RequestLoginChallengeInvoked = true
+	return s.RequestLoginChallengeFn(tenantID, emailAddress)
+}
+
+// RedeemLoginLink is the mock implementation of service method.
+func (s *PasswordlessService) RedeemLoginLink(tenantID iam.TenantID, token string) (*iam.Authentication, error) {
+	s.RedeemLoginLinkInvoked = true
+	return s.RedeemLoginLinkFn(tenantID, token)
+}
+
+// RedeemLoginCode is the mock implementation of service method.
+func (s *PasswordlessService) RedeemLoginCode(tenantID iam.TenantID, emailAddress iam.EmailAddress, code string) (*iam.Authentication, error) {
+	s.RedeemLoginCodeInvoked = true
+	return s.RedeemLoginCodeFn(tenantID, emailAddress, code)
+}
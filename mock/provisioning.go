@@ -0,0 +1,21 @@
+package mock
+
+import "github.com/maurofran/iam"
+
+// TenantProvisioningService is the mock tenant provisioning service implementation.
+type TenantProvisioningService struct {
+	ProvisionTenantFn      func(string, string, iam.FullName, iam.EmailAddress, iam.PostalAddress, iam.Telephone, iam.Telephone) (*iam.Tenant, string, error)
+	ProvisionTenantInvoked bool
+}
+
+// ProvisionTenant is the mock implementation of service method.
+func (s *TenantProvisioningService) ProvisionTenant(
+	name, description string,
+	administratorName iam.FullName,
+	emailAddress iam.EmailAddress,
+	postalAddress iam.PostalAddress,
+	primaryTelephone, secondaryTelephone iam.Telephone,
+) (*iam.Tenant, string, error) {
+	s.ProvisionTenantInvoked = true
+	return s.ProvisionTenantFn(name, description, administratorName, emailAddress, postalAddress, primaryTelephone, secondaryTelephone)
+}
@@ -0,0 +1,23 @@
+package mock
+
+import "github.com/maurofran/iam"
+
+// PasswordResetService is the mock password reset service implementation.
+type PasswordResetService struct {
+	RequestPasswordResetFn      func(iam.TenantID, string) error
+	RequestPasswordResetInvoked bool
+	ResetPasswordFn             func(iam.TenantID, string, string) error
+	ResetPasswordInvoked        bool
+}
+
+// RequestPasswordReset is the mock implementation of service method.
+func (s *PasswordResetService) RequestPasswordReset(tenantID iam.TenantID, username string) error {
+	s.RequestPasswordResetInvoked = true
+	return s.RequestPasswordResetFn(tenantID, username)
+}
+
+// ResetPassword is the mock implementation of service method.
+func (s *PasswordResetService) ResetPassword(tenantID iam.TenantID, token, password string) error {
+	s.ResetPasswordInvoked = true
+	return s.ResetPasswordFn(tenantID, token, password)
+}
@@ -0,0 +1,111 @@
+package mock
+
+import (
+	"time"
+
+	"github.com/maurofran/iam"
+)
+
+// ServiceAccountRepository is the mock struct for service account repository.
+type ServiceAccountRepository struct {
+	AddFn                                 func(*iam.ServiceAccount) error
+	AddInvoked                            bool
+	UpdateFn                              func(*iam.ServiceAccount) error
+	UpdateInvoked                         bool
+	RemoveFn                              func(*iam.ServiceAccount) error
+	RemoveInvoked                         bool
+	ServiceAccountNamedFn                 func(iam.TenantID, string) (*iam.ServiceAccount, error)
+	ServiceAccountNamedInvoked            bool
+	ServiceAccountWithAPIKeyPrefixFn      func(iam.TenantID, string) (*iam.ServiceAccount, error)
+	ServiceAccountWithAPIKeyPrefixInvoked bool
+	AllServiceAccountsFn                  func(iam.TenantID) (iam.ServiceAccounts, error)
+	AllServiceAccountsInvoked             bool
+	RecordAPIKeyUsageFn                   func(iam.TenantID, string, time.Time) error
+	RecordAPIKeyUsageInvoked              bool
+}
+
+// Add is the mock method.
+func (r *ServiceAccountRepository) Add(account *iam.ServiceAccount) error {
+	r.AddInvoked = true
+	return r.AddFn(account)
+}
+
+// Update is the mock method.
+func (r *ServiceAccountRepository) Update(account *iam.ServiceAccount) error {
+	r.UpdateInvoked = true
+	return r.UpdateFn(account)
+}
+
+// Remove is the mock method.
+func (r *ServiceAccountRepository) Remove(account *iam.ServiceAccount) error {
+	r.RemoveInvoked = true
+	return r.RemoveFn(account)
+}
+
+// ServiceAccountNamed is the mock method.
+func (r *ServiceAccountRepository) ServiceAccountNamed(tenantID iam.TenantID, name string) (*iam.ServiceAccount, error) {
+	r.ServiceAccountNamedInvoked = true
+	return r.ServiceAccountNamedFn(tenantID, name)
+}
+
+// ServiceAccountWithAPIKeyPrefix is the mock method.
+func (r *ServiceAccountRepository) ServiceAccountWithAPIKeyPrefix(tenantID iam.TenantID, prefix string) (*iam.ServiceAccount, error) {
+	r.ServiceAccountWithAPIKeyPrefixInvoked = true
+	return r.ServiceAccountWithAPIKeyPrefixFn(tenantID, prefix)
+}
+
+// AllServiceAccounts is the mock method.
+func (r *ServiceAccountRepository) AllServiceAccounts(tenantID iam.TenantID) (iam.ServiceAccounts, error) {
+	r.AllServiceAccountsInvoked = true
+	return r.AllServiceAccountsFn(tenantID)
+}
+
+// RecordAPIKeyUsage is the mock method.
+func (r *ServiceAccountRepository) RecordAPIKeyUsage(tenantID iam.TenantID, prefix string, usedAt time.Time) error {
+	r.RecordAPIKeyUsageInvoked = true
+	return r.RecordAPIKeyUsageFn(tenantID, prefix, usedAt)
+}
+
+// ServiceAccountService is the mock service account service implementation.
+type ServiceAccountService struct {
+	ProvisionServiceAccountFn             func(iam.TenantID, string, string) (*iam.ServiceAccount, error)
+	ProvisionServiceAccountInvoked        bool
+	DefineServiceAccountEnablementFn      func(iam.TenantID, string, iam.Enablement) error
+	DefineServiceAccountEnablementInvoked bool
+	IssueAPIKeyFn                         func(iam.TenantID, string, string, time.Duration) (string, error)
+	IssueAPIKeyInvoked                    bool
+	RevokeAPIKeyFn                        func(iam.TenantID, string, string) error
+	RevokeAPIKeyInvoked                   bool
+	AuthenticateAPIKeyFn                  func(iam.TenantID, string) (*iam.ServiceAccount, error)
+	AuthenticateAPIKeyInvoked             bool
+}
+
+// ProvisionServiceAccount is the mock implementation of service method.
+func (s *ServiceAccountService) ProvisionServiceAccount(tenantID iam.TenantID, name, description string) (*iam.ServiceAccount, error) {
+	s.ProvisionServiceAccountInvoked = true
+	return s.ProvisionServiceAccountFn(tenantID, name, description)
+}
+
+// DefineServiceAccountEnablement is the mock implementation of service method.
+func (s *ServiceAccountService) DefineServiceAccountEnablement(tenantID iam.TenantID, name string, enablement iam.Enablement) error {
+	s.DefineServiceAccountEnablementInvoked = true
+	return s.DefineServiceAccountEnablementFn(tenantID, name, enablement)
+}
+
+// IssueAPIKey is the mock implementation of service method.
+func (s *ServiceAccountService) IssueAPIKey(tenantID iam.TenantID, name, keyName string, ttl time.Duration) (string, error) {
+	s.IssueAPIKeyInvoked = true
+	return s.IssueAPIKeyFn(tenantID, name, keyName, ttl)
+}
+
+// RevokeAPIKey is the mock implementation of service method.
+func (s *ServiceAccountService) RevokeAPIKey(tenantID iam.TenantID, name, keyName string) error {
+	s.RevokeAPIKeyInvoked = true
+	return s.RevokeAPIKeyFn(tenantID, name, keyName)
+}
+
+// AuthenticateAPIKey is the mock implementation of service method.
+func (s *ServiceAccountService) AuthenticateAPIKey(tenantID iam.TenantID, key string) (*iam.ServiceAccount, error) {
+	s.AuthenticateAPIKeyInvoked = true
+	return s.AuthenticateAPIKeyFn(tenantID, key)
+}
@@ -0,0 +1,115 @@
+package mock
+
+import "github.com/maurofran/iam"
+
+// SessionRepository is the mock struct for session repository.
+type SessionRepository struct {
+	AddFn                          func(*iam.Session) error
+	AddInvoked                     bool
+	UpdateFn                       func(*iam.Session) error
+	UpdateInvoked                  bool
+	RotateFn                       func(*iam.Session, string) (bool, error)
+	RotateInvoked                  bool
+	SessionOfIDFn                  func(iam.TenantID, string) (*iam.Session, error)
+	SessionOfIDInvoked             bool
+	SessionWithRefreshTokenFn      func(iam.TenantID, string) (*iam.Session, error)
+	SessionWithRefreshTokenInvoked bool
+	ActiveSessionsOfUserFn         func(iam.TenantID, string) (iam.Sessions, error)
+	ActiveSessionsOfUserInvoked    bool
+	ActiveSessionsOfTenantFn       func(iam.TenantID) (iam.Sessions, error)
+	ActiveSessionsOfTenantInvoked  bool
+}
+
+// Add is the mock method.
+func (r *SessionRepository) Add(session *iam.Session) error {
+	r.AddInvoked = true
+	return r.AddFn(session)
+}
+
+// Update is the mock method.
+func (r *SessionRepository) Update(session *iam.Session) error {
+	r.UpdateInvoked = true
+	return r.UpdateFn(session)
+}
+
+// Rotate is the mock method.
+func (r *SessionRepository) Rotate(session *iam.Session, previous string) (bool, error) {
+	r.RotateInvoked = true
+	return r.RotateFn(session, previous)
+}
+
+// SessionOfID is the mock method.
+func (r *SessionRepository) SessionOfID(tenantID iam.TenantID, sessionID string) (*iam.Session, error) {
+	r.SessionOfIDInvoked = true
+	return r.SessionOfIDFn(tenantID, sessionID)
+}
+
+// SessionWithRefreshToken is the mock method.
+func (r *SessionRepository) SessionWithRefreshToken(tenantID iam.TenantID, token string) (*iam.Session, error) {
+	r.SessionWithRefreshTokenInvoked = true
+	return r.SessionWithRefreshTokenFn(tenantID, token)
+}
+
+// ActiveSessionsOfUser is the mock method.
+func (r *SessionRepository) ActiveSessionsOfUser(tenantID iam.TenantID, username string) (iam.Sessions, error) {
+	r.ActiveSessionsOfUserInvoked = true
+	return r.ActiveSessionsOfUserFn(tenantID, username)
+}
+
+// ActiveSessionsOfTenant is the mock method.
+func (r *SessionRepository) ActiveSessionsOfTenant(tenantID iam.TenantID) (iam.Sessions, error) {
+	r.ActiveSessionsOfTenantInvoked = true
+	return r.ActiveSessionsOfTenantFn(tenantID)
+}
+
+// SessionService is the mock session service implementation.
+type SessionService struct {
+	HandleEventFn         func(*iam.Event) error
+	HandleEventInvoked    bool
+	StartSessionFn        func(*iam.Authentication, iam.ClientMetadata) (*iam.Session, string, error)
+	StartSessionInvoked   bool
+	RefreshSessionFn      func(iam.TenantID, string) (*iam.Session, string, error)
+	RefreshSessionInvoked bool
+	SessionsFn            func(iam.TenantID, string) (iam.Sessions, error)
+	SessionsInvoked       bool
+	RevokeSessionFn       func(iam.TenantID, string, string) error
+	RevokeSessionInvoked  bool
+	RevokeSessionsFn      func(iam.TenantID, string) error
+	RevokeSessionsInvoked bool
+}
+
+// HandleEvent is the mock implementation of handler method.
+func (s *SessionService) HandleEvent(event *iam.Event) error {
+	s.HandleEventInvoked = true
+	return s.HandleEventFn(event)
+}
+
+// StartSession is the mock implementation of service method.
+func (s *SessionService) StartSession(authentication *iam.Authentication, client iam.ClientMetadata) (*iam.Session, string, error) {
+	s.StartSessionInvoked = true
+	return s.StartSessionFn(authentication, client)
+}
+
+// RefreshSession is the mock implementation of service method.
+func (s *SessionService) RefreshSession(tenantID iam.TenantID, refreshToken string) (*iam.Session, string, error) {
+	s.RefreshSessionInvoked = true
+	return s.RefreshSessionFn(tenantID, refreshToken)
+}
+
+// Sessions is the mock implementation of service method.
+func (s *SessionService) Sessions(tenantID iam.TenantID, username string) (iam.Sessions, error) {
+	s.SessionsInvoked = true
+	return s.SessionsFn(tenantID, username)
+}
+
+// RevokeSession is the mock implementation of service method.
+func (s *SessionService) RevokeSession(tenantID iam.TenantID, username, sessionID string) error {
+	s.RevokeSessionInvoked = true
+	return s.RevokeSessionFn(tenantID, username, sessionID)
+}
+
+// RevokeSessions is the mock implementation of service method.
+func (s *SessionService) RevokeSessions(tenantID iam.TenantID, username string) error {
+	s.RevokeSessionsInvoked = true
+	return s.RevokeSessionsFn(tenantID, username)
+}
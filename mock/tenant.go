@@ -14,6 +14,8 @@ type TenantRepository struct {
 	TenantNamedInvoked bool
 	TenantOfIDFn       func(iam.TenantID) (*iam.Tenant, error)
 	TenantOfIDInvoked  bool
+	AllTenantsFn       func() (iam.Tenants, error)
+	AllTenantsInvoked  bool
 }
 
 // Add will mock the tenant repository add method.
@@ -45,3 +47,9 @@ func (t *TenantRepository) TenantOfID(tenantID iam.TenantID) (*iam.Tenant, error
 	t.TenantOfIDInvoked = true
 	return t.TenantOfIDFn(tenantID)
 }
+
+// AllTenants will mock the tenant repository all tenants method.
+func (t *TenantRepository) AllTenants() (iam.Tenants, error) {
+	t.AllTenantsInvoked = true
+	return t.AllTenantsFn()
+}
@@ -0,0 +1,31 @@
+package mock
+
+import "github.com/maurofran/iam"
+
+// TokenService is the mock token service implementation.
+type TokenService struct {
+	IssueAccessTokenFn                    func(*iam.Authentication, string, string) (*iam.AccessToken, error)
+	IssueAccessTokenInvoked               bool
+	IssueServiceAccountAccessTokenFn      func(*iam.ServiceAccount, string, string) (*iam.AccessToken, error)
+	IssueServiceAccountAccessTokenInvoked bool
+	IssueIDTokenFn                        func(*iam.IDTokenGrant) (string, error)
+	IssueIDTokenInvoked                   bool
+}
+
+// IssueAccessToken is the mock implementation of service method.
+func (s *TokenService) IssueAccessToken(authentication *iam.Authentication, clientID, scope string) (*iam.AccessToken, error) {
+	s.IssueAccessTokenInvoked = true
+	return s.IssueAccessTokenFn(authentication, clientID, scope)
+}
+
+// IssueServiceAccountAccessToken is the mock implementation of service method.
+func (s *TokenService) IssueServiceAccountAccessToken(account *iam.ServiceAccount, clientID, scope string) (*iam.AccessToken, error) {
+	s.IssueServiceAccountAccessTokenInvoked = true
+	return s.IssueServiceAccountAccessTokenFn(account, clientID, scope)
+}
+
+// IssueIDToken is the mock implementation of service method.
+func (s *TokenService) IssueIDToken(grant *iam.IDTokenGrant) (string, error) {
+	s.IssueIDTokenInvoked = true
+	return s.IssueIDTokenFn(grant)
+}
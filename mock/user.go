@@ -1,21 +1,39 @@
 package mock
 
-import "github.com/maurofran/iam"
+import (
+	"time"
+
+	"github.com/maurofran/iam"
+)
 
 // UserRepository is struct for mock user repository
 type UserRepository struct {
-	AddFn                         func(*iam.User) error
-	AddInvoked                    bool
-	UpdateFn                      func(*iam.User) error
-	UpdateInvoked                 bool
-	RemoveFn                      func(*iam.User) error
-	RemoveInvoked                 bool
-	UserWithUsernameFn            func(iam.TenantID, string) (*iam.User, error)
-	UserWithUsernameInvoked       bool
-	UserWithCredentialsFn         func(iam.TenantID, string, string) (*iam.User, error)
-	UserWithCredentialsInvoked    bool
-	AllSimilarlyNamedUsersFn      func(iam.TenantID, string, string) (iam.Users, error)
-	AllSimilarlyNamedUsersInvoked bool
+	AddFn                                    func(*iam.User) error
+	AddInvoked                               bool
+	UpdateFn                                 func(*iam.User) error
+	UpdateInvoked                            bool
+	RemoveFn                                 func(*iam.User) error
+	RemoveInvoked                            bool
+	UserWithUsernameFn                       func(iam.TenantID, string) (*iam.User, error)
+	UserWithUsernameInvoked                  bool
+	UserWithEmailAddressFn                   func(iam.TenantID, iam.EmailAddress) (*iam.User, error)
+	UserWithEmailAddressInvoked              bool
+	UserWithPasswordResetTokenFn             func(iam.TenantID, string) (*iam.User, error)
+	UserWithPasswordResetTokenInvoked        bool
+	UserWithCredentialsFn                    func(iam.TenantID, string, string) (*iam.User, error)
+	UserWithCredentialsInvoked               bool
+	AllSimilarlyNamedUsersFn                 func(iam.TenantID, string, string) (iam.Users, error)
+	AllSimilarlyNamedUsersInvoked            bool
+	AllUsersWithPasswordChangedBeforeFn      func(iam.TenantID, time.Time) (iam.Users, error)
+	AllUsersWithPasswordChangedBeforeInvoked bool
+	ExpirePasswordFn                         func(iam.TenantID, string, time.Time) (bool, error)
+	ExpirePasswordInvoked                    bool
+	ResetPasswordFn                          func(*iam.User, string) (bool, error)
+	ResetPasswordInvoked                     bool
+	IncrementFailedAttemptsFn                func(iam.TenantID, string) (int, error)
+	IncrementFailedAttemptsInvoked           bool
+	UpdateLockoutFn                          func(*iam.User) error
+	UpdateLockoutInvoked                     bool
 }
 
 // Add is the mock of add method.
@@ -42,6 +60,18 @@ func (u *UserRepository) UserWithUsername(tenantID iam.TenantID, username string
 	return u.UserWithUsernameFn(tenantID, username)
 }
 
+// UserWithEmailAddress is the mock of find method.
+func (u *UserRepository) UserWithEmailAddress(tenantID iam.TenantID, emailAddress iam.EmailAddress) (*iam.User, error) {
+	u.UserWithEmailAddressInvoked = true
+	return u.UserWithEmailAddressFn(tenantID, emailAddress)
+}
+
+// UserWithPasswordResetToken is the mock of find method.
+func (u *UserRepository) UserWithPasswordResetToken(tenantID iam.TenantID, token string) (*iam.User, error) {
+	u.UserWithPasswordResetTokenInvoked = true
+	return u.UserWithPasswordResetTokenFn(tenantID, token)
+}
+
 // UserWithCredentials is the mock of find method.
 func (u *UserRepository) UserWithCredentials(tenantID iam.TenantID, username string, password string) (*iam.User, error) {
 	u.UserWithCredentialsInvoked = true
@@ -53,3 +83,33 @@ func (u *UserRepository) AllSimilarlyNamedUsers(tenantID iam.TenantID, firstName
 	u.AllSimilarlyNamedUsersInvoked = true
 	return u.AllSimilarlyNamedUsersFn(tenantID, firstNamePrefix, lastNamePrefix)
 }
+
+// AllUsersWithPasswordChangedBefore is the mock of find method.
+func (u *UserRepository) AllUsersWithPasswordChangedBefore(tenantID iam.TenantID, before time.Time) (iam.Users, error) {
+	u.AllUsersWithPasswordChangedBeforeInvoked = true
+	return u.AllUsersWithPasswordChangedBeforeFn(tenantID, before)
+}
+
+// ExpirePassword is the mock of expire method.
+func (u *UserRepository) ExpirePassword(tenantID iam.TenantID, username string, changedBefore time.Time) (bool, error) {
+	u.ExpirePasswordInvoked = true
+	return u.ExpirePasswordFn(tenantID, username, changedBefore)
+}
+
+// ResetPassword is the mock of reset password method.
+func (u *UserRepository) ResetPassword(user *iam.User, token string) (bool, error) {
+	u.ResetPasswordInvoked = true
+	return u.ResetPasswordFn(user, token)
+}
+
+// IncrementFailedAttempts is the mock of increment method.
+func (u *UserRepository) IncrementFailedAttempts(tenantID iam.TenantID, username string) (int, error) {
+	u.IncrementFailedAttemptsInvoked = true
+	return u.IncrementFailedAttemptsFn(tenantID, username)
+}
+
+// UpdateLockout is the mock of update method.
+func (u *UserRepository) UpdateLockout(user *iam.User) error {
+	u.UpdateLockoutInvoked = true
+	return u.UpdateLockoutFn(user)
+}
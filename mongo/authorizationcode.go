@@ -0,0 +1,74 @@
+package mongo
+
+import (
+	"time"
+
+	"github.com/maurofran/iam"
+	"github.com/pkg/errors"
+	mgo "gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+const authorizationCodes = "authorizationCodes"
+
+type authorizationCodeRepository struct {
+	client *Client
+}
+
+func (r *authorizationCodeRepository) init() error {
+	s := r.client.db.Copy()
+	defer s.Close()
+	c := s.DB(r.client.database).C(authorizationCodes)
+	if err := c.EnsureIndex(mgo.Index{Key: []string{"tenantId", "code"}, Unique: true, Name: "ixu_tenantId_code"}); err != nil {
+		return errors.Wrap(err, "An error occurred while ensuring index ixu_tenantId_code")
+	}
+	// Expired codes are kept an hour for auditing, then purged.
+	if err := c.EnsureIndex(mgo.Index{Key: []string{"expiresAt"}, ExpireAfter: time.Hour, Name: "ix_expiresAt"}); err != nil {
+		return errors.Wrap(err, "An error occurred while ensuring index ix_expiresAt")
+	}
+	return nil
+}
+
+// Add will add an authorization code to repository.
+func (r *authorizationCodeRepository) Add(ac *iam.AuthorizationCode) error {
+	s := r.client.db.Copy()
+	defer s.Close()
+	c := s.DB(r.client.database).C(authorizationCodes)
+	if err := c.Insert(ac); err != nil {
+		return errors.Wrapf(err, "An error occurred while adding authorization code of client %s", ac.ClientID)
+	}
+	return nil
+}
+
+// Redeem will mark an authorization code as redeemed, returning false if it already was.
+func (r *authorizationCodeRepository) Redeem(ac *iam.AuthorizationCode) (bool, error) {
+	s := r.client.db.Copy()
+	defer s.Close()
+	c := s.DB(r.client.database).C(authorizationCodes)
+	err := c.Update(
+		bson.M{"tenantId": ac.TenantID, "code": ac.Code, "redeemed": false},
+		bson.M{"$set": bson.M{"redeemed": true}},
+	)
+	if err != nil {
+		if err == mgo.ErrNotFound {
+			return false, nil
+		}
+		return false, errors.Wrapf(err, "An error occurred while redeeming authorization code of client %s", ac.ClientID)
+	}
+	return true, nil
+}
+
+// AuthorizationCodeWithCode will retrieve an authorization code by tenant id and code hash.
+func (r *authorizationCodeRepository) AuthorizationCodeWithCode(tID iam.TenantID, code string) (*iam.AuthorizationCode, error) {
+	s := r.client.db.Copy()
+	defer s.Close()
+	c := s.DB(r.client.database).C(authorizationCodes)
+	ac := new(iam.AuthorizationCode)
+	if err := c.Find(bson.M{"tenantId": tID, "code": code}).One(ac); err != nil {
+		if err == mgo.ErrNotFound {
+			return nil, nil
+		}
+		return nil, errors.Wrapf(err, "An error occurred while retrieving authorization code for id %s", tID)
+	}
+	return ac, nil
+}
@@ -15,6 +15,12 @@ type Client struct {
 	ur       userRepository
 	gr       groupRepository
 	rr       roleRepository
+	lcr      loginChallengeRepository
+	sar      serviceAccountRepository
+	ks       keyStore
+	sr       sessionRepository
+	cr       clientRepository
+	acr      authorizationCodeRepository
 }
 
 // NewClient will create a new client instance.
@@ -24,6 +30,12 @@ func NewClient(url string) *Client {
 	c.ur.client = c
 	c.gr.client = c
 	c.rr.client = c
+	c.lcr.client = c
+	c.sar.client = c
+	c.ks.client = c
+	c.sr.client = c
+	c.cr.client = c
+	c.acr.client = c
 	return c
 }
 
@@ -53,6 +65,36 @@ func (c *Client) RoleRepository() iam.RoleRepository {
 	return &c.rr
 }
 
+// LoginChallengeRepository is the accessor for the login challenge repository implementation with MongoDB.
+func (c *Client) LoginChallengeRepository() iam.LoginChallengeRepository {
+	return &c.lcr
+}
+
+// ServiceAccountRepository is the accessor for the service account repository implementation with MongoDB.
+func (c *Client) ServiceAccountRepository() iam.ServiceAccountRepository {
+	return &c.sar
+}
+
+// KeyStore is the accessor for the signing key store implementation with MongoDB.
+func (c *Client) KeyStore() iam.KeyStore {
+	return &c.ks
+}
+
+// SessionRepository is the accessor for the session repository implementation with MongoDB.
+func (c *Client) SessionRepository() iam.SessionRepository {
+	return &c.sr
+}
+
+// ClientRepository is the accessor for the OAuth client repository implementation with MongoDB.
+func (c *Client) ClientRepository() iam.ClientRepository {
+	return &c.cr
+}
+
+// AuthorizationCodeRepository is the accessor for the authorization code repository implementation with MongoDB.
+func (c *Client) AuthorizationCodeRepository() iam.AuthorizationCodeRepository {
+	return &c.acr
+}
+
 // Open will open the database connection.
 func (c *Client) Open() error {
 	db, err := mgo.Dial(c.url)
@@ -69,7 +111,25 @@ func (c *Client) Open() error {
 	if err := c.gr.init(); err != nil {
 		return err
 	}
-	return c.rr.init()
+	if err := c.rr.init(); err != nil {
+		return err
+	}
+	if err := c.lcr.init(); err != nil {
+		return err
+	}
+	if err := c.sar.init(); err != nil {
+		return err
+	}
+	if err := c.ks.init(); err != nil {
+		return err
+	}
+	if err := c.sr.init(); err != nil {
+		return err
+	}
+	if err := c.cr.init(); err != nil {
+		return err
+	}
+	return c.acr.init()
 }
 
 // Close will close the underlying mongo session.
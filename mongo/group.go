@@ -20,9 +20,64 @@ func (r *groupRepository) init() error {
 	if err := c.EnsureIndex(mgo.Index{Key: []string{"tenantId", "name"}, Unique: true, Name: "ixu_tenantId_name"}); err != nil {
 		return errors.Wrap(err, "An error occurred while ensuring index ixu_tenantId_name")
 	}
+	return migrateGroupMemberTypes(c)
+}
+
+// legacyGroupMemberTypes are the group member types stored as integers by previous versions.
+var legacyGroupMemberTypes = map[int64]iam.GroupMemberType{
+	1: iam.UserGroupMember,
+	2: iam.GroupGroupMember,
+}
+
+// migrateGroupMemberTypes will rewrite the member types stored as integers by previous versions with their names,
+// so that groups stored before member types were readable keep decoding.
+func migrateGroupMemberTypes(c *mgo.Collection) error {
+	codes := make([]int64, 0, len(legacyGroupMemberTypes))
+	for code := range legacyGroupMemberTypes {
+		codes = append(codes, code)
+	}
+	iter := c.Find(bson.M{"members.type": bson.M{"$in": codes}}).Select(bson.M{"members": 1}).Iter()
+	for {
+		var doc struct {
+			ID      interface{} `bson:"_id"`
+			Members []bson.M    `bson:"members"`
+		}
+		if !iter.Next(&doc) {
+			break
+		}
+		for _, m := range doc.Members {
+			if t, ok := legacyGroupMemberType(m["type"]); ok {
+				m["type"] = t
+			}
+		}
+		if err := c.UpdateId(doc.ID, bson.M{"$set": bson.M{"members": doc.Members}}); err != nil {
+			iter.Close()
+			return errors.Wrap(err, "An error occurred while migrating group member types")
+		}
+	}
+	if err := iter.Close(); err != nil {
+		return errors.Wrap(err, "An error occurred while migrating group member types")
+	}
 	return nil
 }
 
+// legacyGroupMemberType will return the group member type of a value stored as an integer.
+func legacyGroupMemberType(v interface{}) (iam.GroupMemberType, bool) {
+	var code int64
+	switch n := v.(type) {
+	case int:
+		code = int64(n)
+	case int64:
+		code = n
+	case float64:
+		code = int64(n)
+	default:
+		return "", false
+	}
+	t, ok := legacyGroupMemberTypes[code]
+	return t, ok
+}
+
 // Add will add a group to repository.
 func (r *groupRepository) Add(g *iam.Group) error {
 	s := r.client.db.Copy()
@@ -0,0 +1,84 @@
+package mongo
+
+import (
+	"github.com/maurofran/iam"
+	"github.com/pkg/errors"
+	mgo "gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+const clients = "clients"
+
+type clientRepository struct {
+	client *Client
+}
+
+func (r *clientRepository) init() error {
+	s := r.client.db.Copy()
+	defer s.Close()
+	c := s.DB(r.client.database).C(clients)
+	if err := c.EnsureIndex(mgo.Index{Key: []string{"tenantId", "clientId"}, Unique: true, Name: "ixu_tenantId_clientId"}); err != nil {
+		return errors.Wrap(err, "An error occurred while ensuring index ixu_tenantId_clientId")
+	}
+	return nil
+}
+
+// Add will add a client to repository.
+func (r *clientRepository) Add(cl *iam.Client) error {
+	s := r.client.db.Copy()
+	defer s.Close()
+	c := s.DB(r.client.database).C(clients)
+	if err := c.Insert(cl); err != nil {
+		return errors.Wrapf(err, "An error occurred while adding client %s", cl.ID)
+	}
+	return nil
+}
+
+// Update will update a client in repository.
+func (r *clientRepository) Update(cl *iam.Client) error {
+	s := r.client.db.Copy()
+	defer s.Close()
+	c := s.DB(r.client.database).C(clients)
+	if err := c.Update(bson.M{"tenantId": cl.TenantID, "clientId": cl.ID}, bson.M{"$set": cl}); err != nil {
+		return errors.Wrapf(err, "An error occurred while updating client %s", cl.ID)
+	}
+	return nil
+}
+
+// Remove will remove a client from repository.
+func (r *clientRepository) Remove(cl *iam.Client) error {
+	s := r.client.db.Copy()
+	defer s.Close()
+	c := s.DB(r.client.database).C(clients)
+	if err := c.Remove(bson.M{"tenantId": cl.TenantID, "clientId": cl.ID}); err != nil {
+		return errors.Wrapf(err, "An error occurred while removing client %s", cl.ID)
+	}
+	return nil
+}
+
+// ClientOfID will retrieve a client by tenant id and client id.
+func (r *clientRepository) ClientOfID(tID iam.TenantID, clientID string) (*iam.Client, error) {
+	s := r.client.db.Copy()
+	defer s.Close()
+	c := s.DB(r.client.database).C(clients)
+	cl := new(iam.Client)
+	if err := c.Find(bson.M{"tenantId": tID, "clientId": clientID}).One(cl); err != nil {
+		if err == mgo.ErrNotFound {
+			return nil, nil
+		}
+		return nil, errors.Wrapf(err, "An error occurred while retrieving client for id %s and client id %s", tID, clientID)
+	}
+	return cl, nil
+}
+
+// AllClients will retrieve all clients for tenant id.
+func (r *clientRepository) AllClients(tID iam.TenantID) (iam.Clients, error) {
+	s := r.client.db.Copy()
+	defer s.Close()
+	c := s.DB(r.client.database).C(clients)
+	var cc iam.Clients
+	if err := c.Find(bson.M{"tenantId": tID}).Sort("name").All(&cc); err != nil {
+		return nil, errors.Wrapf(err, "An error occurred while retrieving clients for id %s", tID)
+	}
+	return cc, nil
+}
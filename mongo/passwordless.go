@@ -0,0 +1,119 @@
+package mongo
+
+import (
+	"time"
+
+	"github.com/maurofran/iam"
+	"github.com/pkg/errors"
+	mgo "gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+const loginChallenges = "loginChallenges"
+
+type loginChallengeRepository struct {
+	client *Client
+}
+
+func (r *loginChallengeRepository) init() error {
+	s := r.client.db.Copy()
+	defer s.Close()
+	c := s.DB(r.client.database).C(loginChallenges)
+	if err := c.EnsureIndex(mgo.Index{Key: []string{"challengeId"}, Unique: true, Name: "ixu_challengeId"}); err != nil {
+		return errors.Wrap(err, "An error occurred while ensuring index ixu_challengeId")
+	}
+	if err := c.EnsureIndex(mgo.Index{Key: []string{"tenantId", "token"}, Unique: true, Name: "ixu_tenantId_token"}); err != nil {
+		return errors.Wrap(err, "An error occurred while ensuring index ixu_tenantId_token")
+	}
+	if err := c.EnsureIndex(mgo.Index{Key: []string{"tenantId", "username", "-issuedAt"}, Name: "ix_tenantId_username_issuedAt"}); err != nil {
+		return errors.Wrap(err, "An error occurred while ensuring index ix_tenantId_username_issuedAt")
+	}
+	// Expired challenges are kept a day for rate limiting, then purged.
+	if err := c.EnsureIndex(mgo.Index{Key: []string{"expiresAt"}, ExpireAfter: 24 * time.Hour, Name: "ix_expiresAt"}); err != nil {
+		return errors.Wrap(err, "An error occurred while ensuring index ix_expiresAt")
+	}
+	return nil
+}
+
+// Add will add a login challenge to repository.
+func (r *loginChallengeRepository) Add(lc *iam.LoginChallenge) error {
+	s := r.client.db.Copy()
+	defer s.Close()
+	c := s.DB(r.client.database).C(loginChallenges)
+	if err := c.Insert(lc); err != nil {
+		return errors.Wrapf(err, "An error occurred while inserting login challenge %s", lc.ID)
+	}
+	return nil
+}
+
+// Redeem will mark a login challenge redeemed while it is neither redeemed nor burned, returning false otherwise,
+// so that concurrent redemptions cannot both succeed.
+func (r *loginChallengeRepository) Redeem(lc *iam.LoginChallenge) (bool, error) {
+	return r.updateRedeemable(lc, bson.M{"$set": bson.M{"redeemed": true}})
+}
+
+// RecordFailedAttempt will count a wrong code of a login challenge while it is neither redeemed nor burned,
+// returning false otherwise, so that concurrent guesses cannot exceed the maximum number of attempts.
+func (r *loginChallengeRepository) RecordFailedAttempt(lc *iam.LoginChallenge) (bool, error) {
+	return r.updateRedeemable(lc, bson.M{"$inc": bson.M{"failedAttempts": 1}})
+}
+
+func (r *loginChallengeRepository) updateRedeemable(lc *iam.LoginChallenge, update bson.M) (bool, error) {
+	s := r.client.db.Copy()
+	defer s.Close()
+	c := s.DB(r.client.database).C(loginChallenges)
+	selector := bson.M{
+		"challengeId":    lc.ID,
+		"redeemed":       false,
+		"failedAttempts": bson.M{"$lt": iam.MaxLoginCodeAttempts},
+	}
+	if err := c.Update(selector, update); err != nil {
+		if err == mgo.ErrNotFound {
+			return false, nil
+		}
+		return false, errors.Wrapf(err, "An error occurred while updating login challenge %s", lc.ID)
+	}
+	return true, nil
+}
+
+// LoginChallengeWithToken will retrieve a login challenge by its hashed token.
+func (r *loginChallengeRepository) LoginChallengeWithToken(tID iam.TenantID, token string) (*iam.LoginChallenge, error) {
+	s := r.client.db.Copy()
+	defer s.Close()
+	c := s.DB(r.client.database).C(loginChallenges)
+	lc := new(iam.LoginChallenge)
+	if err := c.Find(bson.M{"tenantId": tID, "token": token}).One(lc); err != nil {
+		if err == mgo.ErrNotFound {
+			return nil, nil
+		}
+		return nil, errors.Wrapf(err, "An error occurred while retrieving login challenge for tenant %s", tID)
+	}
+	return lc, nil
+}
+
+// LatestLoginChallenge will retrieve the last login challenge issued to a user.
+func (r *loginChallengeRepository) LatestLoginChallenge(tID iam.TenantID, username string) (*iam.LoginChallenge, error) {
+	s := r.client.db.Copy()
+	defer s.Close()
+	c := s.DB(r.client.database).C(loginChallenges)
+	lc := new(iam.LoginChallenge)
+	if err := c.Find(bson.M{"tenantId": tID, "username": username}).Sort("-issuedAt").One(lc); err != nil {
+		if err == mgo.ErrNotFound {
+			return nil, nil
+		}
+		return nil, errors.Wrapf(err, "An error occurred while retrieving login challenge for tenant %s and username %s", tID, username)
+	}
+	return lc, nil
+}
+
+// CountLoginChallengesSince will count the login challenges issued to a user since supplied time.
+func (r *loginChallengeRepository) CountLoginChallengesSince(tID iam.TenantID, username string, since time.Time) (int, error) {
+	s := r.client.db.Copy()
+	defer s.Close()
+	c := s.DB(r.client.database).C(loginChallenges)
+	n, err := c.Find(bson.M{"tenantId": tID, "username": username, "issuedAt": bson.M{"$gte": since}}).Count()
+	if err != nil {
+		return 0, errors.Wrapf(err, "An error occurred while counting login challenges for tenant %s and username %s", tID, username)
+	}
+	return n, nil
+}
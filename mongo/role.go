@@ -77,7 +77,7 @@ func (r *roleRepository) AllRoles(tID iam.TenantID) (iam.Roles, error) {
 	defer s.Close()
 	c := s.DB(r.client.database).C(roles)
 	var rr iam.Roles
-	if err := c.Find(bson.M{"tenantId": tID}).Sort("name").All(rr); err != nil {
+	if err := c.Find(bson.M{"tenantId": tID}).Sort("name").All(&rr); err != nil {
 		return nil, errors.Wrapf(err, "An error occurred while retrieving roles for id %s", tID)
 	}
 	return rr, nil
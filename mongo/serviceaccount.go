@@ -0,0 +1,114 @@
+package mongo
+
+import (
+	"time"
+
+	"github.com/maurofran/iam"
+	"github.com/pkg/errors"
+	mgo "gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+const serviceAccounts = "serviceAccounts"
+
+type serviceAccountRepository struct {
+	client *Client
+}
+
+func (r *serviceAccountRepository) init() error {
+	s := r.client.db.Copy()
+	defer s.Close()
+	c := s.DB(r.client.database).C(serviceAccounts)
+	if err := c.EnsureIndex(mgo.Index{Key: []string{"tenantId", "name"}, Unique: true, Name: "ixu_tenantId_name"}); err != nil {
+		return errors.Wrap(err, "An error occurred while ensuring index ixu_tenantId_name")
+	}
+	if err := c.EnsureIndex(mgo.Index{Key: []string{"tenantId", "apiKeys.prefix"}, Name: "ix_tenantId_apiKeyPrefix"}); err != nil {
+		return errors.Wrap(err, "An error occurred while ensuring index ix_tenantId_apiKeyPrefix")
+	}
+	return nil
+}
+
+// Add will add a service account to repository.
+func (r *serviceAccountRepository) Add(a *iam.ServiceAccount) error {
+	s := r.client.db.Copy()
+	defer s.Close()
+	c := s.DB(r.client.database).C(serviceAccounts)
+	if err := c.Insert(a); err != nil {
+		return errors.Wrapf(err, "An error occurred while adding service account %s", a.Name)
+	}
+	return nil
+}
+
+// Update will update a service account in repository.
+func (r *serviceAccountRepository) Update(a *iam.ServiceAccount) error {
+	s := r.client.db.Copy()
+	defer s.Close()
+	c := s.DB(r.client.database).C(serviceAccounts)
+	if err := c.Update(bson.M{"tenantId": a.TenantID, "name": a.Name}, bson.M{"$set": a}); err != nil {
+		return errors.Wrapf(err, "An error occurred while updating service account %s", a.Name)
+	}
+	return nil
+}
+
+// Remove will remove a service account from repository.
+func (r *serviceAccountRepository) Remove(a *iam.ServiceAccount) error {
+	s := r.client.db.Copy()
+	defer s.Close()
+	c := s.DB(r.client.database).C(serviceAccounts)
+	if err := c.Remove(bson.M{"tenantId": a.TenantID, "name": a.Name}); err != nil {
+		return errors.Wrapf(err, "An error occurred while removing service account %s", a.Name)
+	}
+	return nil
+}
+
+// ServiceAccountNamed will retrieve a service account by tenant id and name.
+func (r *serviceAccountRepository) ServiceAccountNamed(tID iam.TenantID, name string) (*iam.ServiceAccount, error) {
+	return r.one(bson.M{"tenantId": tID, "name": name}, "name "+name, tID)
+}
+
+// ServiceAccountWithAPIKeyPrefix will retrieve the service account owning the API key with supplied prefix.
+func (r *serviceAccountRepository) ServiceAccountWithAPIKeyPrefix(tID iam.TenantID, prefix string) (*iam.ServiceAccount, error) {
+	return r.one(bson.M{"tenantId": tID, "apiKeys.prefix": prefix}, "API key prefix "+prefix, tID)
+}
+
+func (r *serviceAccountRepository) one(query bson.M, what string, tID iam.TenantID) (*iam.ServiceAccount, error) {
+	s := r.client.db.Copy()
+	defer s.Close()
+	c := s.DB(r.client.database).C(serviceAccounts)
+	a := new(iam.ServiceAccount)
+	if err := c.Find(query).One(a); err != nil {
+		if err == mgo.ErrNotFound {
+			return nil, nil
+		}
+		return nil, errors.Wrapf(err, "An error occurred while retrieving service account for id %s and %s", tID, what)
+	}
+	return a, nil
+}
+
+// AllServiceAccounts will retrieve all service accounts for tenant id.
+func (r *serviceAccountRepository) AllServiceAccounts(tID iam.TenantID) (iam.ServiceAccounts, error) {
+	s := r.client.db.Copy()
+	defer s.Close()
+	c := s.DB(r.client.database).C(serviceAccounts)
+	var aa iam.ServiceAccounts
+	if err := c.Find(bson.M{"tenantId": tID}).Sort("name").All(&aa); err != nil {
+		return nil, errors.Wrapf(err, "An error occurred while retrieving service accounts for id %s", tID)
+	}
+	return aa, nil
+}
+
+// RecordAPIKeyUsage will set the last used timestamp of the API key with supplied prefix, without touching
+// the rest of the service account so that a concurrent revocation is never overwritten.
+func (r *serviceAccountRepository) RecordAPIKeyUsage(tID iam.TenantID, prefix string, usedAt time.Time) error {
+	s := r.client.db.Copy()
+	defer s.Close()
+	c := s.DB(r.client.database).C(serviceAccounts)
+	err := c.Update(
+		bson.M{"tenantId": tID, "apiKeys.prefix": prefix},
+		bson.M{"$set": bson.M{"apiKeys.$.lastUsedAt": usedAt}},
+	)
+	if err != nil {
+		return errors.Wrapf(err, "An error occurred while recording usage of API key %s", prefix)
+	}
+	return nil
+}
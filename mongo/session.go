@@ -0,0 +1,125 @@
+package mongo
+
+import (
+	"time"
+
+	"github.com/maurofran/iam"
+	"github.com/pkg/errors"
+	mgo "gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+const sessions = "sessions"
+
+type sessionRepository struct {
+	client *Client
+}
+
+func (r *sessionRepository) init() error {
+	s := r.client.db.Copy()
+	defer s.Close()
+	c := s.DB(r.client.database).C(sessions)
+	if err := c.EnsureIndex(mgo.Index{Key: []string{"sessionId"}, Unique: true, Name: "ixu_sessionId"}); err != nil {
+		return errors.Wrap(err, "An error occurred while ensuring index ixu_sessionId")
+	}
+	if err := c.EnsureIndex(mgo.Index{Key: []string{"tenantId", "refreshToken"}, Name: "ix_tenantId_refreshToken"}); err != nil {
+		return errors.Wrap(err, "An error occurred while ensuring index ix_tenantId_refreshToken")
+	}
+	if err := c.EnsureIndex(mgo.Index{Key: []string{"tenantId", "rotatedRefreshTokens"}, Name: "ix_tenantId_rotatedRefreshTokens"}); err != nil {
+		return errors.Wrap(err, "An error occurred while ensuring index ix_tenantId_rotatedRefreshTokens")
+	}
+	if err := c.EnsureIndex(mgo.Index{Key: []string{"tenantId", "username", "expiresAt"}, Name: "ix_tenantId_username_expiresAt"}); err != nil {
+		return errors.Wrap(err, "An error occurred while ensuring index ix_tenantId_username_expiresAt")
+	}
+	// Expired sessions are kept a day for auditing, then purged.
+	if err := c.EnsureIndex(mgo.Index{Key: []string{"expiresAt"}, ExpireAfter: 24 * time.Hour, Name: "ix_expiresAt"}); err != nil {
+		return errors.Wrap(err, "An error occurred while ensuring index ix_expiresAt")
+	}
+	return nil
+}
+
+// Add will add a session to repository.
+func (r *sessionRepository) Add(ss *iam.Session) error {
+	s := r.client.db.Copy()
+	defer s.Close()
+	c := s.DB(r.client.database).C(sessions)
+	if err := c.Insert(ss); err != nil {
+		return errors.Wrapf(err, "An error occurred while inserting session %s", ss.ID)
+	}
+	return nil
+}
+
+// Update will update a session in repository.
+func (r *sessionRepository) Update(ss *iam.Session) error {
+	s := r.client.db.Copy()
+	defer s.Close()
+	c := s.DB(r.client.database).C(sessions)
+	if err := c.Update(bson.M{"sessionId": ss.ID}, bson.M{"$set": ss}); err != nil {
+		return errors.Wrapf(err, "An error occurred while updating session %s", ss.ID)
+	}
+	return nil
+}
+
+// Rotate will update a session whose refresh token is still previous, returning false if it is not.
+func (r *sessionRepository) Rotate(ss *iam.Session, previous string) (bool, error) {
+	s := r.client.db.Copy()
+	defer s.Close()
+	c := s.DB(r.client.database).C(sessions)
+	if err := c.Update(bson.M{"sessionId": ss.ID, "refreshToken": previous}, bson.M{"$set": ss}); err != nil {
+		if err == mgo.ErrNotFound {
+			return false, nil
+		}
+		return false, errors.Wrapf(err, "An error occurred while rotating refresh token of session %s", ss.ID)
+	}
+	return true, nil
+}
+
+// SessionOfID will retrieve a session by tenant id and session id.
+func (r *sessionRepository) SessionOfID(tID iam.TenantID, sessionID string) (*iam.Session, error) {
+	return r.one(bson.M{"tenantId": tID, "sessionId": sessionID}, tID)
+}
+
+// SessionWithRefreshToken will retrieve a session by the hash of its current or rotated refresh tokens.
+func (r *sessionRepository) SessionWithRefreshToken(tID iam.TenantID, token string) (*iam.Session, error) {
+	return r.one(bson.M{
+		"tenantId": tID,
+		"$or":      []bson.M{{"refreshToken": token}, {"rotatedRefreshTokens": token}},
+	}, tID)
+}
+
+func (r *sessionRepository) one(query bson.M, tID iam.TenantID) (*iam.Session, error) {
+	s := r.client.db.Copy()
+	defer s.Close()
+	c := s.DB(r.client.database).C(sessions)
+	ss := new(iam.Session)
+	if err := c.Find(query).One(ss); err != nil {
+		if err == mgo.ErrNotFound {
+			return nil, nil
+		}
+		return nil, errors.Wrapf(err, "An error occurred while retrieving session for id %s", tID)
+	}
+	return ss, nil
+}
+
+// ActiveSessionsOfUser will retrieve the sessions of a user neither revoked nor expired, most recent first.
+func (r *sessionRepository) ActiveSessionsOfUser(tID iam.TenantID, username string) (iam.Sessions, error) {
+	return r.active(bson.M{"tenantId": tID, "username": username}, tID)
+}
+
+// ActiveSessionsOfTenant will retrieve the sessions of a tenant neither revoked nor expired, most recent first.
+func (r *sessionRepository) ActiveSessionsOfTenant(tID iam.TenantID) (iam.Sessions, error) {
+	return r.active(bson.M{"tenantId": tID}, tID)
+}
+
+func (r *sessionRepository) active(query bson.M, tID iam.TenantID) (iam.Sessions, error) {
+	s := r.client.db.Copy()
+	defer s.Close()
+	c := s.DB(r.client.database).C(sessions)
+	query["revokedAt"] = bson.M{"$exists": false}
+	query["expiresAt"] = bson.M{"$gt": time.Now()}
+	var ss iam.Sessions
+	if err := c.Find(query).Sort("-createdAt").All(&ss); err != nil {
+		return nil, errors.Wrapf(err, "An error occurred while retrieving sessions for id %s", tID)
+	}
+	return ss, nil
+}
@@ -0,0 +1,61 @@
+package mongo
+
+import (
+	"github.com/maurofran/iam"
+	"github.com/pkg/errors"
+	mgo "gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+const signingKeys = "signingKeys"
+
+type keyStore struct {
+	client *Client
+}
+
+func (r *keyStore) init() error {
+	s := r.client.db.Copy()
+	defer s.Close()
+	c := s.DB(r.client.database).C(signingKeys)
+	if err := c.EnsureIndex(mgo.Index{Key: []string{"kid"}, Unique: true, Name: "ixu_kid"}); err != nil {
+		return errors.Wrap(err, "An error occurred while ensuring index ixu_kid")
+	}
+	if err := c.EnsureIndex(mgo.Index{Key: []string{"state", "createdAt"}, Name: "ix_state_createdAt"}); err != nil {
+		return errors.Wrap(err, "An error occurred while ensuring index ix_state_createdAt")
+	}
+	return nil
+}
+
+// Add will add a signing key to the store.
+func (r *keyStore) Add(k *iam.SigningKey) error {
+	s := r.client.db.Copy()
+	defer s.Close()
+	c := s.DB(r.client.database).C(signingKeys)
+	if err := c.Insert(k); err != nil {
+		return errors.Wrapf(err, "An error occurred while adding signing key %s", k.ID)
+	}
+	return nil
+}
+
+// Update will update a signing key in the store.
+func (r *keyStore) Update(k *iam.SigningKey) error {
+	s := r.client.db.Copy()
+	defer s.Close()
+	c := s.DB(r.client.database).C(signingKeys)
+	if err := c.Update(bson.M{"kid": k.ID}, bson.M{"$set": k}); err != nil {
+		return errors.Wrapf(err, "An error occurred while updating signing key %s", k.ID)
+	}
+	return nil
+}
+
+// SigningKeysInState will retrieve the signing keys in any of supplied states, oldest first.
+func (r *keyStore) SigningKeysInState(states ...iam.SigningKeyState) (iam.SigningKeys, error) {
+	s := r.client.db.Copy()
+	defer s.Close()
+	c := s.DB(r.client.database).C(signingKeys)
+	var kk iam.SigningKeys
+	if err := c.Find(bson.M{"state": bson.M{"$in": states}}).Sort("createdAt").All(&kk); err != nil {
+		return nil, errors.Wrapf(err, "An error occurred while retrieving signing keys in states %v", states)
+	}
+	return kk, nil
+}
@@ -89,3 +89,15 @@ func (r *tenantRepository) TenantOfID(tID iam.TenantID) (*iam.Tenant, error) {
 	}
 	return t, nil
 }
+
+// AllTenants will retrieve all the tenants.
+func (r *tenantRepository) AllTenants() (iam.Tenants, error) {
+	s := r.client.db.Copy()
+	defer s.Close()
+	c := s.DB(r.client.database).C(tenants)
+	var tt iam.Tenants
+	if err := c.Find(nil).Sort("name").All(&tt); err != nil {
+		return nil, errors.Wrap(err, "An error occurred while retrieving tenants")
+	}
+	return tt, nil
+}
@@ -1,6 +1,8 @@
 package mongo
 
 import (
+	"time"
+
 	"github.com/maurofran/iam"
 	"github.com/pkg/errors"
 	mgo "gopkg.in/mgo.v2"
@@ -9,6 +11,9 @@ import (
 
 const users = "users"
 
+// withoutPasswordHistory is the projection hiding password history from listings.
+var withoutPasswordHistory = bson.M{"passwordHistory": 0}
+
 type userRepository struct {
 	client *Client
 }
@@ -20,6 +25,15 @@ func (r *userRepository) init() error {
 	if err := c.EnsureIndex(mgo.Index{Key: []string{"tenantId", "username"}, Unique: true, Name: "ixu_tenantId_username"}); err != nil {
 		return errors.Wrap(err, "An error occurred while ensuring index ixu_tenantId_username")
 	}
+	if err := c.EnsureIndex(mgo.Index{Key: []string{"tenantId", "passwordChangedAt"}, Name: "ix_tenantId_passwordChangedAt"}); err != nil {
+		return errors.Wrap(err, "An error occurred while ensuring index ix_tenantId_passwordChangedAt")
+	}
+	if err := c.EnsureIndex(mgo.Index{Key: []string{"tenantId", "person.contactInformation.emailAddress"}, Name: "ix_tenantId_emailAddress"}); err != nil {
+		return errors.Wrap(err, "An error occurred while ensuring index ix_tenantId_emailAddress")
+	}
+	if err := c.EnsureIndex(mgo.Index{Key: []string{"tenantId", "passwordReset.token"}, Sparse: true, Name: "ix_tenantId_passwordResetToken"}); err != nil {
+		return errors.Wrap(err, "An error occurred while ensuring index ix_tenantId_passwordResetToken")
+	}
 	return nil
 }
 
@@ -34,17 +48,139 @@ func (r *userRepository) Add(u *iam.User) error {
 	return nil
 }
 
-// Update will update a user in repository.
+// Update will update a user in repository. A removed second factor or password reset is unset. The password history
+// is only written by ResetPassword, so that users loaded from listings without it can be updated. Lockout fields are
+// only written by IncrementFailedAttempts and UpdateLockout, so that concurrent failed authentications are never lost.
 func (r *userRepository) Update(u *iam.User) error {
 	s := r.client.db.Copy()
 	defer s.Close()
 	c := s.DB(r.client.database).C(users)
-	if err := c.Update(bson.M{"tenantId": u.TenantID, "username": u.Username}, bson.M{"$set": u}); err != nil {
+	update, err := userUpdate(u)
+	if err != nil {
+		return err
+	}
+	if err := c.Update(bson.M{"tenantId": u.TenantID, "username": u.Username}, update); err != nil {
 		return errors.Wrapf(err, "An error occurred while updating user %s", u)
 	}
 	return nil
 }
 
+// ResetPassword will update a user whose password was reset, password history included, only while its stored
+// password reset token is still the supplied hash, returning false otherwise, so that concurrent resets with the
+// same token cannot both succeed.
+func (r *userRepository) ResetPassword(u *iam.User, token string) (bool, error) {
+	s := r.client.db.Copy()
+	defer s.Close()
+	c := s.DB(r.client.database).C(users)
+	update, err := userUpdate(u)
+	if err != nil {
+		return false, err
+	}
+	if len(u.PasswordHistory) == 0 {
+		unset, _ := update["$unset"].(bson.M)
+		if unset == nil {
+			unset = bson.M{}
+			update["$unset"] = unset
+		}
+		unset["passwordHistory"] = ""
+	} else {
+		update["$set"].(bson.M)["passwordHistory"] = u.PasswordHistory
+	}
+	if err := c.Update(bson.M{"tenantId": u.TenantID, "username": u.Username, "passwordReset.token": token}, update); err != nil {
+		if err == mgo.ErrNotFound {
+			return false, nil
+		}
+		return false, errors.Wrapf(err, "An error occurred while resetting password of user %s", u)
+	}
+	return true, nil
+}
+
+// userUpdate will return the update writing the user, but its lockout fields and password history.
+func userUpdate(u *iam.User) (bson.M, error) {
+	data, err := bson.Marshal(u)
+	if err != nil {
+		return nil, errors.Wrapf(err, "An error occurred while marshalling user %s", u.Username)
+	}
+	var doc bson.M
+	if err := bson.Unmarshal(data, &doc); err != nil {
+		return nil, errors.Wrapf(err, "An error occurred while marshalling user %s", u.Username)
+	}
+	for _, f := range lockoutFields {
+		delete(doc, f)
+	}
+	delete(doc, "passwordHistory")
+	update := bson.M{"$set": doc}
+	unset := bson.M{}
+	if u.SecondFactor == nil {
+		unset["secondFactor"] = ""
+	}
+	if u.PasswordReset == nil {
+		unset["passwordReset"] = ""
+	}
+	if len(unset) > 0 {
+		update["$unset"] = unset
+	}
+	return update, nil
+}
+
+// lockoutFields are the user fields updated atomically.
+var lockoutFields = []string{"failedAttempts", "lockouts", "lockedUntil"}
+
+// IncrementFailedAttempts will atomically increment the failed authentications of a user, returning the new count.
+func (r *userRepository) IncrementFailedAttempts(tID iam.TenantID, username string) (int, error) {
+	s := r.client.db.Copy()
+	defer s.Close()
+	c := s.DB(r.client.database).C(users)
+	var u struct {
+		FailedAttempts int `bson:"failedAttempts"`
+	}
+	change := mgo.Change{Update: bson.M{"$inc": bson.M{"failedAttempts": 1}}, ReturnNew: true}
+	if _, err := c.Find(bson.M{"tenantId": tID, "username": username}).Select(bson.M{"failedAttempts": 1}).Apply(change, &u); err != nil {
+		return 0, errors.Wrapf(err, "An error occurred while incrementing failed attempts of user %s", username)
+	}
+	return u.FailedAttempts, nil
+}
+
+// UpdateLockout will atomically update the failed authentications and lockout of a user.
+func (r *userRepository) UpdateLockout(u *iam.User) error {
+	s := r.client.db.Copy()
+	defer s.Close()
+	c := s.DB(r.client.database).C(users)
+	lockout := bson.M{
+		"failedAttempts": u.FailedAttempts,
+		"lockouts":       u.Lockouts,
+		"lockedUntil":    u.LockedUntil,
+	}
+	if err := c.Update(bson.M{"tenantId": u.TenantID, "username": u.Username}, bson.M{"$set": lockout}); err != nil {
+		return errors.Wrapf(err, "An error occurred while updating lockout of user %s", u.Username)
+	}
+	return nil
+}
+
+// ExpirePassword will atomically require a password change of a user, only while the password is still changed
+// before supplied time, returning false otherwise.
+func (r *userRepository) ExpirePassword(tID iam.TenantID, username string, changedBefore time.Time) (bool, error) {
+	s := r.client.db.Copy()
+	defer s.Close()
+	c := s.DB(r.client.database).C(users)
+	err := c.Update(
+		bson.M{
+			"tenantId":           tID,
+			"username":           username,
+			"passwordChangedAt":  bson.M{"$lt": changedBefore},
+			"mustChangePassword": false,
+		},
+		bson.M{"$set": bson.M{"mustChangePassword": true}},
+	)
+	if err != nil {
+		if err == mgo.ErrNotFound {
+			return false, nil
+		}
+		return false, errors.Wrapf(err, "An error occurred while expiring password of user %s", username)
+	}
+	return true, nil
+}
+
 // Remove will remove a user from repository.
 func (r *userRepository) Remove(u *iam.User) error {
 	s := r.client.db.Copy()
@@ -71,6 +207,36 @@ func (r *userRepository) UserWithUsername(tID iam.TenantID, username string) (*i
 	return u, nil
 }
 
+// UserWithEmailAddress will retrieve a user by his email address.
+func (r *userRepository) UserWithEmailAddress(tID iam.TenantID, emailAddress iam.EmailAddress) (*iam.User, error) {
+	s := r.client.db.Copy()
+	defer s.Close()
+	c := s.DB(r.client.database).C(users)
+	u := new(iam.User)
+	if err := c.Find(bson.M{"tenantId": tID, "person.contactInformation.emailAddress": emailAddress}).One(&u); err != nil {
+		if err == mgo.ErrNotFound {
+			return nil, nil
+		}
+		return nil, errors.Wrapf(err, "An error occurred while retrieving user for tenant %s and email address %s", tID, emailAddress)
+	}
+	return u, nil
+}
+
+// UserWithPasswordResetToken will retrieve a user by the hash of his password reset token.
+func (r *userRepository) UserWithPasswordResetToken(tID iam.TenantID, token string) (*iam.User, error) {
+	s := r.client.db.Copy()
+	defer s.Close()
+	c := s.DB(r.client.database).C(users)
+	u := new(iam.User)
+	if err := c.Find(bson.M{"tenantId": tID, "passwordReset.token": token}).One(&u); err != nil {
+		if err == mgo.ErrNotFound {
+			return nil, nil
+		}
+		return nil, errors.Wrapf(err, "An error occurred while retrieving user for tenant %s and password reset token", tID)
+	}
+	return u, nil
+}
+
 // AllSimilarlyNamedUsers will retrieve all users by his first name and last name prefix
 func (r *userRepository) AllSimilarlyNamedUsers(tID iam.TenantID, firstNamePrefix, lastNamePrefix string) (iam.Users, error) {
 	s := r.client.db.Copy()
@@ -82,7 +248,23 @@ func (r *userRepository) AllSimilarlyNamedUsers(tID iam.TenantID, firstNamePrefi
 		"person.fullName.firstName": bson.M{"$regex": bson.RegEx{Pattern: "^" + firstNamePrefix}},
 		"person.fullName.lastName":  bson.M{"$regex": bson.RegEx{Pattern: "^" + lastNamePrefix}},
 	}
-	if err := c.Find(query).Sort("username").All(uu); err != nil {
+	if err := c.Find(query).Select(withoutPasswordHistory).Sort("username").All(&uu); err != nil {
+		return nil, errors.Wrapf(err, "An error occurred while retrieving users of tenant %s", tID)
+	}
+	return uu, nil
+}
+
+// AllUsersWithPasswordChangedBefore will retrieve all users whose password was changed before supplied time.
+func (r *userRepository) AllUsersWithPasswordChangedBefore(tID iam.TenantID, before time.Time) (iam.Users, error) {
+	s := r.client.db.Copy()
+	defer s.Close()
+	c := s.DB(r.client.database).C(users)
+	var uu iam.Users
+	query := bson.M{
+		"tenantId":          tID,
+		"passwordChangedAt": bson.M{"$lt": before},
+	}
+	if err := c.Find(query).Select(withoutPasswordHistory).Sort("username").All(&uu); err != nil {
 		return nil, errors.Wrapf(err, "An error occurred while retrieving users of tenant %s", tID)
 	}
 	return uu, nil
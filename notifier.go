@@ -0,0 +1,50 @@
+package iam
+
+import (
+	"net/url"
+	"time"
+)
+
+// Notifier is the interface for the delivery of notifications to users, usually by email.
+type Notifier interface {
+	Notify(Notification) error
+}
+
+// NotificationKind is the enum type for the purpose of a notification.
+type NotificationKind string
+
+// LoginChallengeNotification is the kind of notifications delivering a passwordless login link and code.
+// PasswordResetNotification is the kind of notifications delivering a password reset link.
+const (
+	LoginChallengeNotification NotificationKind = "loginChallenge"
+	PasswordResetNotification  NotificationKind = "passwordReset"
+)
+
+// Notification is the value object holding a message to deliver to a user.
+// Token and Code are the secrets to deliver, Link embeds the token when the tenant configured an URL for it.
+type Notification struct {
+	Kind         NotificationKind
+	TenantID     TenantID
+	Username     string
+	EmailAddress EmailAddress
+	Token        string
+	Code         string
+	Link         string
+	ExpiresAt    time.Time
+}
+
+// tokenLink will return the link to supplied base URL with the token added as query parameter,
+// empty if no base URL is configured.
+func tokenLink(base, token string) string {
+	if base == "" {
+		return ""
+	}
+	u, err := url.Parse(base)
+	if err != nil {
+		return ""
+	}
+	q := u.Query()
+	q.Set("token", token)
+	u.RawQuery = q.Encode()
+	return u.String()
+}
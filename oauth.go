@@ -0,0 +1,545 @@
+package iam
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"time"
+)
+
+// authorizationCodeTTL is the lifetime of authorization codes, which are meant to be redeemed right away.
+const authorizationCodeTTL = time.Minute
+
+// codeResponseType is the only response type of authorization requests, as implicit flows are not supported.
+const codeResponseType = "code"
+
+// S256CodeChallenge is the PKCE method hashing the code verifier with SHA-256, the only one supported.
+const S256CodeChallenge = "S256"
+
+// minCodeVerifierLength and maxCodeVerifierLength bound the length of PKCE code verifiers, as RFC 7636 requires.
+const (
+	minCodeVerifierLength = 43
+	maxCodeVerifierLength = 128
+)
+
+// Error codes of the OAuth 2.0 protocol, as defined by RFC 6749.
+const (
+	OAuthInvalidRequest          = "invalid_request"
+	OAuthInvalidClient           = "invalid_client"
+	OAuthInvalidGrant            = "invalid_grant"
+	OAuthUnauthorizedClient      = "unauthorized_client"
+	OAuthUnsupportedGrantType    = "unsupported_grant_type"
+	OAuthUnsupportedResponseType = "unsupported_response_type"
+	OAuthInvalidScope            = "invalid_scope"
+	OAuthAccessDenied            = "access_denied"
+	OAuthServerError             = "server_error"
+)
+
+// invalidCodeVerifier is the description of the error raised when the PKCE code verifier does not match.
+const invalidCodeVerifier = "Invalid code verifier."
+
+// OAuthError is the error reported to OAuth 2.0 clients, wrapped by the errors of the OAuth service.
+type OAuthError struct {
+	Code        string
+	Description string
+}
+
+// Error returns the string representation of the OAuth error.
+func (e *OAuthError) Error() string {
+	return e.Code + ": " + e.Description
+}
+
+// OAuthErrorOf will return the OAuth error wrapped by supplied error, or a server error if none is.
+func OAuthErrorOf(err error) *OAuthError {
+	for err != nil {
+		switch e := err.(type) {
+		case *OAuthError:
+			return e
+		case *Error:
+			err = e.Err
+		default:
+			err = nil
+		}
+	}
+	return &OAuthError{Code: OAuthServerError, Description: "An unexpected error occurred."}
+}
+
+func oauthError(op, code, description string) error {
+	var c string
+	switch code {
+	case OAuthInvalidClient, OAuthInvalidGrant, OAuthUnauthorizedClient, OAuthAccessDenied:
+		c = EUNAUTHORIZED
+	default:
+		c = EINVALID
+	}
+	return &Error{Code: c, Message: description, Op: op, Err: &OAuthError{Code: code, Description: description}}
+}
+
+// AuthorizationRequest is the value object holding the parameters of an OAuth 2.0 authorization request.
+type AuthorizationRequest struct {
+	TenantID            TenantID
+	ResponseType        string
+	ClientID            string
+	RedirectURI         string
+	Scope               string
+	State               string
+	CodeChallenge       string
+	CodeChallengeMethod string
+	Nonce               string
+}
+
+// TokenRequest is the value object holding the parameters of an OAuth 2.0 token request.
+// Client holds the user agent and address the request was sent from.
+type TokenRequest struct {
+	TenantID     TenantID
+	GrantType    GrantType
+	ClientID     string
+	ClientSecret string
+	Code         string
+	RedirectURI  string
+	CodeVerifier string
+	RefreshToken string
+	Scope        string
+	Client       ClientMetadata
+}
+
+// TokenResponse is the value object holding the tokens issued by a successful token request.
+type TokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	IDToken      string `json:"id_token,omitempty"`
+	Scope        string `json:"scope,omitempty"`
+}
+
+// AuthorizationCode is the aggregate root representing a code issued to a client on behalf of an authenticated
+// user, to be redeemed once for tokens. Only the hash of the code is stored.
+type AuthorizationCode struct {
+	Code          string    `bson:"code" json:"-"`
+	TenantID      TenantID  `bson:"tenantId"`
+	ClientID      string    `bson:"clientId"`
+	Username      string    `bson:"username"`
+	RedirectURI   string    `bson:"redirectUri"`
+	Scope         string    `bson:"scope,omitempty"`
+	CodeChallenge string    `bson:"codeChallenge"`
+	Nonce         string    `bson:"nonce,omitempty"`
+	IssuedAt      time.Time `bson:"issuedAt"`
+	ExpiresAt     time.Time `bson:"expiresAt"`
+	Redeemed      bool      `bson:"redeemed"`
+}
+
+// NewAuthorizationCode will issue a code for the user authorizing supplied request, returning it with the plain code.
+func NewAuthorizationCode(request *AuthorizationRequest, user *User) (*AuthorizationCode, string, Events, error) {
+	const op = "NewAuthorizationCode"
+	code, err := newToken(op)
+	if err != nil {
+		return nil, "", nil, err
+	}
+	now := time.Now()
+	c := &AuthorizationCode{
+		Code:          hashToken(code),
+		TenantID:      user.TenantID,
+		ClientID:      request.ClientID,
+		Username:      user.Username,
+		RedirectURI:   request.RedirectURI,
+		Scope:         request.Scope,
+		CodeChallenge: request.CodeChallenge,
+		Nonce:         request.Nonce,
+		IssuedAt:      now,
+		ExpiresAt:     now.Add(authorizationCodeTTL),
+	}
+	return c, code, Events{EventWithPayload(&AuthorizationCodeIssued{
+		TenantID: c.TenantID,
+		ClientID: c.ClientID,
+		Username: c.Username,
+	})}, nil
+}
+
+// Redeem will mark the code as redeemed by the client, after checking that the redirect URI is the one it was
+// issued for and that the verifier matches the PKCE challenge.
+func (c *AuthorizationCode) Redeem(clientID, redirectURI, codeVerifier string) (Events, error) {
+	const op = "Redeem"
+	if c.Redeemed || !time.Now().Before(c.ExpiresAt) {
+		return nil, oauthError(op, OAuthInvalidGrant, "Authorization code is expired or already used.")
+	}
+	if c.ClientID != clientID || c.RedirectURI != redirectURI {
+		return nil, oauthError(op, OAuthInvalidGrant, "Authorization code was issued to another client.")
+	}
+	if len(codeVerifier) < minCodeVerifierLength || len(codeVerifier) > maxCodeVerifierLength {
+		return nil, oauthError(op, OAuthInvalidGrant, invalidCodeVerifier)
+	}
+	sum := sha256.Sum256([]byte(codeVerifier))
+	challenge := base64.RawURLEncoding.EncodeToString(sum[:])
+	if subtle.ConstantTimeCompare([]byte(challenge), []byte(c.CodeChallenge)) != 1 {
+		return nil, oauthError(op, OAuthInvalidGrant, invalidCodeVerifier)
+	}
+	c.Redeemed = true
+	return Events{EventWithPayload(&AuthorizationCodeRedeemed{
+		TenantID: c.TenantID,
+		ClientID: c.ClientID,
+		Username: c.Username,
+	})}, nil
+}
+
+// AuthorizationCodeRepository is the repository of authorization codes.
+// Redeem will store the code as redeemed only if it was not already, reporting whether it did, so that
+// concurrent token requests cannot redeem the same code twice.
+type AuthorizationCodeRepository interface {
+	Add(*AuthorizationCode) error
+	Redeem(*AuthorizationCode) (bool, error)
+	AuthorizationCodeWithCode(TenantID, string) (*AuthorizationCode, error)
+}
+
+// AuthorizationCodeIssued is the event raised when an authorization code is issued to a client.
+type AuthorizationCodeIssued struct {
+	TenantID TenantID
+	ClientID string
+	Username string
+}
+
+// AuthorizationCodeRedeemed is the event raised when an authorization code is exchanged for tokens.
+type AuthorizationCodeRedeemed struct {
+	TenantID TenantID
+	ClientID string
+	Username string
+}
+
+// OAuthService is the service implementing the OAuth 2.0 authorization server and OpenID Connect provider.
+// ValidateAuthorizationRequest returns the client of a valid request. On errors the client is returned only when
+// the redirect URI was verified, meaning the error may be reported by redirecting to it. UserInfo returns the
+// claims about the user that the scope granted to a client releases.
+type OAuthService interface {
+	ValidateAuthorizationRequest(request *AuthorizationRequest) (*Client, error)
+	Authorize(request *AuthorizationRequest, authentication *Authentication) (string, error)
+	Token(request *TokenRequest) (*TokenResponse, error)
+	UserInfo(tenantID TenantID, username, scope string) (*UserInfo, error)
+}
+
+// NewOAuthService will create a new OAuth service backed by supplied repositories and services.
+func NewOAuthService(
+	tenantRepository TenantRepository,
+	userRepository UserRepository,
+	serviceAccountRepository ServiceAccountRepository,
+	clientRepository ClientRepository,
+	authorizationCodeRepository AuthorizationCodeRepository,
+	sessionService SessionService,
+	tokenService TokenService,
+	eventPublisher EventPublisher,
+) OAuthService {
+	return &oauthService{
+		tenantRepository:            tenantRepository,
+		userRepository:              userRepository,
+		serviceAccountRepository:    serviceAccountRepository,
+		clientRepository:            clientRepository,
+		authorizationCodeRepository: authorizationCodeRepository,
+		sessionService:              sessionService,
+		tokenService:                tokenService,
+		eventPublisher:              eventPublisher,
+	}
+}
+
+type oauthService struct {
+	tenantRepository            TenantRepository
+	userRepository              UserRepository
+	serviceAccountRepository    ServiceAccountRepository
+	clientRepository            ClientRepository
+	authorizationCodeRepository AuthorizationCodeRepository
+	sessionService              SessionService
+	tokenService                TokenService
+	eventPublisher              EventPublisher
+}
+
+// ValidateAuthorizationRequest will check the request of an authorization code, defaulting the redirect URI when
+// the client registered only one. PKCE with S256 is required of every client, and only the scopes of the client
+// may be requested.
+func (s *oauthService) ValidateAuthorizationRequest(request *AuthorizationRequest) (*Client, error) {
+	const op = "ValidateAuthorizationRequest"
+	client, err := s.client(op, request.TenantID, request.ClientID)
+	if err != nil {
+		return nil, err
+	}
+	if request.RedirectURI == "" && len(client.RedirectURIs) == 1 {
+		request.RedirectURI = client.RedirectURIs[0]
+	}
+	if !client.AllowsRedirectURI(request.RedirectURI) {
+		return nil, oauthError(op, OAuthInvalidRequest, "Redirect URI is not registered for the client.")
+	}
+	if request.ResponseType != codeResponseType {
+		return client, oauthError(op, OAuthUnsupportedResponseType, "Only the code response type is supported.")
+	}
+	if !client.AllowsGrant(AuthorizationCodeGrant) {
+		return client, oauthError(op, OAuthUnauthorizedClient, "Client may not use the authorization code grant.")
+	}
+	if !client.AllowsScope(request.Scope) {
+		return client, oauthError(op, OAuthInvalidScope, "Requested scope is not allowed for the client.")
+	}
+	if request.CodeChallenge == "" || request.CodeChallengeMethod != S256CodeChallenge {
+		return client, oauthError(op, OAuthInvalidRequest, "A PKCE code challenge with the S256 method is required.")
+	}
+	return client, nil
+}
+
+// Authorize will issue an authorization code for a valid request of the user of a complete authentication.
+func (s *oauthService) Authorize(request *AuthorizationRequest, authentication *Authentication) (string, error) {
+	const op = "Authorize"
+	if _, err := s.ValidateAuthorizationRequest(request); err != nil {
+		return "", err
+	}
+	if authentication == nil || !authentication.IsComplete() || authentication.User.TenantID != request.TenantID {
+		return "", oauthError(op, OAuthAccessDenied, "Authentication is not complete.")
+	}
+	code, plain, events, err := NewAuthorizationCode(request, authentication.User)
+	if err != nil {
+		return "", err
+	}
+	if err := s.authorizationCodeRepository.Add(code); err != nil {
+		return "", &Error{
+			Code:    EINTERNAL,
+			Message: "An unexpected error occurred while adding authorization code.",
+			Op:      op,
+			Err:     err,
+		}
+	}
+	if err := s.eventPublisher.Publish(events); err != nil {
+		return "", err
+	}
+	return plain, nil
+}
+
+// Token will authenticate the client of the request and perform its grant.
+func (s *oauthService) Token(request *TokenRequest) (*TokenResponse, error) {
+	const op = "Token"
+	client, err := s.authenticateClient(op, request)
+	if err != nil {
+		return nil, err
+	}
+	switch request.GrantType {
+	case AuthorizationCodeGrant, ClientCredentialsGrant, RefreshTokenGrant:
+	default:
+		return nil, oauthError(op, OAuthUnsupportedGrantType, "Unsupported grant type.")
+	}
+	if !client.AllowsGrant(request.GrantType) {
+		return nil, oauthError(op, OAuthUnauthorizedClient, "Client may not use the grant type.")
+	}
+	switch request.GrantType {
+	case AuthorizationCodeGrant:
+		return s.authorizationCodeGrant(request, client)
+	case ClientCredentialsGrant:
+		return s.clientCredentialsGrant(request, client)
+	default:
+		return s.refreshTokenGrant(request, client)
+	}
+}
+
+// authenticateClient will look the client up, verifying the secret of confidential ones.
+func (s *oauthService) authenticateClient(op string, request *TokenRequest) (*Client, error) {
+	client, err := s.client(op, request.TenantID, request.ClientID)
+	if err != nil {
+		if ErrorCode(err) == EINTERNAL {
+			return nil, err
+		}
+		return nil, oauthError(op, OAuthInvalidClient, "Client authentication failed.")
+	}
+	if client.IsConfidential() && !client.VerifySecret(request.ClientSecret) {
+		return nil, oauthError(op, OAuthInvalidClient, "Client authentication failed.")
+	}
+	return client, nil
+}
+
+func (s *oauthService) authorizationCodeGrant(request *TokenRequest, client *Client) (*TokenResponse, error) {
+	const op = "authorizationCodeGrant"
+	code, err := s.authorizationCodeRepository.AuthorizationCodeWithCode(request.TenantID, hashToken(request.Code))
+	if err != nil {
+		return nil, &Error{
+			Code:    EINTERNAL,
+			Message: "An unexpected error occurred while retrieving authorization code.",
+			Op:      op,
+			Err:     err,
+		}
+	}
+	if code == nil {
+		return nil, oauthError(op, OAuthInvalidGrant, "Authorization code is expired or already used.")
+	}
+	events, err := code.Redeem(client.ID, request.RedirectURI, request.CodeVerifier)
+	if err != nil {
+		return nil, err
+	}
+	redeemed, err := s.authorizationCodeRepository.Redeem(code)
+	if err != nil {
+		return nil, &Error{
+			Code:    EINTERNAL,
+			Message: "An unexpected error occurred while redeeming authorization code.",
+			Op:      op,
+			Err:     err,
+		}
+	}
+	if !redeemed {
+		return nil, oauthError(op, OAuthInvalidGrant, "Authorization code is expired or already used.")
+	}
+	if err := s.eventPublisher.Publish(events); err != nil {
+		return nil, err
+	}
+	user, err := s.user(op, code.TenantID, code.Username)
+	if err != nil {
+		return nil, err
+	}
+	authentication := &Authentication{Status: Authenticated, User: user}
+	response, err := s.tokens(client, authentication, &IDTokenGrant{Nonce: code.Nonce, AuthTime: code.IssuedAt}, code.Scope)
+	if err != nil || !client.AllowsGrant(RefreshTokenGrant) {
+		return response, err
+	}
+	metadata := request.Client
+	metadata.ClientID = client.ID
+	metadata.Scope = code.Scope
+	_, refreshToken, err := s.sessionService.StartSession(authentication, metadata)
+	if err != nil {
+		return nil, err
+	}
+	response.RefreshToken = refreshToken
+	return response, nil
+}
+
+func (s *oauthService) clientCredentialsGrant(request *TokenRequest, client *Client) (*TokenResponse, error) {
+	const op = "clientCredentialsGrant"
+	if !client.IsConfidential() {
+		return nil, oauthError(op, OAuthUnauthorizedClient, "Public clients cannot use client credentials.")
+	}
+	if !client.AllowsScope(request.Scope) {
+		return nil, oauthError(op, OAuthInvalidScope, "Requested scope is not allowed for the client.")
+	}
+	account, err := s.serviceAccountRepository.ServiceAccountNamed(client.TenantID, client.ServiceAccount)
+	if err != nil {
+		return nil, &Error{
+			Code:    EINTERNAL,
+			Message: "An unexpected error occurred while retrieving service account.",
+			Op:      op,
+			Err:     err,
+		}
+	}
+	if account == nil || !account.IsEnabled() {
+		return nil, oauthError(op, OAuthUnauthorizedClient, "Service account of the client is not enabled.")
+	}
+	token, err := s.tokenService.IssueServiceAccountAccessToken(account, client.ID, request.Scope)
+	if err != nil {
+		return nil, err
+	}
+	return tokenResponse(token, request.Scope), nil
+}
+
+// refreshTokenGrant will rotate the refresh token of the session, issuing tokens for the scope granted when the
+// session started. A refresh token presented by another client than the one it was issued to has leaked, so its
+// session is revoked.
+func (s *oauthService) refreshTokenGrant(request *TokenRequest, client *Client) (*TokenResponse, error) {
+	const op = "refreshTokenGrant"
+	session, refreshToken, err := s.sessionService.RefreshSession(request.TenantID, request.RefreshToken)
+	if err != nil {
+		if ErrorCode(err) == EUNAUTHORIZED {
+			return nil, oauthError(op, OAuthInvalidGrant, "Refresh token is invalid or expired.")
+		}
+		return nil, err
+	}
+	if session.Client.ClientID != client.ID {
+		if err := s.sessionService.RevokeSession(session.TenantID, session.Username, session.ID); err != nil {
+			return nil, err
+		}
+		return nil, oauthError(op, OAuthInvalidGrant, "Refresh token is invalid or expired.")
+	}
+	user, err := s.user(op, session.TenantID, session.Username)
+	if err != nil {
+		return nil, err
+	}
+	authentication := &Authentication{Status: Authenticated, User: user}
+	response, err := s.tokens(client, authentication, &IDTokenGrant{AuthTime: session.CreatedAt}, session.Client.Scope)
+	if err != nil {
+		return nil, err
+	}
+	response.RefreshToken = refreshToken
+	return response, nil
+}
+
+// tokens will issue the access token for the scope, along with an ID token when the scope asks for it.
+func (s *oauthService) tokens(client *Client, authentication *Authentication, grant *IDTokenGrant, scope string) (*TokenResponse, error) {
+	token, err := s.tokenService.IssueAccessToken(authentication, client.ID, scope)
+	if err != nil {
+		return nil, err
+	}
+	response := tokenResponse(token, scope)
+	if !HasScope(scope, OpenIDScope) {
+		return response, nil
+	}
+	grant.User = authentication.User
+	grant.ClientID = client.ID
+	grant.Scope = scope
+	if response.IDToken, err = s.tokenService.IssueIDToken(grant); err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+// UserInfo will return the claims released by the scope about the user, who must still be enabled.
+func (s *oauthService) UserInfo(tenantID TenantID, username, scope string) (*UserInfo, error) {
+	const op = "UserInfo"
+	if !HasScope(scope, OpenIDScope) {
+		return nil, &Error{Code: EUNAUTHORIZED, Message: "Token was not granted the openid scope.", Op: op}
+	}
+	user, err := s.user(op, tenantID, username)
+	if err != nil {
+		return nil, err
+	}
+	return NewUserInfo(user, scope), nil
+}
+
+func tokenResponse(token *AccessToken, scope string) *TokenResponse {
+	return &TokenResponse{
+		AccessToken: token.Token,
+		TokenType:   token.TokenType,
+		ExpiresIn:   int64(time.Until(token.ExpiresAt).Round(time.Second) / time.Second),
+		Scope:       scope,
+	}
+}
+
+func (s *oauthService) client(op string, tenantID TenantID, clientID string) (*Client, error) {
+	tenant, err := s.tenantRepository.TenantOfID(tenantID)
+	if err != nil {
+		return nil, &Error{
+			Code:    EINTERNAL,
+			Message: "An unexpected error occurred while retrieving tenant.",
+			Op:      op,
+			Err:     err,
+		}
+	}
+	if tenant == nil || !tenant.Active {
+		return nil, oauthError(op, OAuthInvalidRequest, "Unknown client.")
+	}
+	client, err := s.clientRepository.ClientOfID(tenantID, clientID)
+	if err != nil {
+		return nil, &Error{
+			Code:    EINTERNAL,
+			Message: "An unexpected error occurred while retrieving client.",
+			Op:      op,
+			Err:     err,
+		}
+	}
+	if client == nil {
+		return nil, oauthError(op, OAuthInvalidRequest, "Unknown client.")
+	}
+	return client, nil
+}
+
+// user will retrieve the user tokens are issued to, which must still be enabled.
+func (s *oauthService) user(op string, tenantID TenantID, username string) (*User, error) {
+	user, err := s.userRepository.UserWithUsername(tenantID, username)
+	if err != nil {
+		return nil, &Error{
+			Code:    EINTERNAL,
+			Message: "An unexpected error occurred while retrieving user.",
+			Op:      op,
+			Err:     err,
+		}
+	}
+	if user == nil || !user.IsEnabled() {
+		return nil, oauthError(op, OAuthInvalidGrant, "User is not enabled.")
+	}
+	return user, nil
+}
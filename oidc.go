@@ -0,0 +1,97 @@
+package iam
+
+import "strings"
+
+// OpenIDScope is the scope of the requests asking for an ID token.
+// ProfileScope releases the name claims.
+// EmailScope releases the email claim.
+// PhoneScope releases the phone number claim.
+// AddressScope releases the address claim.
+const (
+	OpenIDScope  = "openid"
+	ProfileScope = "profile"
+	EmailScope   = "email"
+	PhoneScope   = "phone"
+	AddressScope = "address"
+)
+
+// HasScope will check if the space delimited scope holds supplied value.
+func HasScope(scope, value string) bool {
+	for _, s := range strings.Fields(scope) {
+		if s == value {
+			return true
+		}
+	}
+	return false
+}
+
+// UserInfo is the value object holding the standard claims released about a user, as defined by OpenID Connect.
+type UserInfo struct {
+	Subject           string   `json:"sub"`
+	Name              string   `json:"name,omitempty"`
+	GivenName         string   `json:"given_name,omitempty"`
+	FamilyName        string   `json:"family_name,omitempty"`
+	PreferredUsername string   `json:"preferred_username,omitempty"`
+	Email             string   `json:"email,omitempty"`
+	PhoneNumber       string   `json:"phone_number,omitempty"`
+	Address           *Address `json:"address,omitempty"`
+}
+
+// Address is the value object holding the address claim, as defined by OpenID Connect.
+type Address struct {
+	Formatted     string `json:"formatted,omitempty"`
+	StreetAddress string `json:"street_address,omitempty"`
+	Locality      string `json:"locality,omitempty"`
+	Region        string `json:"region,omitempty"`
+	PostalCode    string `json:"postal_code,omitempty"`
+	Country       string `json:"country,omitempty"`
+}
+
+// NewUserInfo will map the person of the user to the claims supplied scope releases. The subject is the username,
+// which is unique within the tenant issuing the claims.
+func NewUserInfo(user *User, scope string) *UserInfo {
+	info := &UserInfo{Subject: user.Username}
+	if user.Person == nil {
+		return info
+	}
+	name := user.Person.FullName
+	contact := user.Person.ContactInformation
+	if HasScope(scope, ProfileScope) {
+		info.Name = joinNonEmpty(" ", name.FirstName, name.LastName)
+		info.GivenName = name.FirstName
+		info.FamilyName = name.LastName
+		info.PreferredUsername = user.Username
+	}
+	if HasScope(scope, EmailScope) {
+		info.Email = string(contact.EmailAddress)
+	}
+	if HasScope(scope, PhoneScope) {
+		info.PhoneNumber = string(contact.PrimaryTelephone)
+	}
+	if HasScope(scope, AddressScope) && contact.PostalAddress != (PostalAddress{}) {
+		info.Address = newAddress(contact.PostalAddress)
+	}
+	return info
+}
+
+func newAddress(p PostalAddress) *Address {
+	a := &Address{
+		StreetAddress: joinNonEmpty(" ", p.StreetName, p.BuildingNumber),
+		Locality:      p.Town,
+		Region:        p.StateProvince,
+		PostalCode:    p.PostalCode,
+		Country:       p.CountryCode,
+	}
+	a.Formatted = joinNonEmpty("\n", a.StreetAddress, joinNonEmpty(" ", a.PostalCode, a.Locality), a.Region, a.Country)
+	return a
+}
+
+func joinNonEmpty(sep string, values ...string) string {
+	var parts []string
+	for _, v := range values {
+		if v != "" {
+			parts = append(parts, v)
+		}
+	}
+	return strings.Join(parts, sep)
+}
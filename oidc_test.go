@@ -0,0 +1,73 @@
+package iam_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+
+	. "github.com/maurofran/iam"
+)
+
+var _ = Describe("User info", func() {
+	user := &User{
+		Username: "jdoe",
+		Person: &Person{
+			FullName: FullName{FirstName: "John", LastName: "Doe"},
+			ContactInformation: ContactInformation{
+				EmailAddress:     "jdoe@example.com",
+				PrimaryTelephone: "+39 02 1234567",
+				PostalAddress: PostalAddress{
+					StreetName:     "Via Roma",
+					BuildingNumber: "1",
+					PostalCode:     "20121",
+					Town:           "Milano",
+					StateProvince:  "MI",
+					CountryCode:    "IT",
+				},
+			},
+		},
+	}
+
+	DescribeTable("should release the claims of the scope",
+		func(scope string, expected *UserInfo) {
+			Expect(NewUserInfo(user, scope)).To(Equal(expected))
+		},
+		Entry("openid", "openid", &UserInfo{Subject: "jdoe"}),
+		Entry("profile", "openid profile", &UserInfo{
+			Subject:           "jdoe",
+			Name:              "John Doe",
+			GivenName:         "John",
+			FamilyName:        "Doe",
+			PreferredUsername: "jdoe",
+		}),
+		Entry("email", "openid email", &UserInfo{Subject: "jdoe", Email: "jdoe@example.com"}),
+		Entry("phone", "openid phone", &UserInfo{Subject: "jdoe", PhoneNumber: "+39 02 1234567"}),
+		Entry("address", "openid address", &UserInfo{Subject: "jdoe", Address: &Address{
+			Formatted:     "Via Roma 1\n20121 Milano\nMI\nIT",
+			StreetAddress: "Via Roma 1",
+			Locality:      "Milano",
+			Region:        "MI",
+			PostalCode:    "20121",
+			Country:       "IT",
+		}}),
+	)
+
+	It("should release only the subject of users without person", func() {
+		Expect(NewUserInfo(&User{Username: "jdoe"}, "openid profile email")).To(Equal(&UserInfo{Subject: "jdoe"}))
+	})
+
+	It("should omit an empty address", func() {
+		u := &User{Username: "jdoe", Person: &Person{}}
+		Expect(NewUserInfo(u, "openid address").Address).To(BeNil())
+	})
+
+	DescribeTable("HasScope",
+		func(scope, value string, expected bool) {
+			Expect(HasScope(scope, value)).To(Equal(expected))
+		},
+		Entry("single value", "openid", "openid", true),
+		Entry("among others", "openid  profile email", "profile", true),
+		Entry("prefix of a value", "openid profile", "pro", false),
+		Entry("empty scope", "", "openid", false),
+	)
+})
@@ -0,0 +1,399 @@
+package iam
+
+import (
+	"fmt"
+	"time"
+)
+
+// PasswordlessPolicy is the value object holding the passwordless login rules of a tenant.
+// Login challenges expire after TokenTTL and at most MaxRequests are issued to a user each RateWindow.
+// LinkURL is the address of the page redeeming login links, the token is added as query parameter.
+type PasswordlessPolicy struct {
+	Enabled     bool          `bson:"enabled"`
+	TokenTTL    time.Duration `bson:"tokenTTL"`
+	MaxRequests int           `bson:"maxRequests"`
+	RateWindow  time.Duration `bson:"rateWindow"`
+	LinkURL     string        `bson:"linkURL,omitempty"`
+}
+
+// DefaultPasswordlessPolicy will return the passwordless policy applied to new tenants, disabled until defined.
+func DefaultPasswordlessPolicy() PasswordlessPolicy {
+	return PasswordlessPolicy{
+		TokenTTL:    15 * time.Minute,
+		MaxRequests: 5,
+		RateWindow:  time.Hour,
+	}
+}
+
+// loginCodeDigits is the number of digits of one time login codes.
+const loginCodeDigits = 6
+
+// MaxLoginCodeAttempts is the number of wrong codes after which a login challenge is burned.
+const MaxLoginCodeAttempts = 5
+
+// LoginChallenge is the aggregate root representing a passwordless login request.
+// Token and Code are hashed: the plain ones are only delivered to the email address the challenge is bound to.
+type LoginChallenge struct {
+	ID             string       `bson:"challengeId"`
+	TenantID       TenantID     `bson:"tenantId"`
+	Username       string       `bson:"username"`
+	EmailAddress   EmailAddress `bson:"emailAddress"`
+	Token          string       `bson:"token"`
+	Code           string       `bson:"code"`
+	IssuedAt       time.Time    `bson:"issuedAt"`
+	ExpiresAt      time.Time    `bson:"expiresAt"`
+	FailedAttempts int          `bson:"failedAttempts"`
+	Redeemed       bool         `bson:"redeemed"`
+}
+
+// NewLoginChallenge will create a new login challenge for the user, bound to its email address.
+// It returns the plain token and code to deliver.
+func NewLoginChallenge(user *User, ttl time.Duration) (*LoginChallenge, string, string, Events, error) {
+	const op = "NewLoginChallenge"
+	email := user.emailAddress()
+	if email == "" {
+		return nil, "", "", nil, &Error{Code: EINVALID, Message: "User has no email address.", Op: op}
+	}
+	id, err := newIdentity(op)
+	if err != nil {
+		return nil, "", "", nil, err
+	}
+	token, err := newToken(op)
+	if err != nil {
+		return nil, "", "", nil, err
+	}
+	n, err := randomInt(1000000)
+	if err != nil {
+		return nil, "", "", nil, &Error{
+			Code:    EINTERNAL,
+			Message: "An unexpected error occurred while generating code.",
+			Op:      op,
+			Err:     err,
+		}
+	}
+	code := fmt.Sprintf("%0*d", loginCodeDigits, n)
+	now := time.Now()
+	c := &LoginChallenge{
+		ID:           id,
+		TenantID:     user.TenantID,
+		Username:     user.Username,
+		EmailAddress: email,
+		Token:        hashToken(token),
+		Code:         hashToken(id + ":" + code),
+		IssuedAt:     now,
+		ExpiresAt:    now.Add(ttl),
+	}
+	return c, token, code, Events{EventWithPayload(&LoginChallengeIssued{
+		TenantID:     c.TenantID,
+		Username:     c.Username,
+		EmailAddress: c.EmailAddress,
+		ExpiresAt:    c.ExpiresAt,
+	})}, nil
+}
+
+// IsRedeemable will check if the challenge can still be redeemed by the user.
+// Changing the email address of the user invalidates the challenges sent to the previous one.
+func (c *LoginChallenge) IsRedeemable(user *User) bool {
+	return !c.Redeemed &&
+		c.FailedAttempts < MaxLoginCodeAttempts &&
+		c.ExpiresAt.After(time.Now()) &&
+		user != nil &&
+		user.TenantID == c.TenantID &&
+		user.Username == c.Username &&
+		user.emailAddress() == c.EmailAddress
+}
+
+// RedeemToken will redeem the challenge for the user with the token of the login link.
+func (c *LoginChallenge) RedeemToken(token string, user *User) (Events, error) {
+	const op = "RedeemToken"
+	if !c.IsRedeemable(user) || !tokenMatches(token, c.Token) {
+		return nil, &Error{Code: EUNAUTHORIZED, Message: "Login challenge is invalid or expired.", Op: op}
+	}
+	return c.redeem(), nil
+}
+
+// RedeemCode will redeem the challenge for the user with the one time code.
+// The challenge is burned after too many wrong codes.
+func (c *LoginChallenge) RedeemCode(code string, user *User) (Events, error) {
+	const op = "RedeemCode"
+	if !c.IsRedeemable(user) {
+		return nil, &Error{Code: EUNAUTHORIZED, Message: "Login challenge is invalid or expired.", Op: op}
+	}
+	if !tokenMatches(c.ID+":"+code, c.Code) {
+		c.FailedAttempts++
+		return nil, &Error{Code: EUNAUTHORIZED, Message: "Invalid login code.", Op: op}
+	}
+	return c.redeem(), nil
+}
+
+func (c *LoginChallenge) redeem() Events {
+	c.Redeemed = true
+	return Events{EventWithPayload(&LoginChallengeRedeemed{TenantID: c.TenantID, Username: c.Username})}
+}
+
+// emailAddress will return the email address of the user, empty if unknown.
+func (u *User) emailAddress() EmailAddress {
+	if u.Person == nil {
+		return ""
+	}
+	return u.Person.ContactInformation.EmailAddress
+}
+
+// LoginChallengeIssued is the event raised when a passwordless login challenge is sent to a user.
+type LoginChallengeIssued struct {
+	TenantID     TenantID
+	Username     string
+	EmailAddress EmailAddress
+	ExpiresAt    time.Time
+}
+
+// LoginChallengeRedeemed is the event raised when a user logs in with a passwordless login challenge.
+type LoginChallengeRedeemed struct {
+	TenantID TenantID
+	Username string
+}
+
+// LoginChallengeRepository is the interface for login challenge repository.
+// Redeem and RecordFailedAttempt must atomically update only challenges neither redeemed nor burned by
+// MaxLoginCodeAttempts wrong codes, returning false otherwise, so that concurrent requests cannot redeem a challenge
+// twice or guess more codes than allowed.
+type LoginChallengeRepository interface {
+	Add(*LoginChallenge) error
+	Redeem(*LoginChallenge) (bool, error)
+	RecordFailedAttempt(*LoginChallenge) (bool, error)
+	LoginChallengeWithToken(TenantID, string) (*LoginChallenge, error)
+	LatestLoginChallenge(TenantID, string) (*LoginChallenge, error)
+	CountLoginChallengesSince(TenantID, string, time.Time) (int, error)
+}
+
+// PasswordlessService is the service for logins with a link or code sent by email.
+type PasswordlessService interface {
+	RequestLoginChallenge(tenantID TenantID, emailAddress EmailAddress) error
+	RedeemLoginLink(tenantID TenantID, token string) (*Authentication, error)
+	RedeemLoginCode(tenantID TenantID, emailAddress EmailAddress, code string) (*Authentication, error)
+}
+
+// NewPasswordlessService will create a new passwordless login service backed by supplied repositories and notifier.
+func NewPasswordlessService(
+	tenantRepository TenantRepository,
+	userRepository UserRepository,
+	loginChallengeRepository LoginChallengeRepository,
+	notifier Notifier,
+	eventPublisher EventPublisher,
+) PasswordlessService {
+	return &passwordlessService{
+		authenticationService: authenticationService{
+			tenantRepository: tenantRepository,
+			userRepository:   userRepository,
+			eventPublisher:   eventPublisher,
+		},
+		loginChallengeRepository: loginChallengeRepository,
+		notifier:                 notifier,
+	}
+}
+
+type passwordlessService struct {
+	authenticationService
+	loginChallengeRepository LoginChallengeRepository
+	notifier                 Notifier
+}
+
+// RequestLoginChallenge will send a login link and code to the user with supplied email address.
+// Unknown or disabled users and requests over the rate limit are silently ignored, so that the outcome
+// does not disclose which addresses are registered.
+func (s *passwordlessService) RequestLoginChallenge(tenantID TenantID, emailAddress EmailAddress) error {
+	const op = "RequestLoginChallenge"
+	tenant, err := s.passwordlessTenant(op, tenantID)
+	if err != nil {
+		return err
+	}
+	user, err := s.userWithEmailAddress(op, tenantID, emailAddress)
+	if err != nil || user == nil || !user.IsEnabled() {
+		return err
+	}
+	policy := tenant.PasswordlessPolicy
+	if policy.MaxRequests > 0 {
+		count, err := s.loginChallengeRepository.CountLoginChallengesSince(tenantID, user.Username, time.Now().Add(-policy.RateWindow))
+		if err != nil {
+			return &Error{
+				Code:    EINTERNAL,
+				Message: "An unexpected error occurred while counting login challenges.",
+				Op:      op,
+				Err:     err,
+			}
+		}
+		if count >= policy.MaxRequests {
+			return nil
+		}
+	}
+	challenge, token, code, events, err := NewLoginChallenge(user, policy.TokenTTL)
+	if err != nil {
+		return err
+	}
+	if err := s.loginChallengeRepository.Add(challenge); err != nil {
+		return &Error{
+			Code:    EINTERNAL,
+			Message: "An unexpected error occurred while adding login challenge.",
+			Op:      op,
+			Err:     err,
+		}
+	}
+	err = s.notifier.Notify(Notification{
+		Kind:         LoginChallengeNotification,
+		TenantID:     tenantID,
+		Username:     user.Username,
+		EmailAddress: challenge.EmailAddress,
+		Token:        token,
+		Code:         code,
+		Link:         tokenLink(policy.LinkURL, token),
+		ExpiresAt:    challenge.ExpiresAt,
+	})
+	if err != nil {
+		return &Error{
+			Code:    EINTERNAL,
+			Message: "An unexpected error occurred while sending login challenge.",
+			Op:      op,
+			Err:     err,
+		}
+	}
+	return s.eventPublisher.Publish(events)
+}
+
+// RedeemLoginLink will authenticate the user the login link with supplied token was sent to.
+func (s *passwordlessService) RedeemLoginLink(tenantID TenantID, token string) (*Authentication, error) {
+	const op = "RedeemLoginLink"
+	tenant, err := s.passwordlessTenant(op, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	challenge, err := s.loginChallengeRepository.LoginChallengeWithToken(tenantID, hashToken(token))
+	if err != nil {
+		return nil, &Error{
+			Code:    EINTERNAL,
+			Message: "An unexpected error occurred while retrieving login challenge.",
+			Op:      op,
+			Err:     err,
+		}
+	}
+	if challenge == nil {
+		return nil, &Error{Code: EUNAUTHORIZED, Message: "Login challenge is invalid or expired.", Op: op}
+	}
+	user, err := s.userRepository.UserWithUsername(tenantID, challenge.Username)
+	if err != nil {
+		return nil, &Error{
+			Code:    EINTERNAL,
+			Message: "An unexpected error occurred while retrieving user.",
+			Op:      op,
+			Err:     err,
+		}
+	}
+	return s.redeem(op, tenant, user, challenge, func() (Events, error) {
+		return challenge.RedeemToken(token, user)
+	})
+}
+
+// RedeemLoginCode will authenticate the user with supplied email address with the code sent to it.
+func (s *passwordlessService) RedeemLoginCode(tenantID TenantID, emailAddress EmailAddress, code string) (*Authentication, error) {
+	const op = "RedeemLoginCode"
+	tenant, err := s.passwordlessTenant(op, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	user, err := s.userWithEmailAddress(op, tenantID, emailAddress)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, &Error{Code: EUNAUTHORIZED, Message: "Login challenge is invalid or expired.", Op: op}
+	}
+	challenge, err := s.loginChallengeRepository.LatestLoginChallenge(tenantID, user.Username)
+	if err != nil {
+		return nil, &Error{
+			Code:    EINTERNAL,
+			Message: "An unexpected error occurred while retrieving login challenge.",
+			Op:      op,
+			Err:     err,
+		}
+	}
+	if challenge == nil {
+		return nil, &Error{Code: EUNAUTHORIZED, Message: "Login challenge is invalid or expired.", Op: op}
+	}
+	return s.redeem(op, tenant, user, challenge, func() (Events, error) {
+		return challenge.RedeemCode(code, user)
+	})
+}
+
+// redeem will redeem the login challenge for the user, completing the first factor of the authentication.
+func (s *passwordlessService) redeem(
+	op string,
+	tenant *Tenant,
+	user *User,
+	challenge *LoginChallenge,
+	redeem func() (Events, error),
+) (*Authentication, error) {
+	if user == nil || !user.IsEnabled() || user.IsLockedOut() {
+		return nil, &Error{Code: EUNAUTHORIZED, Message: "Login challenge is invalid or expired.", Op: op}
+	}
+	attempts := challenge.FailedAttempts
+	events, redeemErr := redeem()
+	var updated bool
+	var err error
+	switch {
+	case redeemErr == nil:
+		updated, err = s.loginChallengeRepository.Redeem(challenge)
+	case challenge.FailedAttempts != attempts:
+		updated, err = s.loginChallengeRepository.RecordFailedAttempt(challenge)
+	default:
+		return nil, redeemErr
+	}
+	if err != nil {
+		return nil, &Error{
+			Code:    EINTERNAL,
+			Message: "An unexpected error occurred while updating login challenge.",
+			Op:      op,
+			Err:     err,
+		}
+	}
+	if !updated {
+		return nil, &Error{Code: EUNAUTHORIZED, Message: "Login challenge is invalid or expired.", Op: op}
+	}
+	if redeemErr != nil {
+		return nil, redeemErr
+	}
+	return s.succeed(op, tenant, user, events)
+}
+
+func (s *passwordlessService) passwordlessTenant(op string, tenantID TenantID) (*Tenant, error) {
+	tenant, err := s.tenantRepository.TenantOfID(tenantID)
+	if err != nil {
+		return nil, &Error{
+			Code:    EINTERNAL,
+			Message: "An unexpected error occurred while retrieving tenant.",
+			Op:      op,
+			Err:     err,
+		}
+	}
+	if tenant == nil {
+		return nil, &Error{Code: ENOTFOUND, Message: "Tenant not found.", Op: op}
+	}
+	if err := tenant.assertActive(op); err != nil {
+		return nil, err
+	}
+	if !tenant.PasswordlessPolicy.Enabled {
+		return nil, &Error{Code: ECONFLICT, Message: "Passwordless login is not enabled.", Op: op}
+	}
+	return tenant, nil
+}
+
+func (s *passwordlessService) userWithEmailAddress(op string, tenantID TenantID, emailAddress EmailAddress) (*User, error) {
+	user, err := s.userRepository.UserWithEmailAddress(tenantID, emailAddress)
+	if err != nil {
+		return nil, &Error{
+			Code:    EINTERNAL,
+			Message: "An unexpected error occurred while retrieving user.",
+			Op:      op,
+			Err:     err,
+		}
+	}
+	return user, nil
+}
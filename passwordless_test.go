@@ -0,0 +1,244 @@
+package iam_test
+
+import (
+	"net/url"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/maurofran/iam"
+	"github.com/maurofran/iam/memory"
+	"github.com/maurofran/iam/mock"
+)
+
+var _ = Describe("Passwordless service", func() {
+	const email = EmailAddress("jdoe@example.com")
+
+	var (
+		tenant     *Tenant
+		user       *User
+		challenges []*LoginChallenge
+		lcr        *mock.LoginChallengeRepository
+		notifier   *memory.Notifier
+		events     Events
+		service    PasswordlessService
+	)
+
+	BeforeEach(func() {
+		var err error
+		tenant = &Tenant{ID: "tenant", Active: true, PasswordlessPolicy: DefaultPasswordlessPolicy()}
+		tenant.PasswordlessPolicy.Enabled = true
+		tenant.PasswordlessPolicy.LinkURL = "https://login.example.com/passwordless?lang=en"
+		person := &Person{ContactInformation: ContactInformation{EmailAddress: email}}
+		user, _, err = NewUser("tenant", "jdoe", "gV7#pLq2!wZx", person, DefaultPasswordPolicy(), nil)
+		Expect(err).NotTo(HaveOccurred())
+		challenges = nil
+		var mu sync.Mutex
+		// Challenges are loaded as copies and updated only while redeemable, as the repository does atomically.
+		load := func(match func(*LoginChallenge) bool) *LoginChallenge {
+			mu.Lock()
+			defer mu.Unlock()
+			for i := len(challenges) - 1; i >= 0; i-- {
+				if match(challenges[i]) {
+					c := *challenges[i]
+					return &c
+				}
+			}
+			return nil
+		}
+		update := func(c *LoginChallenge, apply func(*LoginChallenge)) (bool, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			for _, stored := range challenges {
+				if stored.ID == c.ID && !stored.Redeemed && stored.FailedAttempts < MaxLoginCodeAttempts {
+					apply(stored)
+					return true, nil
+				}
+			}
+			return false, nil
+		}
+		tr := &mock.TenantRepository{
+			TenantOfIDFn: func(TenantID) (*Tenant, error) {
+				return tenant, nil
+			},
+		}
+		ur := &mock.UserRepository{
+			UserWithUsernameFn: func(_ TenantID, username string) (*User, error) {
+				if username == user.Username {
+					return user, nil
+				}
+				return nil, nil
+			},
+			UserWithEmailAddressFn: func(_ TenantID, emailAddress EmailAddress) (*User, error) {
+				if emailAddress == user.Person.ContactInformation.EmailAddress {
+					return user, nil
+				}
+				return nil, nil
+			},
+			UpdateFn: func(*User) error {
+				return nil
+			},
+		}
+		lcr = &mock.LoginChallengeRepository{
+			AddFn: func(c *LoginChallenge) error {
+				challenges = append(challenges, c)
+				return nil
+			},
+			RedeemFn: func(c *LoginChallenge) (bool, error) {
+				return update(c, func(stored *LoginChallenge) { stored.Redeemed = true })
+			},
+			RecordFailedAttemptFn: func(c *LoginChallenge) (bool, error) {
+				return update(c, func(stored *LoginChallenge) { stored.FailedAttempts++ })
+			},
+			LoginChallengeWithTokenFn: func(_ TenantID, token string) (*LoginChallenge, error) {
+				return load(func(c *LoginChallenge) bool { return c.Token == token }), nil
+			},
+			LatestLoginChallengeFn: func(TenantID, string) (*LoginChallenge, error) {
+				return load(func(*LoginChallenge) bool { return true }), nil
+			},
+			CountLoginChallengesSinceFn: func(_ TenantID, _ string, since time.Time) (int, error) {
+				n := 0
+				for _, c := range challenges {
+					if !c.IssuedAt.Before(since) {
+						n++
+					}
+				}
+				return n, nil
+			},
+		}
+		events = nil
+		ep := &mock.EventPublisher{
+			PublishFn: func(ee Events) error {
+				events = append(events, ee...)
+				return nil
+			},
+		}
+		notifier = memory.NewNotifier()
+		service = NewPasswordlessService(tr, ur, lcr, notifier, ep)
+	})
+
+	request := func() Notification {
+		Expect(service.RequestLoginChallenge(tenant.ID, email)).To(Succeed())
+		n, ok := notifier.Last(email)
+		Expect(ok).To(BeTrue())
+		return n
+	}
+
+	Describe("#RequestLoginChallenge", func() {
+		It("should send a link and a code", func() {
+			n := request()
+			Expect(n.Kind).To(Equal(LoginChallengeNotification))
+			Expect(n.Code).To(HaveLen(6))
+			link, err := url.Parse(n.Link)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(link.Query().Get("token")).To(Equal(n.Token))
+			Expect(link.Query().Get("lang")).To(Equal("en"))
+			Expect(events[0].Type).To(Equal("LoginChallengeIssued"))
+		})
+		It("should store the secrets hashed", func() {
+			n := request()
+			Expect(challenges[0].Token).NotTo(Equal(n.Token))
+			Expect(challenges[0].Code).NotTo(Equal(n.Code))
+		})
+		It("should silently ignore unknown email addresses", func() {
+			Expect(service.RequestLoginChallenge(tenant.ID, "unknown@example.com")).To(Succeed())
+			Expect(challenges).To(BeEmpty())
+		})
+		It("should rate limit requests", func() {
+			for i := 0; i < 10; i++ {
+				Expect(service.RequestLoginChallenge(tenant.ID, email)).To(Succeed())
+			}
+			Expect(notifier.Notifications(email)).To(HaveLen(5))
+		})
+		It("should reject tenants without passwordless login", func() {
+			tenant.PasswordlessPolicy.Enabled = false
+			Expect(ErrorCode(service.RequestLoginChallenge(tenant.ID, email))).To(Equal(ECONFLICT))
+		})
+	})
+
+	Describe("#RedeemLoginLink", func() {
+		It("should authenticate the user once", func() {
+			n := request()
+			a, err := service.RedeemLoginLink(tenant.ID, n.Token)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(a.IsComplete()).To(BeTrue())
+			Expect(a.User).To(Equal(user))
+			_, err = service.RedeemLoginLink(tenant.ID, n.Token)
+			Expect(ErrorCode(err)).To(Equal(EUNAUTHORIZED))
+		})
+		It("should authenticate the user once under concurrent redemptions", func() {
+			n := request()
+			// A concurrent redemption loads the challenge before the first one stores it as redeemed.
+			concurrent := *challenges[0]
+			_, err := service.RedeemLoginLink(tenant.ID, n.Token)
+			Expect(err).NotTo(HaveOccurred())
+			published := len(events)
+			lcr.LoginChallengeWithTokenFn = func(TenantID, string) (*LoginChallenge, error) {
+				return &concurrent, nil
+			}
+			_, err = service.RedeemLoginLink(tenant.ID, n.Token)
+			Expect(err).To(Equal(&Error{Code: EUNAUTHORIZED, Message: "Login challenge is invalid or expired.", Op: "RedeemLoginLink"}))
+			Expect(events).To(HaveLen(published))
+		})
+		It("should reject expired links", func() {
+			n := request()
+			challenges[0].ExpiresAt = time.Now().Add(-time.Second)
+			_, err := service.RedeemLoginLink(tenant.ID, n.Token)
+			Expect(ErrorCode(err)).To(Equal(EUNAUTHORIZED))
+		})
+		It("should reject links sent to a previous email address", func() {
+			n := request()
+			user.ChangeContactInformation(ContactInformation{EmailAddress: "john@example.com"})
+			_, err := service.RedeemLoginLink(tenant.ID, n.Token)
+			Expect(ErrorCode(err)).To(Equal(EUNAUTHORIZED))
+		})
+	})
+
+	Describe("#RedeemLoginCode", func() {
+		It("should authenticate the user", func() {
+			n := request()
+			a, err := service.RedeemLoginCode(tenant.ID, email, n.Code)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(a.IsComplete()).To(BeTrue())
+		})
+		It("should burn the challenge after too many wrong codes", func() {
+			n := request()
+			for i := 0; i < 5; i++ {
+				_, err := service.RedeemLoginCode(tenant.ID, email, "wrong")
+				Expect(ErrorCode(err)).To(Equal(EUNAUTHORIZED))
+			}
+			_, err := service.RedeemLoginCode(tenant.ID, email, n.Code)
+			Expect(ErrorCode(err)).To(Equal(EUNAUTHORIZED))
+		})
+		It("should burn the challenge after too many concurrent wrong codes", func() {
+			n := request()
+			var wg sync.WaitGroup
+			for i := 0; i < 4*MaxLoginCodeAttempts; i++ {
+				wg.Add(1)
+				go func() {
+					defer GinkgoRecover()
+					defer wg.Done()
+					_, err := service.RedeemLoginCode(tenant.ID, email, "wrong")
+					Expect(ErrorCode(err)).To(Equal(EUNAUTHORIZED))
+				}()
+			}
+			wg.Wait()
+			Expect(challenges[0].FailedAttempts).To(Equal(MaxLoginCodeAttempts))
+			_, err := service.RedeemLoginCode(tenant.ID, email, n.Code)
+			Expect(ErrorCode(err)).To(Equal(EUNAUTHORIZED))
+		})
+		It("should not redeem a challenge burned by concurrent wrong codes", func() {
+			n := request()
+			// The redemption loads the challenge before concurrent wrong codes burn it.
+			stale := *challenges[0]
+			challenges[0].FailedAttempts = MaxLoginCodeAttempts
+			lcr.LatestLoginChallengeFn = func(TenantID, string) (*LoginChallenge, error) {
+				return &stale, nil
+			}
+			_, err := service.RedeemLoginCode(tenant.ID, email, n.Code)
+			Expect(err).To(Equal(&Error{Code: EUNAUTHORIZED, Message: "Login challenge is invalid or expired.", Op: "RedeemLoginCode"}))
+		})
+	})
+})
@@ -0,0 +1,185 @@
+package iam
+
+import (
+	"strings"
+	"time"
+	"unicode"
+)
+
+// PasswordPolicy is the value object holding the password rules of a tenant.
+// MaxLength is the maximum number of characters, 0 for no limit.
+// MinStrength is the minimum score, from 0 to 4, estimated by EstimatePasswordStrength.
+// BannedWords are words, such as the organization or product names, that make a password easier to guess.
+// ResetTokenTTL is the validity of password reset tokens, ResetLinkURL the address of the page resetting
+// the password, the token is added as query parameter.
+type PasswordPolicy struct {
+	MinLength                 int           `bson:"minLength"`
+	MaxLength                 int           `bson:"maxLength"`
+	RequireUppers             bool          `bson:"requireUppers"`
+	RequireLowers             bool          `bson:"requireLowers"`
+	RequireDigits             bool          `bson:"requireDigits"`
+	RequireSymbols            bool          `bson:"requireSymbols"`
+	MinStrength               int           `bson:"minStrength"`
+	MaxRepeated               int           `bson:"maxRepeated"`
+	ForbidPersonalInformation bool          `bson:"forbidPersonalInformation"`
+	BannedWords               []string      `bson:"bannedWords,omitempty"`
+	HistoryDepth              int           `bson:"historyDepth"`
+	MaxAge                    time.Duration `bson:"maxAge"`
+	ResetTokenTTL             time.Duration `bson:"resetTokenTTL"`
+	ResetLinkURL              string        `bson:"resetLinkURL,omitempty"`
+}
+
+// DefaultPasswordPolicy will return the password policy applied to new tenants.
+func DefaultPasswordPolicy() PasswordPolicy {
+	return PasswordPolicy{
+		MinLength:                 8,
+		MaxLength:                 128,
+		MinStrength:               strongScore,
+		MaxRepeated:               3,
+		ForbidPersonalInformation: true,
+		HistoryDepth:              5,
+		ResetTokenTTL:             time.Hour,
+	}
+}
+
+// PasswordRule is the identifier of a password policy rule.
+type PasswordRule string
+
+// PasswordMinLength is the rule on password length.
+// PasswordMaxLength is the rule bounding password length.
+// PasswordUppers is the rule requiring upper case letters.
+// PasswordLowers is the rule requiring lower case letters.
+// PasswordDigits is the rule requiring digits.
+// PasswordSymbols is the rule requiring symbols.
+// PasswordMinStrength is the rule on password strength.
+// PasswordMaxRepeated is the rule on consecutive repeated characters.
+// PasswordPersonalInformation is the rule forbidding username, name and email in password.
+// PasswordHistory is the rule forbidding the reuse of current and previous passwords.
+// PasswordBreached is the rule forbidding passwords exposed in known data breaches.
+const (
+	PasswordMinLength           PasswordRule = "minLength"
+	PasswordMaxLength           PasswordRule = "maxLength"
+	PasswordUppers              PasswordRule = "uppers"
+	PasswordLowers              PasswordRule = "lowers"
+	PasswordDigits              PasswordRule = "digits"
+	PasswordSymbols             PasswordRule = "symbols"
+	PasswordMinStrength         PasswordRule = "minStrength"
+	PasswordMaxRepeated         PasswordRule = "maxRepeated"
+	PasswordPersonalInformation PasswordRule = "personalInformation"
+	PasswordHistory             PasswordRule = "history"
+	PasswordBreached            PasswordRule = "breached"
+)
+
+// PasswordPolicyViolation is the error listing every password policy rule not satisfied,
+// along with the suggestions of the strength estimator.
+type PasswordPolicyViolation struct {
+	Rules    []PasswordRule
+	Feedback []string
+}
+
+func (v *PasswordPolicyViolation) Error() string {
+	rules := make([]string, len(v.Rules))
+	for i, r := range v.Rules {
+		rules[i] = string(r)
+	}
+	return "password policy violated: " + strings.Join(rules, ", ")
+}
+
+// Validate will validate supplied password for the user against the policy.
+// Passwords exceeding the maximum length are rejected before any other rule is checked.
+func (p PasswordPolicy) Validate(password string, user *User) error {
+	length := len([]rune(password))
+	if p.MaxLength > 0 && length > p.MaxLength {
+		return &PasswordPolicyViolation{Rules: []PasswordRule{PasswordMaxLength}}
+	}
+	var rules []PasswordRule
+	if length < p.MinLength {
+		rules = append(rules, PasswordMinLength)
+	}
+	var uppers, lowers, digits, symbols int
+	for _, ch := range password {
+		switch {
+		case unicode.IsUpper(ch):
+			uppers++
+		case unicode.IsLetter(ch):
+			lowers++
+		case unicode.IsDigit(ch):
+			digits++
+		default:
+			symbols++
+		}
+	}
+	if p.RequireUppers && uppers == 0 {
+		rules = append(rules, PasswordUppers)
+	}
+	if p.RequireLowers && lowers == 0 {
+		rules = append(rules, PasswordLowers)
+	}
+	if p.RequireDigits && digits == 0 {
+		rules = append(rules, PasswordDigits)
+	}
+	if p.RequireSymbols && symbols == 0 {
+		rules = append(rules, PasswordSymbols)
+	}
+	strength := EstimatePasswordStrength(password, append(personalInformation(user), p.BannedWords...)...)
+	if strength.Score < p.MinStrength {
+		rules = append(rules, PasswordMinStrength)
+	}
+	if p.MaxRepeated > 0 && maxRepeated(password) > p.MaxRepeated {
+		rules = append(rules, PasswordMaxRepeated)
+	}
+	if password == user.Username || (p.ForbidPersonalInformation && containsPersonalInformation(password, user)) {
+		rules = append(rules, PasswordPersonalInformation)
+	}
+	if p.HistoryDepth > 0 && user.isPasswordReused(password, p.HistoryDepth) {
+		rules = append(rules, PasswordHistory)
+	}
+	if len(rules) > 0 {
+		return &PasswordPolicyViolation{Rules: rules, Feedback: strength.Feedback}
+	}
+	return nil
+}
+
+func maxRepeated(password string) int {
+	max, count := 0, 0
+	var last rune
+	for i, ch := range []rune(password) {
+		if i > 0 && ch == last {
+			count++
+		} else {
+			count = 1
+		}
+		if count > max {
+			max = count
+		}
+		last = ch
+	}
+	return max
+}
+
+// minPersonalInformationLength is the minimum length of personal fragments searched in passwords,
+// so that very short names do not reject most passwords.
+const minPersonalInformationLength = 3
+
+// personalInformation will return username, names and email local part of the user.
+func personalInformation(user *User) []string {
+	fragments := []string{user.Username}
+	if user.Person != nil {
+		email := string(user.Person.ContactInformation.EmailAddress)
+		if i := strings.Index(email, "@"); i >= 0 {
+			email = email[:i]
+		}
+		fragments = append(fragments, user.Person.FullName.FirstName, user.Person.FullName.LastName, email)
+	}
+	return fragments
+}
+
+func containsPersonalInformation(password string, user *User) bool {
+	lower := strings.ToLower(password)
+	for _, f := range personalInformation(user) {
+		if len(f) >= minPersonalInformationLength && strings.Contains(lower, strings.ToLower(f)) {
+			return true
+		}
+	}
+	return false
+}
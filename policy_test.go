@@ -0,0 +1,120 @@
+package iam_test
+
+import (
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/maurofran/iam"
+)
+
+var _ = Describe("Password policy", func() {
+	var (
+		policy PasswordPolicy
+		user   *User
+	)
+
+	BeforeEach(func() {
+		policy = PasswordPolicy{
+			MinLength:                 10,
+			RequireUppers:             true,
+			RequireLowers:             true,
+			RequireDigits:             true,
+			RequireSymbols:            true,
+			MinStrength:               3,
+			MaxRepeated:               2,
+			ForbidPersonalInformation: true,
+		}
+		user = &User{
+			Username: "jdoe",
+			Person: &Person{
+				FullName:           FullName{FirstName: "John", LastName: "Doe"},
+				ContactInformation: ContactInformation{EmailAddress: "johnny@example.com"},
+			},
+		}
+	})
+
+	Describe("#Validate", func() {
+		It("should accept a compliant password", func() {
+			Expect(policy.Validate("Tr0ub4dor&3-horse", user)).To(Succeed())
+		})
+		It("should list every failed rule", func() {
+			err := policy.Validate("johnnyyy", user)
+			Expect(err).To(BeAssignableToTypeOf(&PasswordPolicyViolation{}))
+			Expect(err.(*PasswordPolicyViolation).Rules).To(ConsistOf(
+				PasswordMinLength,
+				PasswordUppers,
+				PasswordDigits,
+				PasswordSymbols,
+				PasswordMinStrength,
+				PasswordMaxRepeated,
+				PasswordPersonalInformation,
+			))
+		})
+		It("should reject long passwords before any other rule", func() {
+			policy.MaxLength = 64
+			err := policy.Validate(strings.Repeat("a", 65), user)
+			Expect(err.(*PasswordPolicyViolation).Rules).To(ConsistOf(PasswordMaxLength))
+		})
+		It("should forbid names regardless of case", func() {
+			err := policy.Validate("Kx7#qZ2v-JOHN", user)
+			Expect(err.(*PasswordPolicyViolation).Rules).To(ConsistOf(PasswordPersonalInformation))
+		})
+		It("should weaken passwords built on banned words", func() {
+			policy.MinStrength = 4
+			Expect(policy.Validate("Zorblax-Kw9v", user)).To(Succeed())
+			policy.BannedWords = []string{"Zorblax"}
+			err := policy.Validate("Zorblax-Kw9v", user)
+			Expect(err.(*PasswordPolicyViolation).Rules).To(ConsistOf(PasswordMinStrength))
+			Expect(err.(*PasswordPolicyViolation).Feedback).NotTo(BeEmpty())
+		})
+	})
+
+	Describe("User#ChangePassword", func() {
+		It("should return the violation as cause", func() {
+			u, _, err := NewUser("tenant", "jdoe", "gV7#pLq2!wZx", nil, policy, nil)
+			Expect(err).NotTo(HaveOccurred())
+			_, err = u.ChangePassword("gV7#pLq2!wZx", "short", policy, nil)
+			Expect(ErrorCode(err)).To(Equal(EINVALID))
+			Expect(err.(*Error).Err).To(BeAssignableToTypeOf(&PasswordPolicyViolation{}))
+		})
+	})
+})
+
+var _ = Describe("Password history", func() {
+	var (
+		policy PasswordPolicy
+		u      *User
+	)
+
+	BeforeEach(func() {
+		var err error
+		policy = DefaultPasswordPolicy()
+		policy.HistoryDepth = 2
+		u, _, err = NewUser("tenant", "jdoe", "first-Kx7#qZ2v", nil, policy, nil)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("should reject recently used passwords", func() {
+		_, err := u.ChangePassword("first-Kx7#qZ2v", "second-Kx7#qZ2v", policy, nil)
+		Expect(err).NotTo(HaveOccurred())
+		_, err = u.ChangePassword("second-Kx7#qZ2v", "first-Kx7#qZ2v", policy, nil)
+		Expect(ErrorCode(err)).To(Equal(EINVALID))
+		Expect(err.(*Error).Err.(*PasswordPolicyViolation).Rules).To(ConsistOf(PasswordHistory))
+	})
+
+	It("should accept passwords older than the history depth", func() {
+		for _, p := range [][2]string{
+			{"first-Kx7#qZ2v", "second-Kx7#qZ2v"},
+			{"second-Kx7#qZ2v", "third-Kx7#qZ2v"},
+			{"third-Kx7#qZ2v", "fourth-Kx7#qZ2v"},
+		} {
+			_, err := u.ChangePassword(p[0], p[1], policy, nil)
+			Expect(err).NotTo(HaveOccurred())
+		}
+		Expect(u.PasswordHistory).To(HaveLen(2))
+		_, err := u.ChangePassword("fourth-Kx7#qZ2v", "first-Kx7#qZ2v", policy, nil)
+		Expect(err).NotTo(HaveOccurred())
+	})
+})
@@ -0,0 +1,171 @@
+package iam
+
+// TenantProvisioningService is the service used to bootstrap new tenants.
+type TenantProvisioningService interface {
+	ProvisionTenant(
+		name, description string,
+		administratorName FullName,
+		emailAddress EmailAddress,
+		postalAddress PostalAddress,
+		primaryTelephone, secondaryTelephone Telephone,
+	) (*Tenant, string, error)
+}
+
+// AdministratorUsername is the username of the administrator registered with each tenant.
+// AdministratorRoleName is the name of the role granted to the tenant administrator.
+const (
+	AdministratorUsername = "admin"
+	AdministratorRoleName = "Administrator"
+)
+
+// NewTenantProvisioningService will create a new tenant provisioning service backed by supplied repositories.
+// Administrator passwords found by the breached password checker are rejected, a nil checker disables the check.
+func NewTenantProvisioningService(
+	tenantRepository TenantRepository,
+	userRepository UserRepository,
+	roleRepository RoleRepository,
+	breachedPasswords BreachedPasswordChecker,
+	eventPublisher EventPublisher,
+) TenantProvisioningService {
+	return &tenantProvisioningService{
+		tenantRepository:  tenantRepository,
+		userRepository:    userRepository,
+		roleRepository:    roleRepository,
+		breachedPasswords: breachedPasswords,
+		eventPublisher:    eventPublisher,
+	}
+}
+
+type tenantProvisioningService struct {
+	tenantRepository  TenantRepository
+	userRepository    UserRepository
+	roleRepository    RoleRepository
+	breachedPasswords BreachedPasswordChecker
+	eventPublisher    EventPublisher
+}
+
+// ProvisionTenant will create an active tenant with an administrator user holding the administrator role,
+// returning the temporary password of the administrator, that must be changed at first login.
+// When a step fails the previously stored objects are removed and no event is published.
+func (s *tenantProvisioningService) ProvisionTenant(
+	name, description string,
+	administratorName FullName,
+	emailAddress EmailAddress,
+	postalAddress PostalAddress,
+	primaryTelephone, secondaryTelephone Telephone,
+) (*Tenant, string, error) {
+	const op = "ProvisionTenant"
+	existing, err := s.tenantRepository.TenantNamed(name)
+	if err != nil {
+		return nil, "", &Error{
+			Code:    EINTERNAL,
+			Message: "An unexpected error occurred while retrieving tenant.",
+			Op:      op,
+			Err:     err,
+		}
+	}
+	if existing != nil {
+		return nil, "", &Error{Code: ECONFLICT, Message: "A tenant with the same name already exists.", Op: op}
+	}
+	id, err := NewTenantID()
+	if err != nil {
+		return nil, "", err
+	}
+	tenant := &Tenant{
+		ID:                 id,
+		Name:               name,
+		Description:        description,
+		Active:             true,
+		PasswordPolicy:     DefaultPasswordPolicy(),
+		LockoutPolicy:      DefaultLockoutPolicy(),
+		PasswordlessPolicy: DefaultPasswordlessPolicy(),
+	}
+	events := Events{EventWithPayload(&TenantProvisioned{TenantID: id, Name: name})}
+
+	password, err := GeneratePassword(tenant.PasswordPolicy)
+	if err != nil {
+		return nil, "", err
+	}
+	admin, userEvents, err := NewUser(string(id), AdministratorUsername, password, &Person{
+		FullName: administratorName,
+		ContactInformation: ContactInformation{
+			EmailAddress:       emailAddress,
+			PostalAddress:      postalAddress,
+			PrimaryTelephone:   primaryTelephone,
+			SecondaryTelephone: secondaryTelephone,
+		},
+	}, tenant.PasswordPolicy, s.breachedPasswords)
+	if err != nil {
+		return nil, "", err
+	}
+	events = append(events, userEvents...)
+	events = append(events, admin.RequirePasswordChange()...)
+
+	role, roleEvents, err := tenant.ProvisionRole(AdministratorRoleName, "Default "+name+" administrator.", false)
+	if err != nil {
+		return nil, "", err
+	}
+	events = append(events, roleEvents...)
+	assignEvents, err := role.AssignUser(admin)
+	if err != nil {
+		return nil, "", err
+	}
+	events = append(events, assignEvents...)
+	events = append(events, EventWithPayload(&TenantAdministratorRegistered{
+		TenantID:          id,
+		Name:              name,
+		AdministratorName: administratorName,
+		EmailAddress:      emailAddress,
+		Username:          AdministratorUsername,
+	}))
+
+	if err := s.tenantRepository.Add(tenant); err != nil {
+		return nil, "", &Error{
+			Code:    EINTERNAL,
+			Message: "An unexpected error occurred while adding tenant.",
+			Op:      op,
+			Err:     err,
+		}
+	}
+	if err := s.userRepository.Add(admin); err != nil {
+		s.tenantRepository.Remove(tenant)
+		return nil, "", &Error{
+			Code:    EINTERNAL,
+			Message: "An unexpected error occurred while adding administrator.",
+			Op:      op,
+			Err:     err,
+		}
+	}
+	if err := s.roleRepository.Add(role); err != nil {
+		s.userRepository.Remove(admin)
+		s.tenantRepository.Remove(tenant)
+		return nil, "", &Error{
+			Code:    EINTERNAL,
+			Message: "An unexpected error occurred while adding administrator role.",
+			Op:      op,
+			Err:     err,
+		}
+	}
+	if err := s.eventPublisher.Publish(events); err != nil {
+		s.roleRepository.Remove(role)
+		s.userRepository.Remove(admin)
+		s.tenantRepository.Remove(tenant)
+		return nil, "", err
+	}
+	return tenant, password, nil
+}
+
+// TenantProvisioned is the event raised when a new tenant is provisioned.
+type TenantProvisioned struct {
+	TenantID TenantID
+	Name     string
+}
+
+// TenantAdministratorRegistered is the event raised when the administrator of a new tenant is registered.
+type TenantAdministratorRegistered struct {
+	TenantID          TenantID
+	Name              string
+	AdministratorName FullName
+	EmailAddress      EmailAddress
+	Username          string
+}
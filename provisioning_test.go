@@ -0,0 +1,99 @@
+package iam_test
+
+import (
+	"errors"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/maurofran/iam"
+	"github.com/maurofran/iam/mock"
+)
+
+var _ = Describe("Tenant provisioning service", func() {
+	var (
+		tr      *mock.TenantRepository
+		ur      *mock.UserRepository
+		rr      *mock.RoleRepository
+		ep      *mock.EventPublisher
+		users   Users
+		roles   Roles
+		events  Events
+		service TenantProvisioningService
+	)
+
+	BeforeEach(func() {
+		users, roles, events = nil, nil, nil
+		tr = &mock.TenantRepository{
+			TenantNamedFn: func(string) (*Tenant, error) { return nil, nil },
+			AddFn:         func(*Tenant) error { return nil },
+			RemoveFn:      func(*Tenant) error { return nil },
+		}
+		ur = &mock.UserRepository{
+			AddFn: func(u *User) error {
+				users = append(users, u)
+				return nil
+			},
+			RemoveFn: func(*User) error { return nil },
+		}
+		rr = &mock.RoleRepository{
+			AddFn: func(r *Role) error {
+				roles = append(roles, r)
+				return nil
+			},
+			RemoveFn: func(*Role) error { return nil },
+		}
+		ep = &mock.EventPublisher{
+			PublishFn: func(ee Events) error {
+				events = append(events, ee...)
+				return nil
+			},
+		}
+		service = NewTenantProvisioningService(tr, ur, rr, nil, ep)
+	})
+
+	Describe("#ProvisionTenant", func() {
+		provision := func() (*Tenant, string, error) {
+			return service.ProvisionTenant("Acme", "Acme Inc.", FullName{FirstName: "John", LastName: "Doe"},
+				"jdoe@acme.com", PostalAddress{}, "", "")
+		}
+
+		It("should provision an active tenant with an administrator", func() {
+			tenant, password, err := provision()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(tenant.ID).NotTo(BeEmpty())
+			Expect(tenant.Active).To(BeTrue())
+			Expect(users).To(HaveLen(1))
+			Expect(roles).To(HaveLen(1))
+			Expect(roles[0].Name).To(Equal(AdministratorRoleName))
+			Expect(roles[0].IsInRole(users[0], nil)).To(BeTrue())
+
+			var registered *TenantAdministratorRegistered
+			types := []string{}
+			for _, e := range events {
+				types = append(types, e.Type)
+				if p, ok := e.Payload.(*TenantAdministratorRegistered); ok {
+					registered = p
+				}
+			}
+			Expect(types).To(ContainElement("TenantProvisioned"))
+			Expect(registered).NotTo(BeNil())
+			Expect(users[0].VerifyPassword(password)).To(BeTrue())
+			Expect(users[0].MustChangePassword).To(BeTrue())
+		})
+		It("should refuse a duplicate tenant name", func() {
+			tr.TenantNamedFn = func(string) (*Tenant, error) { return &Tenant{Name: "Acme"}, nil }
+			_, _, err := provision()
+			Expect(ErrorCode(err)).To(Equal(ECONFLICT))
+			Expect(tr.AddInvoked).To(BeFalse())
+		})
+		It("should roll back when a step fails", func() {
+			rr.AddFn = func(*Role) error { return errors.New("boom") }
+			_, _, err := provision()
+			Expect(ErrorCode(err)).To(Equal(EINTERNAL))
+			Expect(ur.RemoveInvoked).To(BeTrue())
+			Expect(tr.RemoveInvoked).To(BeTrue())
+			Expect(ep.PublishInvoked).To(BeFalse())
+		})
+	})
+})
@@ -0,0 +1,230 @@
+package iam
+
+import "time"
+
+// defaultResetTokenTTL is the validity of password reset tokens when the tenant policy does not define one.
+// passwordResetResponseTime is the minimum duration of a password reset request, so that requests for
+// known and unknown users cannot be told apart by their timing.
+const (
+	defaultResetTokenTTL      = time.Hour
+	passwordResetResponseTime = 250 * time.Millisecond
+)
+
+// PasswordReset is the value object holding a pending password reset of a user.
+// Token is the hash of the opaque token delivered to the user.
+type PasswordReset struct {
+	Token     string    `bson:"token"`
+	ExpiresAt time.Time `bson:"expiresAt"`
+}
+
+// RequestPasswordReset will issue a new password reset token valid for ttl, replacing any previous one.
+// Only the hash of the returned token is kept.
+func (u *User) RequestPasswordReset(ttl time.Duration) (string, Events, error) {
+	token, err := newToken("RequestPasswordReset")
+	if err != nil {
+		return "", nil, err
+	}
+	u.PasswordReset = &PasswordReset{Token: hashToken(token), ExpiresAt: time.Now().Add(ttl)}
+	return token, Events{EventWithPayload(&UserPasswordResetRequested{
+		TenantID:  u.TenantID,
+		Username:  u.Username,
+		ExpiresAt: u.PasswordReset.ExpiresAt,
+	})}, nil
+}
+
+// ResetPassword will set a new password validated against the policy, consuming the password reset token.
+func (u *User) ResetPassword(
+	token, password string,
+	policy PasswordPolicy,
+	breachedPasswords BreachedPasswordChecker,
+) (Events, error) {
+	const op = "ResetPassword"
+	if u.PasswordReset == nil || !u.PasswordReset.ExpiresAt.After(time.Now()) || !tokenMatches(token, u.PasswordReset.Token) {
+		return nil, &Error{Code: EUNAUTHORIZED, Message: "Password reset token is invalid or expired.", Op: op}
+	}
+	if err := u.protectPassword(op, password, policy, breachedPasswords); err != nil {
+		return nil, err
+	}
+	u.PasswordReset = nil
+	return Events{EventWithPayload(&UserPasswordReset{
+		TenantID: u.TenantID,
+		Username: u.Username,
+	})}, nil
+}
+
+// UserPasswordResetRequested is the event raised when a password reset token is issued to a user.
+type UserPasswordResetRequested struct {
+	TenantID  TenantID
+	Username  string
+	ExpiresAt time.Time
+}
+
+// UserPasswordReset is the event raised when the password of a user is reset with a token.
+type UserPasswordReset struct {
+	TenantID TenantID
+	Username string
+}
+
+// PasswordResetService is the service for users who forgot their password.
+type PasswordResetService interface {
+	RequestPasswordReset(tenantID TenantID, username string) error
+	ResetPassword(tenantID TenantID, token, password string) error
+}
+
+// NewPasswordResetService will create a new password reset service backed by supplied repositories and notifier.
+// New passwords found by the breached password checker are rejected, a nil checker disables the check.
+func NewPasswordResetService(
+	tenantRepository TenantRepository,
+	userRepository UserRepository,
+	breachedPasswords BreachedPasswordChecker,
+	notifier Notifier,
+	eventPublisher EventPublisher,
+) PasswordResetService {
+	return &passwordResetService{
+		tenantRepository:  tenantRepository,
+		userRepository:    userRepository,
+		breachedPasswords: breachedPasswords,
+		notifier:          notifier,
+		eventPublisher:    eventPublisher,
+	}
+}
+
+type passwordResetService struct {
+	tenantRepository  TenantRepository
+	userRepository    UserRepository
+	breachedPasswords BreachedPasswordChecker
+	notifier          Notifier
+	eventPublisher    EventPublisher
+}
+
+// RequestPasswordReset will send a password reset link to the email address of the user.
+// Unknown and disabled users get the same result, after the same time: notifiers are expected to queue
+// the delivery rather than to wait for it.
+func (s *passwordResetService) RequestPasswordReset(tenantID TenantID, username string) error {
+	const op = "RequestPasswordReset"
+	defer padDuration(time.Now(), passwordResetResponseTime)
+	tenant, err := s.tenant(op, tenantID)
+	if err != nil {
+		return err
+	}
+	user, err := s.userRepository.UserWithUsername(tenantID, username)
+	if err != nil {
+		return &Error{
+			Code:    EINTERNAL,
+			Message: "An unexpected error occurred while retrieving user.",
+			Op:      op,
+			Err:     err,
+		}
+	}
+	if user == nil || !user.IsEnabled() || user.emailAddress() == "" {
+		// Do the same work as for a known user.
+		_, _, err := (&User{}).RequestPasswordReset(0)
+		return err
+	}
+	ttl := tenant.PasswordPolicy.ResetTokenTTL
+	if ttl == 0 {
+		ttl = defaultResetTokenTTL
+	}
+	token, events, err := user.RequestPasswordReset(ttl)
+	if err != nil {
+		return err
+	}
+	if err := s.update(op, user); err != nil {
+		return err
+	}
+	err = s.notifier.Notify(Notification{
+		Kind:         PasswordResetNotification,
+		TenantID:     tenantID,
+		Username:     user.Username,
+		EmailAddress: user.emailAddress(),
+		Token:        token,
+		Link:         tokenLink(tenant.PasswordPolicy.ResetLinkURL, token),
+		ExpiresAt:    user.PasswordReset.ExpiresAt,
+	})
+	if err != nil {
+		return &Error{
+			Code:    EINTERNAL,
+			Message: "An unexpected error occurred while sending password reset.",
+			Op:      op,
+			Err:     err,
+		}
+	}
+	return s.eventPublisher.Publish(events)
+}
+
+// ResetPassword will set the password of the user the token was issued to, consuming the token once.
+func (s *passwordResetService) ResetPassword(tenantID TenantID, token, password string) error {
+	const op = "ResetPassword"
+	tenant, err := s.tenant(op, tenantID)
+	if err != nil {
+		return err
+	}
+	hash := hashToken(token)
+	user, err := s.userRepository.UserWithPasswordResetToken(tenantID, hash)
+	if err != nil {
+		return &Error{
+			Code:    EINTERNAL,
+			Message: "An unexpected error occurred while retrieving user.",
+			Op:      op,
+			Err:     err,
+		}
+	}
+	if user == nil || !user.IsEnabled() {
+		return &Error{Code: EUNAUTHORIZED, Message: "Password reset token is invalid or expired.", Op: op}
+	}
+	events, err := user.ResetPassword(token, password, tenant.PasswordPolicy, s.breachedPasswords)
+	if err != nil {
+		return err
+	}
+	reset, err := s.userRepository.ResetPassword(user, hash)
+	if err != nil {
+		return &Error{
+			Code:    EINTERNAL,
+			Message: "An unexpected error occurred while updating user.",
+			Op:      op,
+			Err:     err,
+		}
+	}
+	if !reset {
+		return &Error{Code: EUNAUTHORIZED, Message: "Password reset token is invalid or expired.", Op: op}
+	}
+	return s.eventPublisher.Publish(events)
+}
+
+func (s *passwordResetService) tenant(op string, tenantID TenantID) (*Tenant, error) {
+	tenant, err := s.tenantRepository.TenantOfID(tenantID)
+	if err != nil {
+		return nil, &Error{
+			Code:    EINTERNAL,
+			Message: "An unexpected error occurred while retrieving tenant.",
+			Op:      op,
+			Err:     err,
+		}
+	}
+	if tenant == nil {
+		return nil, &Error{Code: ENOTFOUND, Message: "Tenant not found.", Op: op}
+	}
+	if err := tenant.assertActive(op); err != nil {
+		return nil, err
+	}
+	return tenant, nil
+}
+
+func (s *passwordResetService) update(op string, user *User) error {
+	if err := s.userRepository.Update(user); err != nil {
+		return &Error{
+			Code:    EINTERNAL,
+			Message: "An unexpected error occurred while updating user.",
+			Op:      op,
+			Err:     err,
+		}
+	}
+	return nil
+}
+
+// padDuration will sleep until the duration elapsed since start.
+func padDuration(start time.Time, duration time.Duration) {
+	if elapsed := time.Since(start); elapsed < duration {
+		time.Sleep(duration - elapsed)
+	}
+}
@@ -0,0 +1,155 @@
+package iam_test
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/maurofran/iam"
+	"github.com/maurofran/iam/memory"
+	"github.com/maurofran/iam/mock"
+)
+
+var _ = Describe("Password reset service", func() {
+	const email = EmailAddress("jdoe@example.com")
+
+	var (
+		tenant   *Tenant
+		user     *User
+		stored   string
+		notifier *memory.Notifier
+		tr       *mock.TenantRepository
+		ur       *mock.UserRepository
+		ep       *mock.EventPublisher
+		events   Events
+		service  PasswordResetService
+	)
+
+	BeforeEach(func() {
+		var err error
+		tenant = &Tenant{ID: "tenant", Active: true, PasswordPolicy: DefaultPasswordPolicy()}
+		tenant.PasswordPolicy.ResetLinkURL = "https://login.example.com/reset"
+		person := &Person{ContactInformation: ContactInformation{EmailAddress: email}}
+		user, _, err = NewUser("tenant", "jdoe", "gV7#pLq2!wZx", person, tenant.PasswordPolicy, nil)
+		Expect(err).NotTo(HaveOccurred())
+		tr = &mock.TenantRepository{
+			TenantOfIDFn: func(TenantID) (*Tenant, error) {
+				return tenant, nil
+			},
+		}
+		ur = &mock.UserRepository{
+			UserWithUsernameFn: func(_ TenantID, username string) (*User, error) {
+				if username == user.Username {
+					return user, nil
+				}
+				return nil, nil
+			},
+			UserWithPasswordResetTokenFn: func(_ TenantID, token string) (*User, error) {
+				if user.PasswordReset != nil && user.PasswordReset.Token == token {
+					return user, nil
+				}
+				return nil, nil
+			},
+			UpdateFn: func(u *User) error {
+				if u.PasswordReset != nil {
+					stored = u.PasswordReset.Token
+				}
+				return nil
+			},
+			ResetPasswordFn: func(_ *User, token string) (bool, error) {
+				if token != stored {
+					return false, nil
+				}
+				stored = ""
+				return true, nil
+			},
+		}
+		stored = ""
+		events = nil
+		ep = &mock.EventPublisher{
+			PublishFn: func(ee Events) error {
+				events = append(events, ee...)
+				return nil
+			},
+		}
+		notifier = memory.NewNotifier()
+		service = NewPasswordResetService(tr, ur, nil, notifier, ep)
+	})
+
+	request := func() Notification {
+		Expect(service.RequestPasswordReset(tenant.ID, "jdoe")).To(Succeed())
+		n, ok := notifier.Last(email)
+		Expect(ok).To(BeTrue())
+		return n
+	}
+
+	It("should send a reset link and store the token hashed", func() {
+		n := request()
+		Expect(n.Kind).To(Equal(PasswordResetNotification))
+		Expect(n.Link).To(Equal("https://login.example.com/reset?token=" + n.Token))
+		Expect(user.PasswordReset.Token).NotTo(Equal(n.Token))
+		Expect(events[0].Type).To(Equal("UserPasswordResetRequested"))
+	})
+
+	It("should reset the password once", func() {
+		n := request()
+		Expect(service.ResetPassword(tenant.ID, n.Token, "Rk4$mN8@tYb1")).To(Succeed())
+		Expect(user.VerifyPassword("Rk4$mN8@tYb1")).To(BeTrue())
+		Expect(events[1].Type).To(Equal("UserPasswordReset"))
+		err := service.ResetPassword(tenant.ID, n.Token, "Kx7#qZ2v-third")
+		Expect(ErrorCode(err)).To(Equal(EUNAUTHORIZED))
+	})
+
+	It("should reset the password once under concurrent resets", func() {
+		n := request()
+		// A concurrent reset loads the user before the first one consumes the token.
+		concurrent := *user
+		Expect(service.ResetPassword(tenant.ID, n.Token, "Rk4$mN8@tYb1")).To(Succeed())
+		published := len(events)
+		ur.UserWithPasswordResetTokenFn = func(TenantID, string) (*User, error) {
+			return &concurrent, nil
+		}
+		err := service.ResetPassword(tenant.ID, n.Token, "Kx7#qZ2v-third")
+		Expect(err).To(Equal(&Error{Code: EUNAUTHORIZED, Message: "Password reset token is invalid or expired.", Op: "ResetPassword"}))
+		Expect(events).To(HaveLen(published))
+	})
+
+	It("should enforce the password policy", func() {
+		n := request()
+		err := service.ResetPassword(tenant.ID, n.Token, "password")
+		Expect(ErrorCode(err)).To(Equal(EINVALID))
+		Expect(user.PasswordReset).NotTo(BeNil())
+	})
+
+	It("should reject breached passwords", func() {
+		n := request()
+		service = NewPasswordResetService(tr, ur, &mock.BreachedPasswordChecker{
+			IsBreachedFn: func(password string) (bool, error) {
+				return password == "Rk4$mN8@tYb1", nil
+			},
+		}, notifier, ep)
+		err := service.ResetPassword(tenant.ID, n.Token, "Rk4$mN8@tYb1")
+		Expect(ErrorCode(err)).To(Equal(EINVALID))
+		Expect(user.VerifyPassword("gV7#pLq2!wZx")).To(BeTrue())
+	})
+
+	It("should reject expired tokens", func() {
+		n := request()
+		user.PasswordReset.ExpiresAt = time.Now().Add(-time.Second)
+		err := service.ResetPassword(tenant.ID, n.Token, "Rk4$mN8@tYb1")
+		Expect(ErrorCode(err)).To(Equal(EUNAUTHORIZED))
+	})
+
+	It("should not disclose unknown users", func() {
+		measure := func(username string) time.Duration {
+			start := time.Now()
+			Expect(service.RequestPasswordReset(tenant.ID, username)).To(Succeed())
+			return time.Since(start)
+		}
+		known := measure("jdoe")
+		unknown := measure("unknown")
+		Expect(unknown).To(BeNumerically("~", known, 50*time.Millisecond))
+		Expect(notifier.Notifications(email)).To(HaveLen(1))
+	})
+})
@@ -9,6 +9,145 @@ type Role struct {
 	Group           *Group   `bson:"group"`
 }
 
+// AssignUser will assign supplied user to the role.
+func (r *Role) AssignUser(user *User) (Events, error) {
+	if user.TenantID != r.TenantID {
+		return nil, &Error{Code: EINVALID, Message: "Wrong tenant for this role.", Op: "AssignUser"}
+	}
+	events, err := r.internalGroup().AddUser(user)
+	if err != nil || len(events) == 0 {
+		return nil, err
+	}
+	return Events{EventWithPayload(&UserAssignedToRole{
+		TenantID: r.TenantID,
+		RoleName: r.Name,
+		Username: user.Username,
+	})}, nil
+}
+
+// AssignServiceAccount will assign supplied service account to the role.
+func (r *Role) AssignServiceAccount(account *ServiceAccount) (Events, error) {
+	if account.TenantID != r.TenantID {
+		return nil, &Error{Code: EINVALID, Message: "Wrong tenant for this role.", Op: "AssignServiceAccount"}
+	}
+	events, err := r.internalGroup().AddServiceAccount(account)
+	if err != nil || len(events) == 0 {
+		return nil, err
+	}
+	return Events{EventWithPayload(&ServiceAccountAssignedToRole{
+		TenantID:           r.TenantID,
+		RoleName:           r.Name,
+		ServiceAccountName: account.Name,
+	})}, nil
+}
+
+// AssignGroup will assign supplied group to the role, if the role supports nesting.
+func (r *Role) AssignGroup(group *Group, groupMemberService GroupMemberService) (Events, error) {
+	const op = "AssignGroup"
+	if !r.SupportsNesting {
+		return nil, &Error{Code: ECONFLICT, Message: "This role does not support group nesting.", Op: op}
+	}
+	if group.TenantID != r.TenantID {
+		return nil, &Error{Code: EINVALID, Message: "Wrong tenant for this role.", Op: op}
+	}
+	events, err := r.internalGroup().AddGroup(group, groupMemberService)
+	if err != nil || len(events) == 0 {
+		return nil, err
+	}
+	return Events{EventWithPayload(&GroupAssignedToRole{
+		TenantID:  r.TenantID,
+		RoleName:  r.Name,
+		GroupName: group.Name,
+	})}, nil
+}
+
+// UnassignUser will unassign supplied user from the role.
+func (r *Role) UnassignUser(user *User) (Events, error) {
+	if user.TenantID != r.TenantID {
+		return nil, &Error{Code: EINVALID, Message: "Wrong tenant for this role.", Op: "UnassignUser"}
+	}
+	events, err := r.internalGroup().RemoveUser(user)
+	if err != nil || len(events) == 0 {
+		return nil, err
+	}
+	return Events{EventWithPayload(&UserUnassignedFromRole{
+		TenantID: r.TenantID,
+		RoleName: r.Name,
+		Username: user.Username,
+	})}, nil
+}
+
+// UnassignServiceAccount will unassign supplied service account from the role.
+func (r *Role) UnassignServiceAccount(account *ServiceAccount) (Events, error) {
+	if account.TenantID != r.TenantID {
+		return nil, &Error{Code: EINVALID, Message: "Wrong tenant for this role.", Op: "UnassignServiceAccount"}
+	}
+	events, err := r.internalGroup().RemoveServiceAccount(account)
+	if err != nil || len(events) == 0 {
+		return nil, err
+	}
+	return Events{EventWithPayload(&ServiceAccountUnassignedFromRole{
+		TenantID:           r.TenantID,
+		RoleName:           r.Name,
+		ServiceAccountName: account.Name,
+	})}, nil
+}
+
+// UnassignGroup will unassign supplied group from the role.
+func (r *Role) UnassignGroup(group *Group) (Events, error) {
+	const op = "UnassignGroup"
+	if !r.SupportsNesting {
+		return nil, &Error{Code: ECONFLICT, Message: "This role does not support group nesting.", Op: op}
+	}
+	if group.TenantID != r.TenantID {
+		return nil, &Error{Code: EINVALID, Message: "Wrong tenant for this role.", Op: op}
+	}
+	events, err := r.internalGroup().RemoveGroup(group)
+	if err != nil || len(events) == 0 {
+		return nil, err
+	}
+	return Events{EventWithPayload(&GroupUnassignedFromRole{
+		TenantID:  r.TenantID,
+		RoleName:  r.Name,
+		GroupName: group.Name,
+	})}, nil
+}
+
+// IsInRole will check if supplied user is in the role, through nested groups only when the role supports nesting.
+func (r *Role) IsInRole(user *User, groupMemberService GroupMemberService) (bool, error) {
+	if r.Group == nil || user.TenantID != r.TenantID || !user.IsEnabled() {
+		return false, nil
+	}
+	if !r.SupportsNesting {
+		return r.Group.Members.contains(UserGroupMember, user.Username), nil
+	}
+	return r.Group.IsMember(user, groupMemberService)
+}
+
+// IsServiceAccountInRole will check if supplied service account is in the role, through nested groups only
+// when the role supports nesting.
+func (r *Role) IsServiceAccountInRole(account *ServiceAccount, groupMemberService GroupMemberService) (bool, error) {
+	if r.Group == nil || account.TenantID != r.TenantID || !account.IsEnabled() {
+		return false, nil
+	}
+	if !r.SupportsNesting {
+		return r.Group.Members.contains(ServiceAccountGroupMember, account.Name), nil
+	}
+	return r.Group.IsServiceAccountMember(account, groupMemberService)
+}
+
+// internalGroup will return the group holding role members, creating it when missing.
+func (r *Role) internalGroup() *Group {
+	if r.Group == nil {
+		r.Group = &Group{
+			TenantID:    r.TenantID,
+			Name:        "ROLE-INTERNAL-GROUP: " + r.Name,
+			Description: "Role backing group for " + r.Name,
+		}
+	}
+	return r.Group
+}
+
 // Roles is the collection of roles
 type Roles []*Role
 
@@ -20,3 +159,45 @@ type RoleRepository interface {
 	RoleNamed(TenantID, string) (*Role, error)
 	AllRoles(TenantID) (Roles, error)
 }
+
+// UserAssignedToRole is the event raised when a user is assigned to a role.
+type UserAssignedToRole struct {
+	TenantID TenantID
+	RoleName string
+	Username string
+}
+
+// GroupAssignedToRole is the event raised when a group is assigned to a role.
+type GroupAssignedToRole struct {
+	TenantID  TenantID
+	RoleName  string
+	GroupName string
+}
+
+// UserUnassignedFromRole is the event raised when a user is unassigned from a role.
+type UserUnassignedFromRole struct {
+	TenantID TenantID
+	RoleName string
+	Username string
+}
+
+// GroupUnassignedFromRole is the event raised when a group is unassigned from a role.
+type GroupUnassignedFromRole struct {
+	TenantID  TenantID
+	RoleName  string
+	GroupName string
+}
+
+// ServiceAccountAssignedToRole is the event raised when a service account is assigned to a role.
+type ServiceAccountAssignedToRole struct {
+	TenantID           TenantID
+	RoleName           string
+	ServiceAccountName string
+}
+
+// ServiceAccountUnassignedFromRole is the event raised when a service account is unassigned from a role.
+type ServiceAccountUnassignedFromRole struct {
+	TenantID           TenantID
+	RoleName           string
+	ServiceAccountName string
+}
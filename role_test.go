@@ -0,0 +1,71 @@
+package iam_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/maurofran/iam"
+	"github.com/maurofran/iam/mock"
+)
+
+var _ = Describe("Role", func() {
+	var (
+		user    *User
+		devs    *Group
+		role    *Role
+		service GroupMemberService
+	)
+
+	BeforeEach(func() {
+		user = &User{TenantID: "tenant", Username: "jdoe", Enablement: IndefiniteEnablement()}
+		devs = &Group{TenantID: "tenant", Name: "devs"}
+		role = &Role{TenantID: "tenant", Name: "deployer", SupportsNesting: true}
+		service = NewGroupMemberService(&mock.GroupRepository{
+			GroupNamedFn: func(tenantID TenantID, name string) (*Group, error) {
+				if name == devs.Name {
+					return devs, nil
+				}
+				return nil, nil
+			},
+		})
+	})
+
+	Describe("#AssignUser", func() {
+		It("should put the user in role", func() {
+			events, err := role.AssignUser(user)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(events).To(HaveLen(1))
+			Expect(events[0].Type).To(Equal("UserAssignedToRole"))
+			Expect(role.IsInRole(user, service)).To(BeTrue())
+		})
+	})
+
+	Describe("#UnassignUser", func() {
+		It("should remove the user from role", func() {
+			_, err := role.AssignUser(user)
+			Expect(err).NotTo(HaveOccurred())
+			events, err := role.UnassignUser(user)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(events).To(HaveLen(1))
+			Expect(events[0].Type).To(Equal("UserUnassignedFromRole"))
+			Expect(role.IsInRole(user, service)).To(BeFalse())
+		})
+	})
+
+	Describe("#AssignGroup", func() {
+		It("should put group members in role", func() {
+			_, err := devs.AddUser(user)
+			Expect(err).NotTo(HaveOccurred())
+			events, err := role.AssignGroup(devs, service)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(events).To(HaveLen(1))
+			Expect(events[0].Type).To(Equal("GroupAssignedToRole"))
+			Expect(role.IsInRole(user, service)).To(BeTrue())
+		})
+		It("should be rejected when nesting is not supported", func() {
+			role.SupportsNesting = false
+			_, err := role.AssignGroup(devs, service)
+			Expect(ErrorCode(err)).To(Equal(ECONFLICT))
+		})
+	})
+})
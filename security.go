@@ -1,13 +1,14 @@
 package iam
 
 import (
-	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
 	"encoding/base64"
-	"math/rand"
+	"encoding/hex"
+	"math/big"
 	"strings"
-	"unicode"
-
-	"golang.org/x/crypto/bcrypt"
+	"sync"
 )
 
 var (
@@ -17,103 +18,154 @@ var (
 	symbols = []rune("\"`!?$?%^&*()_-+={[}]:;@'~#|\\<,>.?/")
 )
 
-const (
-	strongThreshold     = 20
-	veryStringThreshold = 40
-)
+// minGeneratedLength is the minimum length of generated passwords.
+const minGeneratedLength = 16
 
-func generateStrongPassword() string {
-	password := ""
-	var buffer bytes.Buffer
-	var i int
-	strong := false
-
-	for !strong {
-		switch rand.Intn(4) {
-		case 0:
-			i = rand.Intn(len(uppers))
-			buffer.WriteRune(uppers[i])
-			break
-		case 1:
-			i = rand.Intn(len(lowers))
-			buffer.WriteRune(lowers[i])
-			break
-		case 2:
-			i = rand.Intn(len(digits))
-			buffer.WriteRune(digits[i])
-			break
-		case 3:
-			i = rand.Intn(len(symbols))
-			buffer.WriteRune(symbols[i])
-			break
+// GeneratePassword will generate a random password satisfying length and character classes of the policy.
+// Every character class is always included and no character is repeated consecutively.
+func GeneratePassword(policy PasswordPolicy) (string, error) {
+	length := minGeneratedLength
+	if policy.MinLength > length {
+		length = policy.MinLength
+	}
+	classes := [][]rune{uppers, lowers, digits, symbols}
+	picks := make([][]rune, length)
+	copy(picks, classes)
+	for i := len(classes); i < length; i++ {
+		n, err := randomInt(len(classes))
+		if err != nil {
+			return "", generationError(err)
 		}
-		password = buffer.String()
-		if len(password) >= 7 {
-			strong = isStrong(password)
+		picks[i] = classes[n]
+	}
+	for i := length - 1; i > 0; i-- {
+		j, err := randomInt(i + 1)
+		if err != nil {
+			return "", generationError(err)
 		}
+		picks[i], picks[j] = picks[j], picks[i]
+	}
+	password := make([]rune, length)
+	for i, class := range picks {
+		for {
+			n, err := randomInt(len(class))
+			if err != nil {
+				return "", generationError(err)
+			}
+			if i == 0 || class[n] != password[i-1] {
+				password[i] = class[n]
+				break
+			}
+		}
+	}
+	return string(password), nil
+}
+
+func randomInt(max int) (int, error) {
+	n, err := rand.Int(rand.Reader, big.NewInt(int64(max)))
+	if err != nil {
+		return 0, err
+	}
+	return int(n.Int64()), nil
+}
+
+func generationError(err error) error {
+	return &Error{
+		Code:    EINTERNAL,
+		Message: "An unexpected error occurred while generating password.",
+		Op:      "GeneratePassword",
+		Err:     err,
 	}
-	return password
 }
 
 func isStrong(password string) bool {
-	return calculateStrength(password) >= strongThreshold
+	return EstimatePasswordStrength(password).Score >= strongScore
 }
 
 func isVeryStong(password string) bool {
-	return calculateStrength(password) >= veryStringThreshold
+	return EstimatePasswordStrength(password).Score >= veryStrongScore
 }
 
 func isWeak(password string) bool {
-	return calculateStrength(password) < strongThreshold
+	return !isStrong(password)
 }
 
-func calculateStrength(password string) int {
-	strength := 0
-	length := len(password)
-
-	if length > 7 {
-		strength += 10 + (length - 7)
+func encrypt(value string) (string, error) {
+	enc, err := DefaultPasswordHasher.Hash(value)
+	if err != nil {
+		return "", &Error{
+			Code:    EINTERNAL,
+			Message: "An unexpected error occurred while encrypting password.",
+			Op:      "encrypt",
+			Err:     err,
+		}
 	}
+	return enc, nil
+}
 
-	digits := 0
-	letters := 0
-	lowers := 0
-	uppers := 0
-	symbols := 0
-
-	for _, ch := range []rune(password) {
-		switch {
-		case unicode.IsLetter(ch):
-			letters++
-			if unicode.IsUpper(ch) {
-				uppers++
-			} else {
-				lowers++
-			}
-			break
-		case unicode.IsDigit(ch):
-			digits++
-		default:
-			symbols++
-		}
+func matches(value, encrypted string) bool {
+	h := hasherFor(encrypted)
+	if h == nil {
+		return false
 	}
-	strength += uppers + lowers + symbols
-	if letters >= 2 && digits >= 2 {
-		strength += letters + digits
+	return h.Verify(value, encrypted)
+}
+
+// dummyPassword holds a hash produced by the default hasher, verified when there is no password to verify
+// so that authenticating unknown users takes as long as authenticating existing ones.
+var dummyPassword struct {
+	sync.Mutex
+	hasher PasswordHasher
+	hash   string
+}
+
+// verifyDummyPassword will verify supplied password against the dummy hash with the hasher that produced it, always
+// failing.
+func verifyDummyPassword(plain string) bool {
+	if hasher, hash := dummyHash(); hasher != nil {
+		hasher.Verify(plain, hash)
 	}
+	return false
+}
 
-	return strength
+// dummyHash will return the dummy hash and its hasher, computing it again when the default hasher changed.
+func dummyHash() (PasswordHasher, string) {
+	dummyPassword.Lock()
+	defer dummyPassword.Unlock()
+	if dummyPassword.hasher != DefaultPasswordHasher {
+		hash, err := DefaultPasswordHasher.Hash("dummy password")
+		if err != nil {
+			return nil, ""
+		}
+		dummyPassword.hasher, dummyPassword.hash = DefaultPasswordHasher, hash
+	}
+	return dummyPassword.hasher, dummyPassword.hash
 }
 
-func encrypt(value string) (string, error) {
-	enc, err := bcrypt.GenerateFromPassword([]byte(value), bcrypt.DefaultCost)
-	if err != nil {
+// tokenLength is the number of random bytes of opaque tokens.
+const tokenLength = 32
+
+// newToken will generate a random opaque token, safe to use in URLs.
+func newToken(op string) (string, error) {
+	b := make([]byte, tokenLength)
+	if _, err := rand.Read(b); err != nil {
 		return "", &Error{
 			Code:    EINTERNAL,
-			Message: "An unexpected error occurred while encrypting password.",
-			Op:      "encrypt",
+			Message: "An unexpected error occurred while generating token.",
+			Op:      op,
 			Err:     err,
 		}
 	}
-	return base64.StdEncoding.EncodeToString(enc), nil
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// hashToken will hash an opaque token for storage. A fast hash is enough, since tokens are random and long.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// tokenMatches will check in constant time if supplied token matches the stored hash.
+func tokenMatches(token, hashed string) bool {
+	return hashed != "" && subtle.ConstantTimeCompare([]byte(hashToken(token)), []byte(hashed)) == 1
 }
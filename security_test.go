@@ -0,0 +1,42 @@
+package iam_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/maurofran/iam"
+)
+
+var _ = Describe("GeneratePassword", func() {
+	var policy PasswordPolicy
+
+	BeforeEach(func() {
+		policy = PasswordPolicy{
+			MinLength:      24,
+			RequireUppers:  true,
+			RequireLowers:  true,
+			RequireDigits:  true,
+			RequireSymbols: true,
+			MinStrength:    4,
+			MaxRepeated:    1,
+		}
+	})
+
+	It("should satisfy the policy", func() {
+		user := &User{Username: "jdoe"}
+		for i := 0; i < 100; i++ {
+			password, err := GeneratePassword(policy)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(len(password)).To(BeNumerically(">=", 24))
+			Expect(policy.Validate(password, user)).To(Succeed())
+		}
+	})
+
+	It("should not repeat passwords", func() {
+		first, err := GeneratePassword(policy)
+		Expect(err).NotTo(HaveOccurred())
+		second, err := GeneratePassword(policy)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(first).NotTo(Equal(second))
+	})
+})
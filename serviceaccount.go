@@ -0,0 +1,388 @@
+package iam
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"strings"
+	"time"
+)
+
+// apiKeyPrefixLength is the number of random bytes of the public prefix used to look up an API key.
+const apiKeyPrefixLength = 8
+
+// ServiceAccounts is the type for a collection of service accounts.
+type ServiceAccounts []*ServiceAccount
+
+// ServiceAccount is the aggregate root representing a non human identity of a tenant, such as a backend
+// service, authenticating with API keys.
+type ServiceAccount struct {
+	TenantID    TenantID   `bson:"tenantId"`
+	Name        string     `bson:"name"`
+	Description string     `bson:"description,omitempty"`
+	Enablement  Enablement `bson:"enablement"`
+	APIKeys     APIKeys    `bson:"apiKeys"`
+}
+
+// APIKey is the value object representing a named API key of a service account.
+// Keys are made of a public prefix and a random secret separated by a dot: the prefix is used to look the key
+// up and only the hash of the whole key is stored. A zero ExpiresAt means the key never expires.
+type APIKey struct {
+	Name       string    `bson:"name"`
+	Prefix     string    `bson:"prefix"`
+	Hash       string    `bson:"hash" json:"-"`
+	CreatedAt  time.Time `bson:"createdAt"`
+	ExpiresAt  time.Time `bson:"expiresAt,omitempty"`
+	LastUsedAt time.Time `bson:"lastUsedAt,omitempty"`
+	RevokedAt  time.Time `bson:"revokedAt,omitempty"`
+}
+
+// IsActive will check if the API key is neither revoked nor expired at supplied time.
+func (k *APIKey) IsActive(now time.Time) bool {
+	return k.RevokedAt.IsZero() && (k.ExpiresAt.IsZero() || k.ExpiresAt.After(now))
+}
+
+// APIKeys is the collection of API keys.
+type APIKeys []*APIKey
+
+// IsEnabled will check if the service account is actually enabled.
+func (a *ServiceAccount) IsEnabled() bool {
+	return a.Enablement.IsEnabled()
+}
+
+// DefineEnablement will define the service account enablement.
+func (a *ServiceAccount) DefineEnablement(enablement Enablement) Events {
+	a.Enablement = enablement
+	return Events{EventWithPayload(&ServiceAccountEnablementChanged{
+		TenantID:   a.TenantID,
+		Name:       a.Name,
+		Enablement: enablement,
+	})}
+}
+
+// IssueAPIKey will generate a new API key with supplied name, expiring after ttl unless it is zero.
+// The key is returned once and never stored as such.
+func (a *ServiceAccount) IssueAPIKey(name string, ttl time.Duration) (string, Events, error) {
+	const op = "IssueAPIKey"
+	if name == "" {
+		return "", nil, &Error{Code: EINVALID, Message: "API key name is required.", Op: op}
+	}
+	if ttl < 0 {
+		return "", nil, &Error{Code: EINVALID, Message: "API key time to live cannot be negative.", Op: op}
+	}
+	now := time.Now()
+	if k := a.apiKeyNamed(name); k != nil && k.IsActive(now) {
+		return "", nil, &Error{Code: ECONFLICT, Message: "API key name is already in use.", Op: op}
+	}
+	b := make([]byte, apiKeyPrefixLength)
+	if _, err := rand.Read(b); err != nil {
+		return "", nil, &Error{
+			Code:    EINTERNAL,
+			Message: "An unexpected error occurred while generating API key.",
+			Op:      op,
+			Err:     err,
+		}
+	}
+	secret, err := newToken(op)
+	if err != nil {
+		return "", nil, err
+	}
+	prefix := hex.EncodeToString(b)
+	key := prefix + "." + secret
+	k := &APIKey{Name: name, Prefix: prefix, Hash: hashToken(key), CreatedAt: now}
+	if ttl > 0 {
+		k.ExpiresAt = now.Add(ttl)
+	}
+	a.APIKeys = append(a.APIKeys, k)
+	return key, Events{EventWithPayload(&ServiceAccountAPIKeyIssued{
+		TenantID:  a.TenantID,
+		Name:      a.Name,
+		KeyName:   name,
+		Prefix:    prefix,
+		ExpiresAt: k.ExpiresAt,
+	})}, nil
+}
+
+// RevokeAPIKey will revoke the active API key with supplied name.
+func (a *ServiceAccount) RevokeAPIKey(name string) (Events, error) {
+	now := time.Now()
+	k := a.apiKeyNamed(name)
+	if k == nil || !k.IsActive(now) {
+		return nil, &Error{Code: ENOTFOUND, Message: "API key not found.", Op: "RevokeAPIKey"}
+	}
+	k.RevokedAt = now
+	return Events{EventWithPayload(&ServiceAccountAPIKeyRevoked{
+		TenantID: a.TenantID,
+		Name:     a.Name,
+		KeyName:  name,
+	})}, nil
+}
+
+// VerifyAPIKey will return the active API key matching supplied one, or nil.
+func (a *ServiceAccount) VerifyAPIKey(key string) *APIKey {
+	prefix, ok := apiKeyPrefix(key)
+	if !ok {
+		return nil
+	}
+	now := time.Now()
+	for _, k := range a.APIKeys {
+		if k.Prefix == prefix && k.IsActive(now) && tokenMatches(key, k.Hash) {
+			return k
+		}
+	}
+	return nil
+}
+
+// apiKeyNamed will return the most recent API key with supplied name, or nil.
+func (a *ServiceAccount) apiKeyNamed(name string) *APIKey {
+	for i := len(a.APIKeys) - 1; i >= 0; i-- {
+		if a.APIKeys[i].Name == name {
+			return a.APIKeys[i]
+		}
+	}
+	return nil
+}
+
+func (a *ServiceAccount) toGroupMember() *GroupMember {
+	return &GroupMember{Type: ServiceAccountGroupMember, Name: a.Name}
+}
+
+// apiKeyPrefix will return the lookup prefix of supplied API key.
+func apiKeyPrefix(key string) (string, bool) {
+	i := strings.IndexByte(key, '.')
+	if i != apiKeyPrefixLength*2 {
+		return "", false
+	}
+	return key[:i], true
+}
+
+// ServiceAccountRepository is the repository of service accounts.
+// RecordAPIKeyUsage atomically updates the last used timestamp of the key with supplied prefix.
+type ServiceAccountRepository interface {
+	Add(*ServiceAccount) error
+	Update(*ServiceAccount) error
+	Remove(*ServiceAccount) error
+	ServiceAccountNamed(TenantID, string) (*ServiceAccount, error)
+	ServiceAccountWithAPIKeyPrefix(TenantID, string) (*ServiceAccount, error)
+	AllServiceAccounts(TenantID) (ServiceAccounts, error)
+	RecordAPIKeyUsage(tenantID TenantID, prefix string, usedAt time.Time) error
+}
+
+// ServiceAccountEnablementChanged is the event raised when the enablement of a service account changes.
+type ServiceAccountEnablementChanged struct {
+	TenantID   TenantID
+	Name       string
+	Enablement Enablement
+}
+
+// ServiceAccountAPIKeyIssued is the event raised when an API key is issued for a service account.
+type ServiceAccountAPIKeyIssued struct {
+	TenantID  TenantID
+	Name      string
+	KeyName   string
+	Prefix    string
+	ExpiresAt time.Time
+}
+
+// ServiceAccountAPIKeyRevoked is the event raised when an API key of a service account is revoked.
+type ServiceAccountAPIKeyRevoked struct {
+	TenantID TenantID
+	Name     string
+	KeyName  string
+}
+
+// ServiceAccountService is the service managing service accounts and authenticating their API keys.
+type ServiceAccountService interface {
+	ProvisionServiceAccount(tenantID TenantID, name, description string) (*ServiceAccount, error)
+	DefineServiceAccountEnablement(tenantID TenantID, name string, enablement Enablement) error
+	IssueAPIKey(tenantID TenantID, name, keyName string, ttl time.Duration) (string, error)
+	RevokeAPIKey(tenantID TenantID, name, keyName string) error
+	AuthenticateAPIKey(tenantID TenantID, key string) (*ServiceAccount, error)
+}
+
+// NewServiceAccountService will create a new service account service backed by supplied repositories.
+func NewServiceAccountService(
+	tenantRepository TenantRepository,
+	serviceAccountRepository ServiceAccountRepository,
+	eventPublisher EventPublisher,
+) ServiceAccountService {
+	return &serviceAccountService{
+		tenantRepository:         tenantRepository,
+		serviceAccountRepository: serviceAccountRepository,
+		eventPublisher:           eventPublisher,
+	}
+}
+
+type serviceAccountService struct {
+	tenantRepository         TenantRepository
+	serviceAccountRepository ServiceAccountRepository
+	eventPublisher           EventPublisher
+}
+
+// ProvisionServiceAccount will provision a new service account for the tenant.
+func (s *serviceAccountService) ProvisionServiceAccount(tenantID TenantID, name, description string) (*ServiceAccount, error) {
+	const op = "ProvisionServiceAccount"
+	tenant, err := s.tenant(op, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	account, events, err := tenant.ProvisionServiceAccount(name, description)
+	if err != nil {
+		return nil, err
+	}
+	existing, err := s.serviceAccountRepository.ServiceAccountNamed(tenantID, name)
+	if err != nil {
+		return nil, &Error{
+			Code:    EINTERNAL,
+			Message: "An unexpected error occurred while retrieving service account.",
+			Op:      op,
+			Err:     err,
+		}
+	}
+	if existing != nil {
+		return nil, &Error{Code: ECONFLICT, Message: "Service account name is already in use.", Op: op}
+	}
+	if err := s.serviceAccountRepository.Add(account); err != nil {
+		return nil, &Error{
+			Code:    EINTERNAL,
+			Message: "An unexpected error occurred while adding service account.",
+			Op:      op,
+			Err:     err,
+		}
+	}
+	if err := s.eventPublisher.Publish(events); err != nil {
+		return nil, err
+	}
+	return account, nil
+}
+
+// DefineServiceAccountEnablement will define the enablement of the service account.
+func (s *serviceAccountService) DefineServiceAccountEnablement(tenantID TenantID, name string, enablement Enablement) error {
+	const op = "DefineServiceAccountEnablement"
+	account, err := s.serviceAccount(op, tenantID, name)
+	if err != nil {
+		return err
+	}
+	return s.save(op, account, account.DefineEnablement(enablement))
+}
+
+// IssueAPIKey will issue a new API key for the service account.
+func (s *serviceAccountService) IssueAPIKey(tenantID TenantID, name, keyName string, ttl time.Duration) (string, error) {
+	const op = "IssueAPIKey"
+	account, err := s.serviceAccount(op, tenantID, name)
+	if err != nil {
+		return "", err
+	}
+	key, events, err := account.IssueAPIKey(keyName, ttl)
+	if err != nil {
+		return "", err
+	}
+	if err := s.save(op, account, events); err != nil {
+		return "", err
+	}
+	return key, nil
+}
+
+// RevokeAPIKey will revoke the API key of the service account.
+func (s *serviceAccountService) RevokeAPIKey(tenantID TenantID, name, keyName string) error {
+	const op = "RevokeAPIKey"
+	account, err := s.serviceAccount(op, tenantID, name)
+	if err != nil {
+		return err
+	}
+	events, err := account.RevokeAPIKey(keyName)
+	if err != nil {
+		return err
+	}
+	return s.save(op, account, events)
+}
+
+// AuthenticateAPIKey will return the enabled service account owning supplied active API key, recording its usage.
+func (s *serviceAccountService) AuthenticateAPIKey(tenantID TenantID, key string) (*ServiceAccount, error) {
+	const op = "AuthenticateAPIKey"
+	if _, err := s.tenant(op, tenantID); err != nil {
+		return nil, err
+	}
+	prefix, ok := apiKeyPrefix(key)
+	if !ok {
+		return nil, &Error{Code: EUNAUTHORIZED, Message: "Invalid API key.", Op: op}
+	}
+	account, err := s.serviceAccountRepository.ServiceAccountWithAPIKeyPrefix(tenantID, prefix)
+	if err != nil {
+		return nil, &Error{
+			Code:    EINTERNAL,
+			Message: "An unexpected error occurred while retrieving service account.",
+			Op:      op,
+			Err:     err,
+		}
+	}
+	if account == nil || !account.IsEnabled() {
+		return nil, &Error{Code: EUNAUTHORIZED, Message: "Invalid API key.", Op: op}
+	}
+	k := account.VerifyAPIKey(key)
+	if k == nil {
+		return nil, &Error{Code: EUNAUTHORIZED, Message: "Invalid API key.", Op: op}
+	}
+	k.LastUsedAt = time.Now()
+	if err := s.serviceAccountRepository.RecordAPIKeyUsage(tenantID, k.Prefix, k.LastUsedAt); err != nil {
+		return nil, &Error{
+			Code:    EINTERNAL,
+			Message: "An unexpected error occurred while updating service account.",
+			Op:      op,
+			Err:     err,
+		}
+	}
+	return account, nil
+}
+
+func (s *serviceAccountService) tenant(op string, tenantID TenantID) (*Tenant, error) {
+	tenant, err := s.tenantRepository.TenantOfID(tenantID)
+	if err != nil {
+		return nil, &Error{
+			Code:    EINTERNAL,
+			Message: "An unexpected error occurred while retrieving tenant.",
+			Op:      op,
+			Err:     err,
+		}
+	}
+	if tenant == nil {
+		return nil, &Error{Code: ENOTFOUND, Message: "Tenant not found.", Op: op}
+	}
+	if err := tenant.assertActive(op); err != nil {
+		return nil, err
+	}
+	return tenant, nil
+}
+
+func (s *serviceAccountService) serviceAccount(op string, tenantID TenantID, name string) (*ServiceAccount, error) {
+	if _, err := s.tenant(op, tenantID); err != nil {
+		return nil, err
+	}
+	account, err := s.serviceAccountRepository.ServiceAccountNamed(tenantID, name)
+	if err != nil {
+		return nil, &Error{
+			Code:    EINTERNAL,
+			Message: "An unexpected error occurred while retrieving service account.",
+			Op:      op,
+			Err:     err,
+		}
+	}
+	if account == nil {
+		return nil, &Error{Code: ENOTFOUND, Message: "Service account not found.", Op: op}
+	}
+	return account, nil
+}
+
+func (s *serviceAccountService) save(op string, account *ServiceAccount, events Events) error {
+	if err := s.serviceAccountRepository.Update(account); err != nil {
+		return &Error{
+			Code:    EINTERNAL,
+			Message: "An unexpected error occurred while updating service account.",
+			Op:      op,
+			Err:     err,
+		}
+	}
+	if len(events) == 0 {
+		return nil
+	}
+	return s.eventPublisher.Publish(events)
+}
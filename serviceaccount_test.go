@@ -0,0 +1,155 @@
+package iam_test
+
+import (
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/maurofran/iam"
+	"github.com/maurofran/iam/mock"
+)
+
+var _ = Describe("Service account", func() {
+	var account *ServiceAccount
+
+	BeforeEach(func() {
+		tenant := &Tenant{ID: "tenant", Active: true}
+		var err error
+		account, _, err = tenant.ProvisionServiceAccount("billing", "Billing backend")
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	Describe("#IssueAPIKey", func() {
+		It("should store the key hashed with its lookup prefix", func() {
+			key, events, err := account.IssueAPIKey("ci", time.Hour)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(account.APIKeys).To(HaveLen(1))
+			k := account.APIKeys[0]
+			Expect(key).To(HavePrefix(k.Prefix + "."))
+			Expect(k.Hash).NotTo(ContainSubstring(strings.TrimPrefix(key, k.Prefix+".")))
+			Expect(k.ExpiresAt).To(BeTemporally("~", time.Now().Add(time.Hour), time.Second))
+			Expect(events[0].Type).To(Equal("ServiceAccountAPIKeyIssued"))
+		})
+		It("should issue keys that never expire", func() {
+			_, _, err := account.IssueAPIKey("ci", 0)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(account.APIKeys[0].ExpiresAt.IsZero()).To(BeTrue())
+		})
+		It("should reject a name in use", func() {
+			account.IssueAPIKey("ci", 0)
+			_, _, err := account.IssueAPIKey("ci", 0)
+			Expect(ErrorCode(err)).To(Equal(ECONFLICT))
+		})
+		It("should reuse the name of a revoked key", func() {
+			account.IssueAPIKey("ci", 0)
+			account.RevokeAPIKey("ci")
+			_, _, err := account.IssueAPIKey("ci", 0)
+			Expect(err).NotTo(HaveOccurred())
+		})
+	})
+
+	Describe("#VerifyAPIKey", func() {
+		var key string
+
+		BeforeEach(func() {
+			key, _, _ = account.IssueAPIKey("ci", time.Hour)
+		})
+
+		It("should match the key", func() {
+			Expect(account.VerifyAPIKey(key)).To(Equal(account.APIKeys[0]))
+		})
+		It("should reject a wrong secret", func() {
+			Expect(account.VerifyAPIKey(account.APIKeys[0].Prefix + ".wrong")).To(BeNil())
+		})
+		It("should reject a revoked key", func() {
+			events, err := account.RevokeAPIKey("ci")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(events[0].Type).To(Equal("ServiceAccountAPIKeyRevoked"))
+			Expect(account.VerifyAPIKey(key)).To(BeNil())
+		})
+		It("should reject an expired key", func() {
+			account.APIKeys[0].ExpiresAt = time.Now().Add(-time.Second)
+			Expect(account.VerifyAPIKey(key)).To(BeNil())
+		})
+	})
+
+	Describe("service", func() {
+		var (
+			sar     *mock.ServiceAccountRepository
+			service ServiceAccountService
+		)
+
+		BeforeEach(func() {
+			tr := &mock.TenantRepository{
+				TenantOfIDFn: func(TenantID) (*Tenant, error) {
+					return &Tenant{ID: "tenant", Active: true}, nil
+				},
+			}
+			sar = &mock.ServiceAccountRepository{
+				ServiceAccountNamedFn: func(_ TenantID, name string) (*ServiceAccount, error) {
+					if name == account.Name {
+						return account, nil
+					}
+					return nil, nil
+				},
+				ServiceAccountWithAPIKeyPrefixFn: func(_ TenantID, prefix string) (*ServiceAccount, error) {
+					for _, k := range account.APIKeys {
+						if k.Prefix == prefix {
+							return account, nil
+						}
+					}
+					return nil, nil
+				},
+				AddFn: func(*ServiceAccount) error {
+					return nil
+				},
+				UpdateFn: func(*ServiceAccount) error {
+					return nil
+				},
+				RecordAPIKeyUsageFn: func(TenantID, string, time.Time) error {
+					return nil
+				},
+			}
+			ep := &mock.EventPublisher{
+				PublishFn: func(Events) error {
+					return nil
+				},
+			}
+			service = NewServiceAccountService(tr, sar, ep)
+		})
+
+		It("should reject a duplicated name", func() {
+			_, err := service.ProvisionServiceAccount("tenant", "billing", "")
+			Expect(ErrorCode(err)).To(Equal(ECONFLICT))
+			Expect(sar.AddInvoked).To(BeFalse())
+		})
+		It("should authenticate an API key, recording its usage", func() {
+			key, err := service.IssueAPIKey("tenant", "billing", "ci", 0)
+			Expect(err).NotTo(HaveOccurred())
+			a, err := service.AuthenticateAPIKey("tenant", key)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(a).To(Equal(account))
+			Expect(sar.RecordAPIKeyUsageInvoked).To(BeTrue())
+			Expect(account.APIKeys[0].LastUsedAt).To(BeTemporally("~", time.Now(), time.Second))
+		})
+		It("should reject a revoked API key", func() {
+			key, _ := service.IssueAPIKey("tenant", "billing", "ci", 0)
+			Expect(service.RevokeAPIKey("tenant", "billing", "ci")).To(Succeed())
+			_, err := service.AuthenticateAPIKey("tenant", key)
+			Expect(ErrorCode(err)).To(Equal(EUNAUTHORIZED))
+		})
+		It("should reject the API keys of a disabled service account", func() {
+			key, _ := service.IssueAPIKey("tenant", "billing", "ci", 0)
+			Expect(service.DefineServiceAccountEnablement("tenant", "billing", Enablement{Enabled: false})).To(Succeed())
+			_, err := service.AuthenticateAPIKey("tenant", key)
+			Expect(ErrorCode(err)).To(Equal(EUNAUTHORIZED))
+		})
+		It("should reject a malformed API key", func() {
+			_, err := service.AuthenticateAPIKey("tenant", "garbage")
+			Expect(ErrorCode(err)).To(Equal(EUNAUTHORIZED))
+			Expect(sar.ServiceAccountWithAPIKeyPrefixInvoked).To(BeFalse())
+		})
+	})
+})
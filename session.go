@@ -0,0 +1,409 @@
+package iam
+
+import "time"
+
+// maxRotatedRefreshTokens is the number of rotated refresh tokens remembered to detect their reuse.
+const maxRotatedRefreshTokens = 100
+
+// Sessions is the collection of sessions.
+type Sessions []*Session
+
+// ClientMetadata is the value object describing the client a session was started from, and the scope granted to it.
+type ClientMetadata struct {
+	ClientID  string `bson:"clientId,omitempty"`
+	Scope     string `bson:"scope,omitempty"`
+	UserAgent string `bson:"userAgent,omitempty"`
+	IPAddress string `bson:"ipAddress,omitempty"`
+}
+
+// SessionRevocationReason is the enum type for the reason a session was revoked.
+type SessionRevocationReason string
+
+// SessionRevokedOnRequest is the reason of sessions revoked by their user or an administrator.
+// SessionRevokedOnReuse is the reason of sessions whose rotated refresh token was presented again.
+// SessionRevokedOnUserDisabled is the reason of sessions revoked because their user was disabled.
+// SessionRevokedOnTenantDeactivated is the reason of sessions revoked because their tenant was deactivated.
+const (
+	SessionRevokedOnRequest           SessionRevocationReason = "requested"
+	SessionRevokedOnReuse             SessionRevocationReason = "refreshTokenReused"
+	SessionRevokedOnUserDisabled      SessionRevocationReason = "userDisabled"
+	SessionRevokedOnTenantDeactivated SessionRevocationReason = "tenantDeactivated"
+)
+
+// Session is the aggregate root representing the login of a user, kept alive with an opaque refresh token.
+// Refresh tokens are rotated on every use and only their hashes are stored. The session is the family of
+// its refresh tokens: presenting a rotated one again means it leaked, so the whole session is revoked.
+type Session struct {
+	ID                   string                  `bson:"sessionId"`
+	TenantID             TenantID                `bson:"tenantId"`
+	Username             string                  `bson:"username"`
+	Client               ClientMetadata          `bson:"client"`
+	CreatedAt            time.Time               `bson:"createdAt"`
+	LastActivityAt       time.Time               `bson:"lastActivityAt"`
+	ExpiresAt            time.Time               `bson:"expiresAt"`
+	RefreshToken         string                  `bson:"refreshToken" json:"-"`
+	RotatedRefreshTokens []string                `bson:"rotatedRefreshTokens,omitempty" json:"-"`
+	RevokedAt            time.Time               `bson:"revokedAt,omitempty"`
+	RevocationReason     SessionRevocationReason `bson:"revocationReason,omitempty"`
+}
+
+// NewSession will start a new session for the user, returning it with its first refresh token.
+func NewSession(user *User, client ClientMetadata, ttl time.Duration) (*Session, string, Events, error) {
+	const op = "NewSession"
+	id, err := newIdentity(op)
+	if err != nil {
+		return nil, "", nil, err
+	}
+	token, err := newToken(op)
+	if err != nil {
+		return nil, "", nil, err
+	}
+	now := time.Now()
+	s := &Session{
+		ID:             id,
+		TenantID:       user.TenantID,
+		Username:       user.Username,
+		Client:         client,
+		CreatedAt:      now,
+		LastActivityAt: now,
+		ExpiresAt:      now.Add(ttl),
+		RefreshToken:   hashToken(token),
+	}
+	return s, token, Events{EventWithPayload(&SessionStarted{
+		TenantID:  s.TenantID,
+		Username:  s.Username,
+		SessionID: s.ID,
+		Client:    client,
+	})}, nil
+}
+
+// IsActive will check if the session is neither revoked nor expired at supplied time.
+func (s *Session) IsActive(now time.Time) bool {
+	return s.RevokedAt.IsZero() && s.ExpiresAt.After(now)
+}
+
+// Refresh will exchange the current refresh token for a new one, recording the activity.
+// A rotated token revokes the session, returning the revocation events together with the error.
+func (s *Session) Refresh(token string) (string, Events, error) {
+	const op = "Refresh"
+	now := time.Now()
+	if !s.IsActive(now) {
+		return "", nil, invalidRefreshToken(op)
+	}
+	if !tokenMatches(token, s.RefreshToken) {
+		if s.wasRotated(token) {
+			return "", s.Revoke(SessionRevokedOnReuse), invalidRefreshToken(op)
+		}
+		return "", nil, invalidRefreshToken(op)
+	}
+	next, err := newToken(op)
+	if err != nil {
+		return "", nil, err
+	}
+	s.RotatedRefreshTokens = append(s.RotatedRefreshTokens, s.RefreshToken)
+	if n := len(s.RotatedRefreshTokens); n > maxRotatedRefreshTokens {
+		s.RotatedRefreshTokens = s.RotatedRefreshTokens[n-maxRotatedRefreshTokens:]
+	}
+	s.RefreshToken = hashToken(next)
+	s.LastActivityAt = now
+	return next, nil, nil
+}
+
+func (s *Session) wasRotated(token string) bool {
+	for _, hashed := range s.RotatedRefreshTokens {
+		if tokenMatches(token, hashed) {
+			return true
+		}
+	}
+	return false
+}
+
+// Revoke will end the session for supplied reason.
+func (s *Session) Revoke(reason SessionRevocationReason) Events {
+	if !s.RevokedAt.IsZero() {
+		return nil
+	}
+	s.RevokedAt = time.Now()
+	s.RevocationReason = reason
+	return Events{EventWithPayload(&SessionRevoked{
+		TenantID:  s.TenantID,
+		Username:  s.Username,
+		SessionID: s.ID,
+		Reason:    reason,
+	})}
+}
+
+func invalidRefreshToken(op string) error {
+	return &Error{Code: EUNAUTHORIZED, Message: "Refresh token is invalid or expired.", Op: op}
+}
+
+// SessionRepository is the repository of sessions.
+// SessionWithRefreshToken finds sessions by the hash of their current or rotated refresh tokens.
+// Rotate updates the session only if its refresh token is still previous, returning false otherwise,
+// so that concurrent refreshes with the same token cannot both succeed.
+type SessionRepository interface {
+	Add(*Session) error
+	Update(*Session) error
+	Rotate(session *Session, previous string) (bool, error)
+	SessionOfID(TenantID, string) (*Session, error)
+	SessionWithRefreshToken(TenantID, string) (*Session, error)
+	ActiveSessionsOfUser(TenantID, string) (Sessions, error)
+	ActiveSessionsOfTenant(TenantID) (Sessions, error)
+}
+
+// SessionStarted is the event raised when a user starts a session.
+type SessionStarted struct {
+	TenantID  TenantID
+	Username  string
+	SessionID string
+	Client    ClientMetadata
+}
+
+// SessionRevoked is the event raised when a session is revoked.
+type SessionRevoked struct {
+	TenantID  TenantID
+	Username  string
+	SessionID string
+	Reason    SessionRevocationReason
+}
+
+// SessionService is the service managing the sessions of users.
+// It handles the events disabling users and deactivating tenants, revoking their sessions.
+type SessionService interface {
+	EventHandler
+	StartSession(authentication *Authentication, client ClientMetadata) (*Session, string, error)
+	RefreshSession(tenantID TenantID, refreshToken string) (*Session, string, error)
+	Sessions(tenantID TenantID, username string) (Sessions, error)
+	RevokeSession(tenantID TenantID, username, sessionID string) error
+	RevokeSessions(tenantID TenantID, username string) error
+}
+
+// NewSessionService will create a new session service backed by supplied repositories, starting sessions
+// lasting ttl.
+func NewSessionService(
+	tenantRepository TenantRepository,
+	userRepository UserRepository,
+	sessionRepository SessionRepository,
+	eventPublisher EventPublisher,
+	ttl time.Duration,
+) SessionService {
+	return &sessionService{
+		tenantRepository:  tenantRepository,
+		userRepository:    userRepository,
+		sessionRepository: sessionRepository,
+		eventPublisher:    eventPublisher,
+		ttl:               ttl,
+	}
+}
+
+type sessionService struct {
+	tenantRepository  TenantRepository
+	userRepository    UserRepository
+	sessionRepository SessionRepository
+	eventPublisher    EventPublisher
+	ttl               time.Duration
+}
+
+// StartSession will start a session for the user of a complete authentication.
+func (s *sessionService) StartSession(authentication *Authentication, client ClientMetadata) (*Session, string, error) {
+	const op = "StartSession"
+	if authentication == nil || !authentication.IsComplete() {
+		return nil, "", &Error{Code: EUNAUTHORIZED, Message: "Authentication is not complete.", Op: op}
+	}
+	session, token, events, err := NewSession(authentication.User, client, s.ttl)
+	if err != nil {
+		return nil, "", err
+	}
+	if err := s.sessionRepository.Add(session); err != nil {
+		return nil, "", &Error{
+			Code:    EINTERNAL,
+			Message: "An unexpected error occurred while adding session.",
+			Op:      op,
+			Err:     err,
+		}
+	}
+	if err := s.eventPublisher.Publish(events); err != nil {
+		return nil, "", err
+	}
+	return session, token, nil
+}
+
+// RefreshSession will rotate the refresh token of the session, returning the new one.
+// Sessions of disabled users and deactivated tenants are revoked instead, without rotating the token.
+func (s *sessionService) RefreshSession(tenantID TenantID, refreshToken string) (*Session, string, error) {
+	const op = "RefreshSession"
+	session, err := s.sessionRepository.SessionWithRefreshToken(tenantID, hashToken(refreshToken))
+	if err != nil {
+		return nil, "", s.retrievalError(op, err)
+	}
+	if session == nil {
+		return nil, "", invalidRefreshToken(op)
+	}
+	reason, err := s.revocationReason(op, session)
+	if err != nil {
+		return nil, "", err
+	}
+	if reason != "" {
+		if events := session.Revoke(reason); len(events) > 0 {
+			if err := s.save(op, session, events); err != nil {
+				return nil, "", err
+			}
+		}
+		return nil, "", invalidRefreshToken(op)
+	}
+	previous := session.RefreshToken
+	token, events, err := session.Refresh(refreshToken)
+	if err != nil {
+		if len(events) > 0 {
+			if err := s.save(op, session, events); err != nil {
+				return nil, "", err
+			}
+		}
+		return nil, "", err
+	}
+	rotated, err := s.sessionRepository.Rotate(session, previous)
+	if err != nil {
+		return nil, "", s.updateError(op, err)
+	}
+	if !rotated {
+		if err := s.save(op, session, session.Revoke(SessionRevokedOnReuse)); err != nil {
+			return nil, "", err
+		}
+		return nil, "", invalidRefreshToken(op)
+	}
+	return session, token, nil
+}
+
+// Sessions will list the active sessions of the user.
+func (s *sessionService) Sessions(tenantID TenantID, username string) (Sessions, error) {
+	sessions, err := s.sessionRepository.ActiveSessionsOfUser(tenantID, username)
+	if err != nil {
+		return nil, s.retrievalError("Sessions", err)
+	}
+	return sessions, nil
+}
+
+// RevokeSession will revoke the session of the user with supplied id.
+func (s *sessionService) RevokeSession(tenantID TenantID, username, sessionID string) error {
+	const op = "RevokeSession"
+	session, err := s.sessionRepository.SessionOfID(tenantID, sessionID)
+	if err != nil {
+		return s.retrievalError(op, err)
+	}
+	if session == nil || session.Username != username {
+		return &Error{Code: ENOTFOUND, Message: "Session not found.", Op: op}
+	}
+	events := session.Revoke(SessionRevokedOnRequest)
+	if len(events) == 0 {
+		return nil
+	}
+	return s.save(op, session, events)
+}
+
+// RevokeSessions will revoke every active session of the user.
+func (s *sessionService) RevokeSessions(tenantID TenantID, username string) error {
+	const op = "RevokeSessions"
+	sessions, err := s.sessionRepository.ActiveSessionsOfUser(tenantID, username)
+	if err != nil {
+		return s.retrievalError(op, err)
+	}
+	return s.revokeAll(op, sessions, SessionRevokedOnRequest)
+}
+
+// HandleEvent will revoke the sessions of disabled users and deactivated tenants.
+func (s *sessionService) HandleEvent(event *Event) error {
+	const op = "HandleEvent"
+	var sessions Sessions
+	var reason SessionRevocationReason
+	var err error
+	switch p := event.Payload.(type) {
+	case *UserEnablementChanged:
+		if p.Enablement.IsEnabled() {
+			return nil
+		}
+		sessions, err = s.sessionRepository.ActiveSessionsOfUser(p.TenantID, p.Username)
+		reason = SessionRevokedOnUserDisabled
+	case *TenantDeactivated:
+		sessions, err = s.sessionRepository.ActiveSessionsOfTenant(p.TenantID)
+		reason = SessionRevokedOnTenantDeactivated
+	default:
+		return nil
+	}
+	if err != nil {
+		return s.retrievalError(op, err)
+	}
+	return s.revokeAll(op, sessions, reason)
+}
+
+// revocationReason will return the reason the session must be revoked for when its tenant is no longer active
+// or its user no longer enabled, empty otherwise.
+func (s *sessionService) revocationReason(op string, session *Session) (SessionRevocationReason, error) {
+	tenant, err := s.tenantRepository.TenantOfID(session.TenantID)
+	if err != nil {
+		return "", &Error{
+			Code:    EINTERNAL,
+			Message: "An unexpected error occurred while retrieving tenant.",
+			Op:      op,
+			Err:     err,
+		}
+	}
+	if tenant == nil || !tenant.Active {
+		return SessionRevokedOnTenantDeactivated, nil
+	}
+	user, err := s.userRepository.UserWithUsername(session.TenantID, session.Username)
+	if err != nil {
+		return "", &Error{
+			Code:    EINTERNAL,
+			Message: "An unexpected error occurred while retrieving user.",
+			Op:      op,
+			Err:     err,
+		}
+	}
+	if user == nil || !user.IsEnabled() {
+		return SessionRevokedOnUserDisabled, nil
+	}
+	return "", nil
+}
+
+func (s *sessionService) revokeAll(op string, sessions Sessions, reason SessionRevocationReason) error {
+	var events Events
+	for _, session := range sessions {
+		revoked := session.Revoke(reason)
+		if len(revoked) == 0 {
+			continue
+		}
+		if err := s.sessionRepository.Update(session); err != nil {
+			return s.updateError(op, err)
+		}
+		events = append(events, revoked...)
+	}
+	if len(events) == 0 {
+		return nil
+	}
+	return s.eventPublisher.Publish(events)
+}
+
+func (s *sessionService) save(op string, session *Session, events Events) error {
+	if err := s.sessionRepository.Update(session); err != nil {
+		return s.updateError(op, err)
+	}
+	return s.eventPublisher.Publish(events)
+}
+
+func (s *sessionService) retrievalError(op string, err error) error {
+	return &Error{
+		Code:    EINTERNAL,
+		Message: "An unexpected error occurred while retrieving session.",
+		Op:      op,
+		Err:     err,
+	}
+}
+
+func (s *sessionService) updateError(op string, err error) error {
+	return &Error{
+		Code:    EINTERNAL,
+		Message: "An unexpected error occurred while updating session.",
+		Op:      op,
+		Err:     err,
+	}
+}
@@ -0,0 +1,212 @@
+package iam_test
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/maurofran/iam"
+	"github.com/maurofran/iam/mock"
+)
+
+var _ = Describe("Session service", func() {
+	var (
+		tenant     *Tenant
+		user       *User
+		sessions   map[string]*Session
+		sr         *mock.SessionRepository
+		dispatcher *EventDispatcher
+		events     Events
+		service    SessionService
+	)
+
+	hasToken := func(s *Session, token string) bool {
+		if s.RefreshToken == token {
+			return true
+		}
+		for _, t := range s.RotatedRefreshTokens {
+			if t == token {
+				return true
+			}
+		}
+		return false
+	}
+
+	active := func(match func(*Session) bool) (Sessions, error) {
+		var ss Sessions
+		for _, s := range sessions {
+			if s.IsActive(time.Now()) && match(s) {
+				ss = append(ss, s)
+			}
+		}
+		return ss, nil
+	}
+
+	BeforeEach(func() {
+		tenant = &Tenant{ID: "tenant", Active: true}
+		user = &User{TenantID: "tenant", Username: "jdoe", Enablement: IndefiniteEnablement()}
+		sessions = map[string]*Session{}
+		tr := &mock.TenantRepository{
+			TenantOfIDFn: func(TenantID) (*Tenant, error) {
+				return tenant, nil
+			},
+		}
+		ur := &mock.UserRepository{
+			UserWithUsernameFn: func(TenantID, string) (*User, error) {
+				return user, nil
+			},
+		}
+		sr = &mock.SessionRepository{
+			AddFn: func(s *Session) error {
+				sessions[s.ID] = s
+				return nil
+			},
+			UpdateFn: func(*Session) error {
+				return nil
+			},
+			RotateFn: func(*Session, string) (bool, error) {
+				return true, nil
+			},
+			SessionOfIDFn: func(_ TenantID, id string) (*Session, error) {
+				return sessions[id], nil
+			},
+			SessionWithRefreshTokenFn: func(_ TenantID, token string) (*Session, error) {
+				for _, s := range sessions {
+					if hasToken(s, token) {
+						return s, nil
+					}
+				}
+				return nil, nil
+			},
+			ActiveSessionsOfUserFn: func(tenantID TenantID, username string) (Sessions, error) {
+				return active(func(s *Session) bool { return s.TenantID == tenantID && s.Username == username })
+			},
+			ActiveSessionsOfTenantFn: func(tenantID TenantID) (Sessions, error) {
+				return active(func(s *Session) bool { return s.TenantID == tenantID })
+			},
+		}
+		events = nil
+		dispatcher = NewEventDispatcher(&mock.EventPublisher{
+			PublishFn: func(ee Events) error {
+				events = append(events, ee...)
+				return nil
+			},
+		})
+		service = NewSessionService(tr, ur, sr, dispatcher, time.Hour)
+		dispatcher.Subscribe(service)
+	})
+
+	start := func() (*Session, string) {
+		session, token, err := service.StartSession(
+			&Authentication{Status: Authenticated, User: user},
+			ClientMetadata{UserAgent: "curl/8.0", IPAddress: "10.0.0.1"},
+		)
+		Expect(err).NotTo(HaveOccurred())
+		return session, token
+	}
+
+	It("should start a session with a hashed refresh token", func() {
+		session, token := start()
+		Expect(session.Username).To(Equal("jdoe"))
+		Expect(session.Client.UserAgent).To(Equal("curl/8.0"))
+		Expect(session.RefreshToken).NotTo(Equal(token))
+		Expect(session.ExpiresAt).To(BeTemporally("~", time.Now().Add(time.Hour), time.Second))
+		Expect(events[0].Type).To(Equal("SessionStarted"))
+	})
+
+	It("should refuse incomplete authentications", func() {
+		_, _, err := service.StartSession(&Authentication{Status: MFARequired}, ClientMetadata{})
+		Expect(ErrorCode(err)).To(Equal(EUNAUTHORIZED))
+	})
+
+	It("should rotate the refresh token", func() {
+		session, token := start()
+		refreshed, next, err := service.RefreshSession("tenant", token)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(refreshed).To(Equal(session))
+		Expect(next).NotTo(Equal(token))
+		_, _, err = service.RefreshSession("tenant", next)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("should revoke the family when a rotated token is reused", func() {
+		session, token := start()
+		_, next, _ := service.RefreshSession("tenant", token)
+		_, _, err := service.RefreshSession("tenant", token)
+		Expect(ErrorCode(err)).To(Equal(EUNAUTHORIZED))
+		Expect(session.RevocationReason).To(Equal(SessionRevokedOnReuse))
+		Expect(events[len(events)-1].Type).To(Equal("SessionRevoked"))
+		_, _, err = service.RefreshSession("tenant", next)
+		Expect(ErrorCode(err)).To(Equal(EUNAUTHORIZED))
+	})
+
+	It("should revoke the session when a concurrent refresh won", func() {
+		session, token := start()
+		sr.RotateFn = func(*Session, string) (bool, error) {
+			return false, nil
+		}
+		_, _, err := service.RefreshSession("tenant", token)
+		Expect(ErrorCode(err)).To(Equal(EUNAUTHORIZED))
+		Expect(session.IsActive(time.Now())).To(BeFalse())
+	})
+
+	It("should reject expired sessions", func() {
+		session, token := start()
+		session.ExpiresAt = time.Now().Add(-time.Second)
+		_, _, err := service.RefreshSession("tenant", token)
+		Expect(ErrorCode(err)).To(Equal(EUNAUTHORIZED))
+	})
+
+	It("should revoke instead of refreshing the session of a disabled user", func() {
+		session, token := start()
+		user.Enablement = Enablement{Enabled: false}
+		sr.RotateFn = func(*Session, string) (bool, error) {
+			Fail("refresh token rotated")
+			return false, nil
+		}
+		_, _, err := service.RefreshSession("tenant", token)
+		Expect(ErrorCode(err)).To(Equal(EUNAUTHORIZED))
+		Expect(session.RevocationReason).To(Equal(SessionRevokedOnUserDisabled))
+	})
+
+	It("should revoke instead of refreshing the session of a deactivated tenant", func() {
+		session, token := start()
+		tenant.Active = false
+		_, _, err := service.RefreshSession("tenant", token)
+		Expect(ErrorCode(err)).To(Equal(EUNAUTHORIZED))
+		Expect(session.RevocationReason).To(Equal(SessionRevokedOnTenantDeactivated))
+	})
+
+	It("should list and revoke the sessions of a user", func() {
+		first, _ := start()
+		start()
+		Expect(service.Sessions("tenant", "jdoe")).To(HaveLen(2))
+		Expect(service.RevokeSession("tenant", "jdoe", first.ID)).To(Succeed())
+		Expect(first.RevocationReason).To(Equal(SessionRevokedOnRequest))
+		Expect(service.Sessions("tenant", "jdoe")).To(HaveLen(1))
+		Expect(service.RevokeSessions("tenant", "jdoe")).To(Succeed())
+		Expect(service.Sessions("tenant", "jdoe")).To(BeEmpty())
+	})
+
+	It("should not revoke the sessions of another user", func() {
+		session, _ := start()
+		err := service.RevokeSession("tenant", "other", session.ID)
+		Expect(ErrorCode(err)).To(Equal(ENOTFOUND))
+		Expect(session.IsActive(time.Now())).To(BeTrue())
+	})
+
+	It("should revoke the sessions of a disabled user", func() {
+		session, _ := start()
+		Expect(dispatcher.Publish(user.DefineEnablement(Enablement{Enabled: true}))).To(Succeed())
+		Expect(session.IsActive(time.Now())).To(BeTrue())
+		Expect(dispatcher.Publish(user.DefineEnablement(Enablement{Enabled: false}))).To(Succeed())
+		Expect(session.RevocationReason).To(Equal(SessionRevokedOnUserDisabled))
+	})
+
+	It("should revoke the sessions of a deactivated tenant", func() {
+		session, _ := start()
+		Expect(dispatcher.Publish(tenant.Deactivate())).To(Succeed())
+		Expect(session.RevocationReason).To(Equal(SessionRevokedOnTenantDeactivated))
+	})
+})
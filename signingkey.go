@@ -0,0 +1,375 @@
+package iam
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"time"
+)
+
+// SigningAlgorithm is the enum type for the JSON web signature algorithm of a signing key.
+type SigningAlgorithm string
+
+// RS256 is RSASSA-PKCS1-v1_5 with SHA-256 on a 2048 bits key.
+// ES256 is ECDSA with SHA-256 on the P-256 curve.
+// EdDSA is Ed25519.
+const (
+	RS256 SigningAlgorithm = "RS256"
+	ES256 SigningAlgorithm = "ES256"
+	EdDSA SigningAlgorithm = "EdDSA"
+)
+
+// rsaKeyBits is the size of generated RSA keys.
+const rsaKeyBits = 2048
+
+// SigningKeyState is the enum type for the rotation state of a signing key.
+type SigningKeyState string
+
+// PendingSigningKey is the state of a new key published ahead of signing, so that verifiers learn it first.
+// ActiveSigningKey is the state of the key signing new tokens.
+// RetiringSigningKey is the state of a key no longer signing, still published so that issued tokens verify.
+// RetiredSigningKey is the state of a key neither signing nor published.
+const (
+	PendingSigningKey  SigningKeyState = "pending"
+	ActiveSigningKey   SigningKeyState = "active"
+	RetiringSigningKey SigningKeyState = "retiring"
+	RetiredSigningKey  SigningKeyState = "retired"
+)
+
+// SigningKeys is the collection of signing keys.
+type SigningKeys []*SigningKey
+
+// SigningKey is the aggregate root representing a key pair signing tokens.
+// PrivateKey holds the PKCS #8 encoding of the private key.
+type SigningKey struct {
+	ID          string           `bson:"kid"`
+	Algorithm   SigningAlgorithm `bson:"algorithm"`
+	PrivateKey  []byte           `bson:"privateKey" json:"-"`
+	State       SigningKeyState  `bson:"state"`
+	CreatedAt   time.Time        `bson:"createdAt"`
+	ActivatedAt time.Time        `bson:"activatedAt,omitempty"`
+	RetiringAt  time.Time        `bson:"retiringAt,omitempty"`
+	RetiredAt   time.Time        `bson:"retiredAt,omitempty"`
+}
+
+// NewSigningKey will generate a new pending signing key for supplied algorithm.
+func NewSigningKey(algorithm SigningAlgorithm) (*SigningKey, Events, error) {
+	const op = "NewSigningKey"
+	var private crypto.Signer
+	var err error
+	switch algorithm {
+	case RS256:
+		private, err = rsa.GenerateKey(rand.Reader, rsaKeyBits)
+	case ES256:
+		private, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case EdDSA:
+		_, private, err = ed25519.GenerateKey(rand.Reader)
+	default:
+		return nil, nil, &Error{Code: EINVALID, Message: "Unsupported signing algorithm.", Op: op}
+	}
+	if err != nil {
+		return nil, nil, signingKeyError(op, err)
+	}
+	encoded, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		return nil, nil, signingKeyError(op, err)
+	}
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return nil, nil, signingKeyError(op, err)
+	}
+	k := &SigningKey{
+		ID:         base64.RawURLEncoding.EncodeToString(id),
+		Algorithm:  algorithm,
+		PrivateKey: encoded,
+		State:      PendingSigningKey,
+		CreatedAt:  time.Now(),
+	}
+	return k, Events{EventWithPayload(&SigningKeyStateChanged{KeyID: k.ID, State: k.State})}, nil
+}
+
+func signingKeyError(op string, err error) error {
+	return &Error{
+		Code:    EINTERNAL,
+		Message: "An unexpected error occurred while generating signing key.",
+		Op:      op,
+		Err:     err,
+	}
+}
+
+// Signer will return the private key of the signing key.
+func (k *SigningKey) Signer() (crypto.Signer, error) {
+	private, err := x509.ParsePKCS8PrivateKey(k.PrivateKey)
+	if err != nil {
+		return nil, &Error{
+			Code:    EINTERNAL,
+			Message: "An unexpected error occurred while decoding signing key.",
+			Op:      "Signer",
+			Err:     err,
+		}
+	}
+	return private.(crypto.Signer), nil
+}
+
+// PublicKey will return the public key of the signing key.
+func (k *SigningKey) PublicKey() (crypto.PublicKey, error) {
+	signer, err := k.Signer()
+	if err != nil {
+		return nil, err
+	}
+	return signer.Public(), nil
+}
+
+// IsPublished will check if tokens signed with the key must verify.
+func (k *SigningKey) IsPublished() bool {
+	return k.State == PendingSigningKey || k.State == ActiveSigningKey || k.State == RetiringSigningKey
+}
+
+// Activate will make a pending key sign new tokens.
+func (k *SigningKey) Activate() Events {
+	if k.State != PendingSigningKey {
+		return nil
+	}
+	k.State = ActiveSigningKey
+	k.ActivatedAt = time.Now()
+	return Events{EventWithPayload(&SigningKeyStateChanged{KeyID: k.ID, State: k.State})}
+}
+
+// StartRetiring will stop the key from signing new tokens, keeping it published.
+func (k *SigningKey) StartRetiring() Events {
+	if k.State != ActiveSigningKey {
+		return nil
+	}
+	k.State = RetiringSigningKey
+	k.RetiringAt = time.Now()
+	return Events{EventWithPayload(&SigningKeyStateChanged{KeyID: k.ID, State: k.State})}
+}
+
+// Retire will stop publishing the key.
+func (k *SigningKey) Retire() Events {
+	if k.State == RetiredSigningKey {
+		return nil
+	}
+	k.State = RetiredSigningKey
+	k.RetiredAt = time.Now()
+	return Events{EventWithPayload(&SigningKeyStateChanged{KeyID: k.ID, State: k.State})}
+}
+
+// KeyStore is the repository of signing keys.
+type KeyStore interface {
+	Add(*SigningKey) error
+	Update(*SigningKey) error
+	SigningKeysInState(...SigningKeyState) (SigningKeys, error)
+}
+
+// SigningKeyStateChanged is the event raised when a signing key is generated or changes rotation state.
+type SigningKeyStateChanged struct {
+	KeyID string
+	State SigningKeyState
+}
+
+// SigningKeyService is the service rotating signing keys.
+type SigningKeyService interface {
+	RotateSigningKey(algorithm SigningAlgorithm) (*SigningKey, error)
+	ActivateSigningKeys() error
+	RetireSigningKeys() error
+	ActiveSigningKey() (*SigningKey, error)
+	PublishedSigningKeys() (SigningKeys, error)
+}
+
+// NewSigningKeyService will create a new signing key service backed by supplied key store.
+// Pending keys are activated once propagationDelay elapsed, which must exceed the time verifiers take to fetch
+// the published keys again. Retiring keys are retired once retirementDelay elapsed, which must exceed the lifetime
+// of signed tokens.
+func NewSigningKeyService(
+	keyStore KeyStore,
+	eventPublisher EventPublisher,
+	propagationDelay, retirementDelay time.Duration,
+) SigningKeyService {
+	return &signingKeyService{
+		keyStore:         keyStore,
+		eventPublisher:   eventPublisher,
+		propagationDelay: propagationDelay,
+		retirementDelay:  retirementDelay,
+	}
+}
+
+type signingKeyService struct {
+	keyStore         KeyStore
+	eventPublisher   EventPublisher
+	propagationDelay time.Duration
+	retirementDelay  time.Duration
+}
+
+// RotateSigningKey will generate a new pending key, published right away and activated by ActivateSigningKeys
+// after the propagation delay, so that verifiers know it before it signs. Without an active key, as on first
+// rotation, the new key is activated at once.
+func (s *signingKeyService) RotateSigningKey(algorithm SigningAlgorithm) (*SigningKey, error) {
+	const op = "RotateSigningKey"
+	keys, err := s.keys(op, PendingSigningKey, ActiveSigningKey, RetiringSigningKey)
+	if err != nil {
+		return nil, err
+	}
+	key, events, err := NewSigningKey(algorithm)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.keyStore.Add(key); err != nil {
+		return nil, &Error{
+			Code:    EINTERNAL,
+			Message: "An unexpected error occurred while adding signing key.",
+			Op:      op,
+			Err:     err,
+		}
+	}
+	for _, k := range keys {
+		if k.State != RetiringSigningKey || time.Since(k.RetiringAt) < s.retirementDelay {
+			continue
+		}
+		changed := k.Retire()
+		if err := s.update(op, k, changed); err != nil {
+			return nil, err
+		}
+		events = append(events, changed...)
+	}
+	activated, err := s.activate(op, append(keys, key))
+	if err != nil {
+		return nil, err
+	}
+	events = append(events, activated...)
+	if err := s.eventPublisher.Publish(events); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// ActivateSigningKeys will activate the keys pending for longer than the propagation delay.
+func (s *signingKeyService) ActivateSigningKeys() error {
+	const op = "ActivateSigningKeys"
+	keys, err := s.keys(op, PendingSigningKey, ActiveSigningKey)
+	if err != nil {
+		return err
+	}
+	events, err := s.activate(op, keys)
+	if err != nil || len(events) == 0 {
+		return err
+	}
+	return s.eventPublisher.Publish(events)
+}
+
+// activate will activate the pending keys among supplied ones that were published long enough, or all of them
+// when none is active, moving the previously active keys to retiring.
+func (s *signingKeyService) activate(op string, keys SigningKeys) (Events, error) {
+	var pending, active SigningKeys
+	for _, k := range keys {
+		switch k.State {
+		case PendingSigningKey:
+			pending = append(pending, k)
+		case ActiveSigningKey:
+			active = append(active, k)
+		}
+	}
+	var events Events
+	for _, k := range pending {
+		if len(active) > 0 && time.Since(k.CreatedAt) < s.propagationDelay {
+			continue
+		}
+		changed := k.Activate()
+		if err := s.update(op, k, changed); err != nil {
+			return nil, err
+		}
+		events = append(events, changed...)
+	}
+	if len(events) == 0 {
+		return nil, nil
+	}
+	for _, k := range active {
+		changed := k.StartRetiring()
+		if err := s.update(op, k, changed); err != nil {
+			return nil, err
+		}
+		events = append(events, changed...)
+	}
+	return events, nil
+}
+
+// RetireSigningKeys will retire the keys retiring for longer than the retirement delay.
+func (s *signingKeyService) RetireSigningKeys() error {
+	const op = "RetireSigningKeys"
+	keys, err := s.keys(op, RetiringSigningKey)
+	if err != nil {
+		return err
+	}
+	var events Events
+	for _, k := range keys {
+		if time.Since(k.RetiringAt) < s.retirementDelay {
+			continue
+		}
+		changed := k.Retire()
+		if err := s.update(op, k, changed); err != nil {
+			return err
+		}
+		events = append(events, changed...)
+	}
+	if len(events) == 0 {
+		return nil
+	}
+	return s.eventPublisher.Publish(events)
+}
+
+// ActiveSigningKey will return the most recent active key.
+func (s *signingKeyService) ActiveSigningKey() (*SigningKey, error) {
+	const op = "ActiveSigningKey"
+	keys, err := s.keys(op, ActiveSigningKey)
+	if err != nil {
+		return nil, err
+	}
+	var active *SigningKey
+	for _, k := range keys {
+		if active == nil || k.CreatedAt.After(active.CreatedAt) {
+			active = k
+		}
+	}
+	if active == nil {
+		return nil, &Error{Code: ENOTFOUND, Message: "No active signing key.", Op: op}
+	}
+	return active, nil
+}
+
+// PublishedSigningKeys will return the pending, active and retiring keys.
+func (s *signingKeyService) PublishedSigningKeys() (SigningKeys, error) {
+	return s.keys("PublishedSigningKeys", PendingSigningKey, ActiveSigningKey, RetiringSigningKey)
+}
+
+func (s *signingKeyService) keys(op string, states ...SigningKeyState) (SigningKeys, error) {
+	keys, err := s.keyStore.SigningKeysInState(states...)
+	if err != nil {
+		return nil, &Error{
+			Code:    EINTERNAL,
+			Message: "An unexpected error occurred while retrieving signing keys.",
+			Op:      op,
+			Err:     err,
+		}
+	}
+	return keys, nil
+}
+
+func (s *signingKeyService) update(op string, key *SigningKey, events Events) error {
+	if len(events) == 0 {
+		return nil
+	}
+	if err := s.keyStore.Update(key); err != nil {
+		return &Error{
+			Code:    EINTERNAL,
+			Message: "An unexpected error occurred while updating signing key.",
+			Op:      op,
+			Err:     err,
+		}
+	}
+	return nil
+}
@@ -0,0 +1,120 @@
+package iam_test
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+
+	. "github.com/maurofran/iam"
+	"github.com/maurofran/iam/memory"
+	"github.com/maurofran/iam/mock"
+)
+
+var _ = Describe("Signing key service", func() {
+	var (
+		keyStore *memory.KeyStore
+		events   Events
+		service  SigningKeyService
+	)
+
+	BeforeEach(func() {
+		keyStore = memory.NewKeyStore()
+		events = nil
+		ep := &mock.EventPublisher{
+			PublishFn: func(ee Events) error {
+				events = append(events, ee...)
+				return nil
+			},
+		}
+		service = NewSigningKeyService(keyStore, ep, time.Minute, time.Hour)
+	})
+
+	states := func() []SigningKeyState {
+		keys, err := keyStore.SigningKeysInState(PendingSigningKey, ActiveSigningKey, RetiringSigningKey, RetiredSigningKey)
+		Expect(err).NotTo(HaveOccurred())
+		var ss []SigningKeyState
+		for _, k := range keys {
+			ss = append(ss, k.State)
+		}
+		return ss
+	}
+
+	// propagate will make the pending keys look published for longer than the propagation delay.
+	propagate := func() {
+		keys, _ := keyStore.SigningKeysInState(PendingSigningKey)
+		for _, k := range keys {
+			k.CreatedAt = k.CreatedAt.Add(-2 * time.Minute)
+			keyStore.Update(k)
+		}
+	}
+
+	DescribeTable("should generate keys",
+		func(algorithm SigningAlgorithm) {
+			key, err := service.RotateSigningKey(algorithm)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(key.Algorithm).To(Equal(algorithm))
+			Expect(key.PublicKey()).NotTo(BeNil())
+			Expect(service.ActiveSigningKey()).To(Equal(key))
+		},
+		Entry("RS256", RS256),
+		Entry("ES256", ES256),
+		Entry("EdDSA", EdDSA),
+	)
+
+	It("should reject unsupported algorithms", func() {
+		_, err := service.RotateSigningKey("HS256")
+		Expect(ErrorCode(err)).To(Equal(EINVALID))
+	})
+
+	It("should publish the new key before it signs", func() {
+		first, _ := service.RotateSigningKey(ES256)
+		second, err := service.RotateSigningKey(ES256)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(states()).To(Equal([]SigningKeyState{ActiveSigningKey, PendingSigningKey}))
+		Expect(service.ActiveSigningKey()).To(Equal(first))
+		published, err := service.PublishedSigningKeys()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(published).To(HaveLen(2))
+		Expect(published[1].ID).To(Equal(second.ID))
+		Expect(service.ActivateSigningKeys()).To(Succeed())
+		Expect(states()).To(Equal([]SigningKeyState{ActiveSigningKey, PendingSigningKey}))
+	})
+
+	It("should move the active key to retiring on activation of the new one", func() {
+		first, _ := service.RotateSigningKey(ES256)
+		second, _ := service.RotateSigningKey(ES256)
+		propagate()
+		Expect(service.ActivateSigningKeys()).To(Succeed())
+		Expect(states()).To(Equal([]SigningKeyState{RetiringSigningKey, ActiveSigningKey}))
+		active, err := service.ActiveSigningKey()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(active.ID).To(Equal(second.ID))
+		published, err := service.PublishedSigningKeys()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(published).To(HaveLen(2))
+		Expect(published[0].ID).To(Equal(first.ID))
+		Expect(events).To(HaveLen(5))
+	})
+
+	It("should retire keys only after the retirement delay", func() {
+		service.RotateSigningKey(EdDSA)
+		service.RotateSigningKey(EdDSA)
+		propagate()
+		Expect(service.ActivateSigningKeys()).To(Succeed())
+		Expect(service.RetireSigningKeys()).To(Succeed())
+		Expect(states()).To(Equal([]SigningKeyState{RetiringSigningKey, ActiveSigningKey}))
+		keys, _ := keyStore.SigningKeysInState(RetiringSigningKey)
+		keys[0].RetiringAt = time.Now().Add(-2 * time.Hour)
+		keyStore.Update(keys[0])
+		Expect(service.RetireSigningKeys()).To(Succeed())
+		Expect(states()).To(Equal([]SigningKeyState{RetiredSigningKey, ActiveSigningKey}))
+		Expect(service.PublishedSigningKeys()).To(HaveLen(1))
+	})
+
+	It("should fail without an active key", func() {
+		_, err := service.ActiveSigningKey()
+		Expect(ErrorCode(err)).To(Equal(ENOTFOUND))
+	})
+})
@@ -0,0 +1,524 @@
+package iam
+
+import (
+	"bufio"
+	"embed"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// PasswordStrength is the value object holding the estimated strength of a password.
+// Score ranges from 0 (too guessable) to 4 (very unguessable), Entropy is the base 2 logarithm
+// of the estimated number of guesses and Feedback holds human readable suggestions.
+type PasswordStrength struct {
+	Score    int
+	Entropy  float64
+	Feedback []string
+}
+
+// EstimatePasswordStrength will estimate the strength of supplied password, searching it for common passwords,
+// dictionary words, banned words, keyboard walks, repeats, sequences, dates and leetspeak substitutions.
+// Only the first maxEstimatedLength runes are estimated, bounding the cost of long passwords.
+func EstimatePasswordStrength(password string, bannedWords ...string) PasswordStrength {
+	banned := make(map[string]int, len(bannedWords))
+	for _, w := range bannedWords {
+		if w = strings.ToLower(w); len(w) >= minPersonalInformationLength {
+			banned[w] = 1
+		}
+	}
+	runes := []rune(password)
+	if len(runes) > maxEstimatedLength {
+		runes = runes[:maxEstimatedLength]
+	}
+	e := &estimator{banned: banned, blocks: map[string]float64{}}
+	bits, used := e.estimate(runes)
+	ps := PasswordStrength{Score: score(bits), Entropy: bits}
+	seen := map[string]bool{}
+	for _, m := range used {
+		if msg := feedback[m.kind]; msg != "" && !seen[msg] {
+			seen[msg] = true
+			ps.Feedback = append(ps.Feedback, msg)
+		}
+	}
+	if ps.Score < strongScore {
+		ps.Feedback = append(ps.Feedback, "Add another word or two. Uncommon words are better.")
+	}
+	return ps
+}
+
+// maxEstimatedLength bounds the runes searched for patterns, since matching grows quadratically with the length.
+const maxEstimatedLength = 100
+
+// strongScore is the minimum score of strong passwords.
+// veryStrongScore is the minimum score of very strong passwords.
+const (
+	strongScore     = 3
+	veryStrongScore = 4
+)
+
+// score will convert the entropy to the 0-4 scale, using 10^3, 10^6, 10^8 and 10^10 guesses as thresholds.
+func score(bits float64) int {
+	switch {
+	case bits < 3*math.Log2(10):
+		return 0
+	case bits < 6*math.Log2(10):
+		return 1
+	case bits < 8*math.Log2(10):
+		return 2
+	case bits < 10*math.Log2(10):
+		return 3
+	}
+	return 4
+}
+
+type matchKind int
+
+const (
+	commonPasswordMatch matchKind = iota + 1
+	dictionaryMatch
+	bannedWordMatch
+	reversedMatch
+	leetMatch
+	sequenceMatch
+	repeatMatch
+	keyboardMatch
+	dateMatch
+)
+
+var feedback = map[matchKind]string{
+	commonPasswordMatch: "This is a very common password.",
+	dictionaryMatch:     "Words by themselves are easy to guess.",
+	bannedWordMatch:     "Avoid words and names tied to you or your organization.",
+	reversedMatch:       "Reversed words aren't much harder to guess.",
+	leetMatch:           "Predictable substitutions like '@' instead of 'a' don't help very much.",
+	sequenceMatch:       "Avoid sequences like abc or 6543.",
+	repeatMatch:         "Avoid repeated words and characters.",
+	keyboardMatch:       "Avoid keyboard patterns like qwerty or asdf.",
+	dateMatch:           "Avoid dates and years that are associated with you.",
+}
+
+// match is a guessable pattern found in the password between runes i and j (excluded).
+type match struct {
+	i, j int
+	bits float64
+	kind matchKind
+}
+
+// estimator will estimate the entropy of passwords, remembering the entropy of the repeated blocks
+// so that each block is estimated once.
+type estimator struct {
+	banned map[string]int
+	blocks map[string]float64
+}
+
+// estimate will return the minimum entropy of the password, as the cheapest sequence of matches
+// and brute forced characters covering it, together with the matches used.
+func (e *estimator) estimate(password []rune) (float64, []match) {
+	n := len(password)
+	matches := e.findMatches(password)
+	best := make([]float64, n+1)
+	back := make([]int, n+1)
+	for j := 1; j <= n; j++ {
+		best[j] = best[j-1] + bruteforceBits(password[j-1])
+		back[j] = -1
+		for k, m := range matches {
+			if m.j == j && best[m.i]+m.bits < best[j] {
+				best[j] = best[m.i] + m.bits
+				back[j] = k
+			}
+		}
+	}
+	var used []match
+	for j := n; j > 0; {
+		if back[j] < 0 {
+			j--
+			continue
+		}
+		m := matches[back[j]]
+		used = append(used, m)
+		j = m.i
+	}
+	return best[n], used
+}
+
+func bruteforceBits(ch rune) float64 {
+	switch {
+	case unicode.IsDigit(ch):
+		return math.Log2(10)
+	case unicode.IsLetter(ch):
+		return math.Log2(26)
+	}
+	return math.Log2(33)
+}
+
+func (e *estimator) findMatches(password []rune) []match {
+	var matches []match
+	matches = append(matches, dictionaryMatches(password, e.banned)...)
+	matches = append(matches, sequenceMatches(password)...)
+	matches = append(matches, e.repeatMatches(password)...)
+	matches = append(matches, keyboardMatches(password)...)
+	matches = append(matches, dateMatches(password)...)
+	return matches
+}
+
+//go:embed wordlists/*.txt
+var wordlists embed.FS
+
+var (
+	commonPasswords = loadWordlist("wordlists/passwords.txt")
+	englishWords    = loadWordlist("wordlists/english.txt")
+)
+
+// loadWordlist will load an embedded word list, ranking each word by its position.
+func loadWordlist(name string) map[string]int {
+	f, err := wordlists.Open(name)
+	if err != nil {
+		panic(err)
+	}
+	defer f.Close()
+	ranks := map[string]int{}
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		w := strings.TrimSpace(s.Text())
+		if _, ok := ranks[w]; w != "" && !ok {
+			ranks[w] = len(ranks) + 1
+		}
+	}
+	return ranks
+}
+
+// leetTables are the substitution tables tried when decoding leetspeak.
+var leetTables = []map[rune]rune{
+	{'4': 'a', '@': 'a', '8': 'b', '(': 'c', '3': 'e', '6': 'g', '1': 'i', '!': 'i', '0': 'o', '$': 's', '5': 's', '7': 't', '+': 't', '2': 'z'},
+	{'4': 'a', '@': 'a', '8': 'b', '(': 'c', '3': 'e', '6': 'g', '1': 'l', '|': 'l', '0': 'o', '$': 's', '5': 's', '7': 't', '+': 't', '2': 'z'},
+}
+
+// maxWordLength bounds the length of dictionary words searched in passwords.
+const maxWordLength = 24
+
+func dictionaryMatches(password []rune, banned map[string]int) []match {
+	lower := []rune(strings.ToLower(string(password)))
+	var matches []match
+	lookup := func(i, j int, word string, extra float64, kind matchKind) {
+		rank, k := 0, kind
+		if r, ok := banned[word]; ok {
+			rank, k = r, bannedWordMatch
+		} else if r, ok := commonPasswords[word]; ok {
+			rank = r
+			if kind == dictionaryMatch {
+				k = commonPasswordMatch
+			}
+		} else if r, ok := englishWords[word]; ok {
+			rank = r
+		}
+		if rank == 0 {
+			return
+		}
+		bits := math.Log2(float64(rank+1)) + uppercaseBits(password[i:j]) + extra
+		matches = append(matches, match{i: i, j: j, bits: bits, kind: k})
+	}
+	for i := range lower {
+		for j := i + minPersonalInformationLength; j <= len(lower) && j-i <= maxWordLength; j++ {
+			word := string(lower[i:j])
+			lookup(i, j, word, 0, dictionaryMatch)
+			lookup(i, j, reverse(word), 1, reversedMatch)
+			for _, table := range leetTables {
+				decoded, subs := unleet(lower[i:j], table)
+				if subs > 0 {
+					lookup(i, j, decoded, float64(subs), leetMatch)
+				}
+			}
+		}
+	}
+	return matches
+}
+
+func unleet(word []rune, table map[rune]rune) (string, int) {
+	decoded := make([]rune, len(word))
+	subs := 0
+	for i, ch := range word {
+		if sub, ok := table[ch]; ok {
+			decoded[i] = sub
+			subs++
+		} else {
+			decoded[i] = ch
+		}
+	}
+	return string(decoded), subs
+}
+
+func reverse(s string) string {
+	r := []rune(s)
+	for i, j := 0, len(r)-1; i < j; i, j = i+1, j-1 {
+		r[i], r[j] = r[j], r[i]
+	}
+	return string(r)
+}
+
+// uppercaseBits will estimate the bits added by capitalization: one for the common patterns,
+// the number of possible placements for the others.
+func uppercaseBits(word []rune) float64 {
+	var upper, lower int
+	for _, ch := range word {
+		if unicode.IsUpper(ch) {
+			upper++
+		} else if unicode.IsLower(ch) {
+			lower++
+		}
+	}
+	if upper == 0 {
+		return 0
+	}
+	if lower == 0 || (upper == 1 && (unicode.IsUpper(word[0]) || unicode.IsUpper(word[len(word)-1]))) {
+		return 1
+	}
+	combinations := 0.0
+	for k := 1; k <= upper && k <= lower; k++ {
+		combinations += binomial(upper+lower, k)
+	}
+	return math.Log2(combinations)
+}
+
+func binomial(n, k int) float64 {
+	r := 1.0
+	for i := 1; i <= k; i++ {
+		r = r * float64(n-k+i) / float64(i)
+	}
+	return r
+}
+
+// sequenceMatches will find runs of letters or digits with a constant step of one or two, as in abc or 9753.
+func sequenceMatches(password []rune) []match {
+	lower := []rune(strings.ToLower(string(password)))
+	var matches []match
+	for i := 0; i+2 < len(lower); {
+		delta := lower[i+1] - lower[i]
+		j := i + 1
+		for j < len(lower) && lower[j]-lower[j-1] == delta && sameClass(lower[j], lower[i]) {
+			j++
+		}
+		if j-i >= 3 && delta != 0 && delta >= -2 && delta <= 2 && (unicode.IsDigit(lower[i]) || unicode.IsLetter(lower[i])) {
+			base := 26.0
+			if unicode.IsDigit(lower[i]) {
+				base = 10
+			}
+			if strings.ContainsRune("az019", lower[i]) {
+				base = 4
+			}
+			bits := math.Log2(base * float64(j-i))
+			if delta < 0 {
+				bits++
+			}
+			matches = append(matches, match{i: i, j: j, bits: bits, kind: sequenceMatch})
+			i = j - 1
+			continue
+		}
+		i++
+	}
+	return matches
+}
+
+func sameClass(a, b rune) bool {
+	return unicode.IsDigit(a) == unicode.IsDigit(b) && unicode.IsLetter(a) == unicode.IsLetter(b)
+}
+
+// repeatMatches will find blocks repeated consecutively, as in aaa or abcabc.
+// A repeat costs as much as its block plus the number of repetitions.
+func (e *estimator) repeatMatches(password []rune) []match {
+	var matches []match
+	for i := range password {
+		for size := 1; i+2*size <= len(password); size++ {
+			block := string(password[i : i+size])
+			count := 1
+			for i+(count+1)*size <= len(password) && string(password[i+count*size:i+(count+1)*size]) == block {
+				count++
+			}
+			if count < 2 || (size == 1 && count < 3) {
+				continue
+			}
+			bits := e.blockBits(block) + math.Log2(float64(count))
+			matches = append(matches, match{i: i, j: i + count*size, bits: bits, kind: repeatMatch})
+		}
+	}
+	return matches
+}
+
+// blockBits will return the entropy of a repeated block, estimating it on first use.
+func (e *estimator) blockBits(block string) float64 {
+	bits, ok := e.blocks[block]
+	if !ok {
+		bits, _ = e.estimate([]rune(block))
+		e.blocks[block] = bits
+	}
+	return bits
+}
+
+var (
+	keyboardRows    = []string{"1234567890-=", "qwertyuiop[]", "asdfghjkl;'", "zxcvbnm,./"}
+	keyboardShifted = strings.NewReplacer("!", "1", "@", "2", "#", "3", "$", "4", "%", "5", "^", "6", "&", "7",
+		"*", "8", "(", "9", ")", "0", "_", "-", "+", "=", "{", "[", "}", "]", ":", ";", "\"", "'", "<", ",", ">", ".", "?", "/")
+	keyboardKeys = keyboardPositions()
+)
+
+type keyPosition struct{ row, col int }
+
+func keyboardPositions() map[rune]keyPosition {
+	keys := map[rune]keyPosition{}
+	for r, row := range keyboardRows {
+		for c, ch := range row {
+			keys[ch] = keyPosition{r, c}
+		}
+	}
+	return keys
+}
+
+// keyboardMatches will find walks of at least four adjacent keys on a qwerty keyboard, as in qwer or zaq1.
+// A walk costs the starting key, the turns taken and the shifted keys.
+func keyboardMatches(password []rune) []match {
+	keys := []rune(keyboardShifted.Replace(strings.ToLower(string(password))))
+	if len(keys) != len(password) {
+		return nil
+	}
+	var matches []match
+	for i := 0; i+3 < len(keys); i++ {
+		turns, shifted := 1, 0
+		var direction keyPosition
+		for j := i + 1; j <= len(keys); j++ {
+			if password[j-1] != keys[j-1] {
+				shifted = 1
+			}
+			if j-i >= 4 {
+				bits := math.Log2(float64(len(keyboardKeys))*float64(j-i)) + float64(turns)*math.Log2(4.6) + float64(shifted)
+				matches = append(matches, match{i: i, j: j, bits: bits, kind: keyboardMatch})
+			}
+			if j == len(keys) {
+				break
+			}
+			d, ok := adjacent(keys[j-1], keys[j])
+			if !ok {
+				break
+			}
+			if j > i+1 && d != direction {
+				turns++
+			}
+			direction = d
+		}
+	}
+	return matches
+}
+
+// adjacent will check if two keys are next to each other on the staggered layout, returning the direction.
+func adjacent(a, b rune) (keyPosition, bool) {
+	pa, ok := keyboardKeys[a]
+	if !ok {
+		return keyPosition{}, false
+	}
+	pb, ok := keyboardKeys[b]
+	if !ok {
+		return keyPosition{}, false
+	}
+	d := keyPosition{pb.row - pa.row, pb.col - pa.col}
+	switch d {
+	case keyPosition{0, -1}, keyPosition{0, 1}, keyPosition{-1, 0}, keyPosition{-1, 1}, keyPosition{1, -1}, keyPosition{1, 0}:
+		return d, true
+	}
+	return keyPosition{}, false
+}
+
+var dateWithSeparators = regexp.MustCompile(`^(\d{1,4})([\s/\\_.-])(\d{1,2})([\s/\\_.-])(\d{1,4})$`)
+
+// minYearSpace is the minimum distance in years from now considered when guessing years.
+const minYearSpace = 20
+
+// dateMatches will find years between 1900 and 2099 and dates, with or without separators.
+func dateMatches(password []rune) []match {
+	var matches []match
+	for i := range password {
+		for j := i + 4; j <= len(password) && j-i <= 10; j++ {
+			s := string(password[i:j])
+			if bits, ok := dateBits(s); ok {
+				matches = append(matches, match{i: i, j: j, bits: bits, kind: dateMatch})
+			}
+		}
+	}
+	return matches
+}
+
+func dateBits(s string) (float64, bool) {
+	if len(s) == 4 && isDigits(s) {
+		if y, _ := strconv.Atoi(s); y >= 1900 && y <= 2099 {
+			return math.Log2(yearSpace(y)), true
+		}
+	}
+	if m := dateWithSeparators.FindStringSubmatch(s); m != nil && m[2] == m[4] {
+		if y, ok := parseDate(m[1], m[3], m[5]); ok {
+			return math.Log2(365*yearSpace(y)) + 2, true
+		}
+		return 0, false
+	}
+	if !isDigits(s) || (len(s) != 6 && len(s) != 8) {
+		return 0, false
+	}
+	splits := [][3]int{{2, 2, len(s) - 4}, {len(s) - 4, 2, 2}}
+	for _, sp := range splits {
+		a, b, c := s[:sp[0]], s[sp[0]:sp[0]+sp[1]], s[sp[0]+sp[1]:]
+		if y, ok := parseDate(a, b, c); ok {
+			return math.Log2(365 * yearSpace(y)), true
+		}
+	}
+	return 0, false
+}
+
+// parseDate will try to read the three parts as day, month and year in any common order, returning the year.
+func parseDate(a, b, c string) (int, bool) {
+	na, _ := strconv.Atoi(a)
+	nb, _ := strconv.Atoi(b)
+	nc, _ := strconv.Atoi(c)
+	valid := func(d, m, y int, ylen int) (int, bool) {
+		if d < 1 || d > 31 || m < 1 || m > 12 || (ylen != 2 && ylen != 4) {
+			return 0, false
+		}
+		if ylen == 2 {
+			if y > 50 {
+				y += 1900
+			} else {
+				y += 2000
+			}
+		}
+		if y < 1900 || y > 2099 {
+			return 0, false
+		}
+		return y, true
+	}
+	if y, ok := valid(na, nb, nc, len(c)); ok {
+		return y, true
+	}
+	if y, ok := valid(nb, na, nc, len(c)); ok {
+		return y, true
+	}
+	if y, ok := valid(nc, nb, na, len(a)); ok {
+		return y, true
+	}
+	return 0, false
+}
+
+func yearSpace(year int) float64 {
+	space := math.Abs(float64(year - time.Now().Year()))
+	if space < minYearSpace {
+		return minYearSpace
+	}
+	return space
+}
+
+func isDigits(s string) bool {
+	for _, ch := range s {
+		if ch < '0' || ch > '9' {
+			return false
+		}
+	}
+	return s != ""
+}
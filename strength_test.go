@@ -0,0 +1,59 @@
+package iam_test
+
+import (
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+
+	. "github.com/maurofran/iam"
+)
+
+var _ = Describe("EstimatePasswordStrength", func() {
+	DescribeTable("should score guessable passwords as weak",
+		func(password string) {
+			strength := EstimatePasswordStrength(password)
+			Expect(strength.Score).To(BeNumerically("<", 3))
+			Expect(strength.Feedback).NotTo(BeEmpty())
+		},
+		Entry("common password", "Password1234!"),
+		Entry("leetspeak", "P@ssw0rd"),
+		Entry("keyboard walk", "qwertyuiop"),
+		Entry("shifted keyboard walk", "!QAZ2wsx"),
+		Entry("sequence", "abcdef123456"),
+		Entry("repeat", "abcabcabcabc"),
+		Entry("date", "monkey19/08/1984"),
+		Entry("reversed word", "drowssap"),
+	)
+
+	DescribeTable("should score unpredictable passwords as strong",
+		func(password string) {
+			Expect(EstimatePasswordStrength(password).Score).To(BeNumerically(">=", 3))
+		},
+		Entry("random characters", "gV7#pLq2!wZx"),
+		Entry("uncommon words", "correct-horse-battery-staple"),
+	)
+
+	It("should penalize banned words", func() {
+		password := "Zorblax-Kw9v"
+		Expect(EstimatePasswordStrength(password).Score).To(Equal(4))
+		Expect(EstimatePasswordStrength(password, "zorblax").Score).To(BeNumerically("<", 4))
+	})
+
+	It("should explain the patterns found", func() {
+		strength := EstimatePasswordStrength("qwerty")
+		Expect(strength.Score).To(Equal(0))
+		Expect(strength.Feedback).To(ContainElement("This is a very common password."))
+	})
+
+	It("should score long passwords quickly", func() {
+		for _, password := range []string{strings.Repeat("a", 1000), strings.Repeat("ab1", 334)} {
+			start := time.Now()
+			strength := EstimatePasswordStrength(password)
+			Expect(time.Since(start)).To(BeNumerically("<", time.Second))
+			Expect(strength.Score).To(BeNumerically("<", 3))
+		}
+	})
+})
@@ -1,19 +1,332 @@
 package iam
 
 import (
+	"crypto/rand"
+	"fmt"
+	"net/url"
 	"time"
 )
 
 // TenantID is the value object for a tenant identifier.
 type TenantID string
 
+// NewTenantID will generate a new random tenant identifier.
+func NewTenantID() (TenantID, error) {
+	id, err := newIdentity("NewTenantID")
+	return TenantID(id), err
+}
+
+// newIdentity will generate a new random (version 4) UUID.
+func newIdentity(op string) (string, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", &Error{
+			Code:    EINTERNAL,
+			Message: "An unexpected error occurred while generating identifier.",
+			Op:      op,
+			Err:     err,
+		}
+	}
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:]), nil
+}
+
+// Tenants is the collection of tenants.
+type Tenants []*Tenant
+
 // Tenant is the aggregate root object for the tenant.
 type Tenant struct {
-	ID          TenantID    `bson:"tenantId"`
-	Name        string      `bson:"name"`
-	Description string      `bson:"description,omitempty"`
-	Active      bool        `bson:"active"`
-	Invitations Invitations `bson:"invitations"`
+	ID                 TenantID           `bson:"tenantId"`
+	Name               string             `bson:"name"`
+	Description        string             `bson:"description,omitempty"`
+	Active             bool               `bson:"active"`
+	Invitations        Invitations        `bson:"invitations"`
+	PasswordPolicy     PasswordPolicy     `bson:"passwordPolicy"`
+	LockoutPolicy      LockoutPolicy      `bson:"lockoutPolicy"`
+	MFARequired        bool               `bson:"mfaRequired"`
+	PasswordlessPolicy PasswordlessPolicy `bson:"passwordlessPolicy"`
+}
+
+// Activate will activate the tenant.
+func (t *Tenant) Activate() Events {
+	if t.Active {
+		return nil
+	}
+	t.Active = true
+	return Events{EventWithPayload(&TenantActivated{TenantID: t.ID})}
+}
+
+// Deactivate will deactivate the tenant, suspending every tenant scoped operation.
+func (t *Tenant) Deactivate() Events {
+	if !t.Active {
+		return nil
+	}
+	t.Active = false
+	return Events{EventWithPayload(&TenantDeactivated{TenantID: t.ID})}
+}
+
+// DefinePasswordPolicy will define the password policy applied to the tenant users.
+func (t *Tenant) DefinePasswordPolicy(policy PasswordPolicy) (Events, error) {
+	const op = "DefinePasswordPolicy"
+	if err := t.assertActive(op); err != nil {
+		return nil, err
+	}
+	if policy.MinLength < 0 || policy.MaxLength < 0 || policy.MinStrength < 0 || policy.MaxRepeated < 0 || policy.HistoryDepth < 0 ||
+		policy.MaxAge < 0 || policy.ResetTokenTTL < 0 {
+		return nil, &Error{Code: EINVALID, Message: "Password policy limits cannot be negative.", Op: op}
+	}
+	if policy.MinStrength > veryStrongScore {
+		return nil, &Error{Code: EINVALID, Message: "Password policy minimum strength cannot exceed 4.", Op: op}
+	}
+	if policy.MaxLength > 0 && policy.MaxLength < policy.MinLength {
+		return nil, &Error{Code: EINVALID, Message: "Password policy maximum length cannot be less than minimum length.", Op: op}
+	}
+	t.PasswordPolicy = policy
+	return Events{EventWithPayload(&TenantPasswordPolicyDefined{
+		TenantID:       t.ID,
+		PasswordPolicy: policy,
+	})}, nil
+}
+
+// DefineLockoutPolicy will define the lockout policy applied to the tenant users.
+func (t *Tenant) DefineLockoutPolicy(policy LockoutPolicy) (Events, error) {
+	const op = "DefineLockoutPolicy"
+	if err := t.assertActive(op); err != nil {
+		return nil, err
+	}
+	if policy.MaxFailedAttempts < 0 || policy.Duration < 0 || policy.MaxDuration < 0 {
+		return nil, &Error{Code: EINVALID, Message: "Lockout policy limits cannot be negative.", Op: op}
+	}
+	if policy.MaxFailedAttempts > 0 && policy.Duration == 0 {
+		return nil, &Error{Code: EINVALID, Message: "Lockout duration is required.", Op: op}
+	}
+	t.LockoutPolicy = policy
+	return Events{EventWithPayload(&TenantLockoutPolicyDefined{
+		TenantID:      t.ID,
+		LockoutPolicy: policy,
+	})}, nil
+}
+
+// DefineMFARequirement will define if the tenant users must authenticate with a second factor.
+func (t *Tenant) DefineMFARequirement(required bool) (Events, error) {
+	if err := t.assertActive("DefineMFARequirement"); err != nil {
+		return nil, err
+	}
+	if t.MFARequired == required {
+		return nil, nil
+	}
+	t.MFARequired = required
+	return Events{EventWithPayload(&TenantMFARequirementDefined{TenantID: t.ID, Required: required})}, nil
+}
+
+// DefinePasswordlessPolicy will define the passwordless login policy applied to the tenant users.
+func (t *Tenant) DefinePasswordlessPolicy(policy PasswordlessPolicy) (Events, error) {
+	const op = "DefinePasswordlessPolicy"
+	if err := t.assertActive(op); err != nil {
+		return nil, err
+	}
+	if policy.TokenTTL < 0 || policy.MaxRequests < 0 || policy.RateWindow < 0 {
+		return nil, &Error{Code: EINVALID, Message: "Passwordless policy limits cannot be negative.", Op: op}
+	}
+	if policy.Enabled && policy.TokenTTL == 0 {
+		return nil, &Error{Code: EINVALID, Message: "Login challenge time to live is required.", Op: op}
+	}
+	if policy.LinkURL != "" {
+		if u, err := url.Parse(policy.LinkURL); err != nil || !u.IsAbs() {
+			return nil, &Error{Code: EINVALID, Message: "Login link URL must be absolute.", Op: op}
+		}
+	}
+	t.PasswordlessPolicy = policy
+	return Events{EventWithPayload(&TenantPasswordlessPolicyDefined{
+		TenantID:           t.ID,
+		PasswordlessPolicy: policy,
+	})}, nil
+}
+
+// ProvisionGroup will provision a new group for the tenant.
+func (t *Tenant) ProvisionGroup(name, description string) (*Group, Events, error) {
+	const op = "ProvisionGroup"
+	if err := t.assertActive(op); err != nil {
+		return nil, nil, err
+	}
+	if name == "" {
+		return nil, nil, &Error{Code: EINVALID, Message: "Group name is required.", Op: op}
+	}
+	g := &Group{
+		TenantID:    t.ID,
+		Name:        name,
+		Description: description,
+	}
+	return g, Events{EventWithPayload(&GroupProvisioned{
+		TenantID: t.ID,
+		Name:     name,
+	})}, nil
+}
+
+// ProvisionRole will provision a new role for the tenant.
+func (t *Tenant) ProvisionRole(name, description string, supportsNesting bool) (*Role, Events, error) {
+	const op = "ProvisionRole"
+	if err := t.assertActive(op); err != nil {
+		return nil, nil, err
+	}
+	if name == "" {
+		return nil, nil, &Error{Code: EINVALID, Message: "Role name is required.", Op: op}
+	}
+	r := &Role{
+		TenantID:        t.ID,
+		Name:            name,
+		Description:     description,
+		SupportsNesting: supportsNesting,
+	}
+	r.internalGroup()
+	return r, Events{EventWithPayload(&RoleProvisioned{
+		TenantID: t.ID,
+		Name:     name,
+	})}, nil
+}
+
+// ProvisionServiceAccount will provision a new service account for the tenant.
+func (t *Tenant) ProvisionServiceAccount(name, description string) (*ServiceAccount, Events, error) {
+	const op = "ProvisionServiceAccount"
+	if err := t.assertActive(op); err != nil {
+		return nil, nil, err
+	}
+	if name == "" {
+		return nil, nil, &Error{Code: EINVALID, Message: "Service account name is required.", Op: op}
+	}
+	a := &ServiceAccount{
+		TenantID:    t.ID,
+		Name:        name,
+		Description: description,
+		Enablement:  IndefiniteEnablement(),
+	}
+	return a, Events{EventWithPayload(&ServiceAccountProvisioned{
+		TenantID: t.ID,
+		Name:     name,
+	})}, nil
+}
+
+// RegisterClient will register a new OAuth 2.0 client for the tenant, returning its secret unless it is public.
+func (t *Tenant) RegisterClient(registration ClientRegistration) (*Client, string, Events, error) {
+	const op = "RegisterClient"
+	if err := t.assertActive(op); err != nil {
+		return nil, "", nil, err
+	}
+	c, secret, err := newClient(op, t.ID, registration)
+	if err != nil {
+		return nil, "", nil, err
+	}
+	return c, secret, Events{EventWithPayload(&ClientRegistered{
+		TenantID:   t.ID,
+		ClientID:   c.ID,
+		Name:       c.Name,
+		GrantTypes: c.GrantTypes,
+		Scopes:     c.Scopes,
+	})}, nil
+}
+
+// OfferRegistrationInvitation will offer a new, open ended, registration invitation.
+func (t *Tenant) OfferRegistrationInvitation(description string) (*Invitation, Events, error) {
+	const op = "OfferRegistrationInvitation"
+	if err := t.assertActive(op); err != nil {
+		return nil, nil, err
+	}
+	if description == "" {
+		return nil, nil, &Error{Code: EINVALID, Message: "Invitation description is required.", Op: op}
+	}
+	for _, i := range t.Invitations {
+		if i.Description == description {
+			return nil, nil, &Error{Code: ECONFLICT, Message: "Invitation already exists.", Op: op}
+		}
+	}
+	id, err := newIdentity(op)
+	if err != nil {
+		return nil, nil, err
+	}
+	i := &Invitation{ID: id, Description: description}
+	t.Invitations = append(t.Invitations, i)
+	return i, Events{EventWithPayload(&RegistrationInvitationOffered{
+		TenantID:     t.ID,
+		InvitationID: id,
+		Description:  description,
+	})}, nil
+}
+
+// WithdrawInvitation will withdraw the registration invitation with supplied identifier.
+func (t *Tenant) WithdrawInvitation(invitationID string) (Events, error) {
+	const op = "WithdrawInvitation"
+	if err := t.assertActive(op); err != nil {
+		return nil, err
+	}
+	for idx, i := range t.Invitations {
+		if i.ID == invitationID {
+			t.Invitations = append(t.Invitations[:idx], t.Invitations[idx+1:]...)
+			return Events{EventWithPayload(&RegistrationInvitationWithdrawn{
+				TenantID:     t.ID,
+				InvitationID: invitationID,
+			})}, nil
+		}
+	}
+	return nil, &Error{Code: ENOTFOUND, Message: "Invitation not found.", Op: op}
+}
+
+// RedefineInvitationAvailability will redefine the time window of the registration invitation.
+// Zero times leave the corresponding bound open.
+func (t *Tenant) RedefineInvitationAvailability(invitationID string, startingOn, until time.Time) (Events, error) {
+	const op = "RedefineInvitationAvailability"
+	if err := t.assertActive(op); err != nil {
+		return nil, err
+	}
+	if !startingOn.IsZero() && !until.IsZero() && until.Before(startingOn) {
+		return nil, &Error{Code: EINVALID, Message: "Invitation must start before it ends.", Op: op}
+	}
+	i := t.invitation(invitationID)
+	if i == nil {
+		return nil, &Error{Code: ENOTFOUND, Message: "Invitation not found.", Op: op}
+	}
+	i.StartingOn = startingOn
+	i.Until = until
+	return Events{EventWithPayload(&RegistrationInvitationRedefined{
+		TenantID:     t.ID,
+		InvitationID: invitationID,
+		StartingOn:   startingOn,
+		Until:        until,
+	})}, nil
+}
+
+// RegisterUser will register a new user through the registration invitation, while it is available.
+// Passwords found by the breached password checker are rejected, a nil checker disables the check.
+func (t *Tenant) RegisterUser(
+	invitationID, username, password string,
+	person *Person,
+	breachedPasswords BreachedPasswordChecker,
+) (*User, Events, error) {
+	const op = "RegisterUser"
+	if err := t.assertActive(op); err != nil {
+		return nil, nil, err
+	}
+	i := t.invitation(invitationID)
+	if i == nil || !i.IsAvailable(time.Now()) {
+		return nil, nil, &Error{Code: EINVALID, Message: "Invitation is not available.", Op: op}
+	}
+	return NewUser(string(t.ID), username, password, person, t.PasswordPolicy, breachedPasswords)
+}
+
+func (t *Tenant) assertActive(op string) error {
+	if !t.Active {
+		return &Error{Code: ECONFLICT, Message: "Tenant is not active.", Op: op}
+	}
+	return nil
+}
+
+func (t *Tenant) invitation(invitationID string) *Invitation {
+	for _, i := range t.Invitations {
+		if i.ID == invitationID {
+			return i
+		}
+	}
+	return nil
 }
 
 // TenantRepository is the interface for tenants.
@@ -23,6 +336,7 @@ type TenantRepository interface {
 	Remove(*Tenant) error
 	TenantNamed(string) (*Tenant, error)
 	TenantOfID(TenantID) (*Tenant, error)
+	AllTenants() (Tenants, error)
 }
 
 // Invitations is the collection of invitation entities.
@@ -35,3 +349,87 @@ type Invitation struct {
 	StartingOn  time.Time `bson:"startingOn"`
 	Until       time.Time `bson:"until"`
 }
+
+// IsAvailable will check if the invitation is open at supplied time.
+func (i *Invitation) IsAvailable(now time.Time) bool {
+	if !i.StartingOn.IsZero() && now.Before(i.StartingOn) {
+		return false
+	}
+	if !i.Until.IsZero() && now.After(i.Until) {
+		return false
+	}
+	return true
+}
+
+// TenantActivated is the event raised when a tenant is activated.
+type TenantActivated struct {
+	TenantID TenantID
+}
+
+// TenantDeactivated is the event raised when a tenant is deactivated.
+type TenantDeactivated struct {
+	TenantID TenantID
+}
+
+// TenantPasswordPolicyDefined is the event raised when the tenant password policy is defined.
+type TenantPasswordPolicyDefined struct {
+	TenantID       TenantID
+	PasswordPolicy PasswordPolicy
+}
+
+// TenantPasswordlessPolicyDefined is the event raised when the tenant passwordless policy is defined.
+type TenantPasswordlessPolicyDefined struct {
+	TenantID           TenantID
+	PasswordlessPolicy PasswordlessPolicy
+}
+
+// TenantMFARequirementDefined is the event raised when the tenant multi-factor authentication requirement is defined.
+type TenantMFARequirementDefined struct {
+	TenantID TenantID
+	Required bool
+}
+
+// TenantLockoutPolicyDefined is the event raised when the tenant lockout policy is defined.
+type TenantLockoutPolicyDefined struct {
+	TenantID      TenantID
+	LockoutPolicy LockoutPolicy
+}
+
+// GroupProvisioned is the event raised when a group is provisioned for a tenant.
+type GroupProvisioned struct {
+	TenantID TenantID
+	Name     string
+}
+
+// RoleProvisioned is the event raised when a role is provisioned for a tenant.
+type RoleProvisioned struct {
+	TenantID TenantID
+	Name     string
+}
+
+// ServiceAccountProvisioned is the event raised when a service account is provisioned for a tenant.
+type ServiceAccountProvisioned struct {
+	TenantID TenantID
+	Name     string
+}
+
+// RegistrationInvitationOffered is the event raised when a registration invitation is offered.
+type RegistrationInvitationOffered struct {
+	TenantID     TenantID
+	InvitationID string
+	Description  string
+}
+
+// RegistrationInvitationWithdrawn is the event raised when a registration invitation is withdrawn.
+type RegistrationInvitationWithdrawn struct {
+	TenantID     TenantID
+	InvitationID string
+}
+
+// RegistrationInvitationRedefined is the event raised when the availability of a registration invitation changes.
+type RegistrationInvitationRedefined struct {
+	TenantID     TenantID
+	InvitationID string
+	StartingOn   time.Time
+	Until        time.Time
+}
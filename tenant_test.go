@@ -0,0 +1,100 @@
+package iam_test
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/maurofran/iam"
+)
+
+var _ = Describe("Tenant", func() {
+	var (
+		t *Tenant
+		i *Invitation
+	)
+
+	BeforeEach(func() {
+		var err error
+		t = &Tenant{ID: "tenant", Name: "Tenant", Active: true}
+		i, _, err = t.OfferRegistrationInvitation("Early adopters")
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	Describe("#Deactivate", func() {
+		It("should emit the event once", func() {
+			events := t.Deactivate()
+			Expect(events).To(HaveLen(1))
+			Expect(events[0].Type).To(Equal("TenantDeactivated"))
+			Expect(t.Deactivate()).To(BeEmpty())
+			Expect(t.Active).To(BeFalse())
+		})
+		It("should suspend tenant scoped operations", func() {
+			t.Deactivate()
+			_, _, err := t.RegisterUser(i.ID, "jdoe", "gV7#pLq2!wZx", nil, nil)
+			Expect(ErrorCode(err)).To(Equal(ECONFLICT))
+			_, _, err = t.ProvisionGroup("devs", "")
+			Expect(ErrorCode(err)).To(Equal(ECONFLICT))
+			_, _, err = t.ProvisionRole("deployer", "", false)
+			Expect(ErrorCode(err)).To(Equal(ECONFLICT))
+			_, _, err = t.OfferRegistrationInvitation("Late adopters")
+			Expect(ErrorCode(err)).To(Equal(ECONFLICT))
+		})
+	})
+
+	Describe("#Activate", func() {
+		It("should resume tenant scoped operations", func() {
+			t.Deactivate()
+			events := t.Activate()
+			Expect(events).To(HaveLen(1))
+			Expect(events[0].Type).To(Equal("TenantActivated"))
+			_, _, err := t.ProvisionGroup("devs", "")
+			Expect(err).NotTo(HaveOccurred())
+		})
+	})
+
+	Describe("#OfferRegistrationInvitation", func() {
+		It("should refuse a duplicate description", func() {
+			_, _, err := t.OfferRegistrationInvitation("Early adopters")
+			Expect(ErrorCode(err)).To(Equal(ECONFLICT))
+		})
+	})
+
+	Describe("#RedefineInvitationAvailability", func() {
+		It("should bound the invitation availability", func() {
+			now := time.Now()
+			_, err := t.RedefineInvitationAvailability(i.ID, now, now.Add(time.Hour))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(i.IsAvailable(now.Add(time.Minute))).To(BeTrue())
+			Expect(i.IsAvailable(now.Add(-time.Minute))).To(BeFalse())
+			Expect(i.IsAvailable(now.Add(2 * time.Hour))).To(BeFalse())
+		})
+		It("should refuse an inverted window", func() {
+			now := time.Now()
+			_, err := t.RedefineInvitationAvailability(i.ID, now, now.Add(-time.Hour))
+			Expect(ErrorCode(err)).To(Equal(EINVALID))
+		})
+	})
+
+	Describe("#RegisterUser", func() {
+		It("should register a user with an open invitation", func() {
+			u, events, err := t.RegisterUser(i.ID, "jdoe", "gV7#pLq2!wZx", nil, nil)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(u.TenantID).To(Equal(t.ID))
+			Expect(events).To(HaveLen(1))
+		})
+		It("should refuse an expired invitation", func() {
+			_, err := t.RedefineInvitationAvailability(i.ID, time.Time{}, time.Now().Add(-time.Hour))
+			Expect(err).NotTo(HaveOccurred())
+			_, _, err = t.RegisterUser(i.ID, "jdoe", "gV7#pLq2!wZx", nil, nil)
+			Expect(ErrorCode(err)).To(Equal(EINVALID))
+		})
+		It("should refuse a withdrawn invitation", func() {
+			_, err := t.WithdrawInvitation(i.ID)
+			Expect(err).NotTo(HaveOccurred())
+			_, _, err = t.RegisterUser(i.ID, "jdoe", "gV7#pLq2!wZx", nil, nil)
+			Expect(ErrorCode(err)).To(Equal(EINVALID))
+		})
+	})
+})
@@ -0,0 +1,32 @@
+package iam
+
+import "time"
+
+// BearerTokenType is the type of access tokens to send in the Authorization header.
+const BearerTokenType = "Bearer"
+
+// AccessToken is the value object holding a signed access token and its expiration.
+type AccessToken struct {
+	Token     string
+	TokenType string
+	ExpiresAt time.Time
+}
+
+// IDTokenGrant is the value object holding what an ID token asserts about the authentication of a user to a client.
+// Nonce is the value the client sent along the authorization request, if any.
+type IDTokenGrant struct {
+	User     *User
+	ClientID string
+	Scope    string
+	Nonce    string
+	AuthTime time.Time
+}
+
+// TokenService is the service issuing access tokens to authenticated users and service accounts, and ID tokens to
+// OpenID Connect clients. The client id identifies the OAuth 2.0 client the token is issued to, if any, that is
+// its audience, and the scope is the one granted to it.
+type TokenService interface {
+	IssueAccessToken(authentication *Authentication, clientID, scope string) (*AccessToken, error)
+	IssueServiceAccountAccessToken(account *ServiceAccount, clientID, scope string) (*AccessToken, error)
+	IssueIDToken(grant *IDTokenGrant) (string, error)
+}
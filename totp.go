@@ -0,0 +1,102 @@
+package iam
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// totpPeriod is the time step of the codes.
+// totpDigits is the number of digits of the codes.
+// totpDrift is the number of steps before and after the current one accepted, to allow for clock drift.
+// totpSecretLength is the number of random bytes of the secrets, as recommended for HMAC-SHA1.
+const (
+	totpPeriod       = 30 * time.Second
+	totpDigits       = 6
+	totpDrift        = 1
+	totpSecretLength = 20
+)
+
+var totpEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// newTOTPSecret will generate a random base32 encoded secret.
+func newTOTPSecret(op string) (string, error) {
+	b := make([]byte, totpSecretLength)
+	if _, err := rand.Read(b); err != nil {
+		return "", &Error{
+			Code:    EINTERNAL,
+			Message: "An unexpected error occurred while generating secret.",
+			Op:      op,
+			Err:     err,
+		}
+	}
+	return totpEncoding.EncodeToString(b), nil
+}
+
+// totpStep will return the time step of supplied time.
+func totpStep(t time.Time) int64 {
+	return t.Unix() / int64(totpPeriod/time.Second)
+}
+
+// totpCode will compute the code of the base32 encoded secret for the time step, as defined by RFC 6238 and RFC 4226.
+func totpCode(secret string, step int64) (string, error) {
+	key, err := totpEncoding.DecodeString(strings.ToUpper(secret))
+	if err != nil {
+		return "", err
+	}
+	var counter [8]byte
+	binary.BigEndian.PutUint64(counter[:], uint64(step))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(counter[:])
+	sum := mac.Sum(nil)
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	mod := uint32(1)
+	for i := 0; i < totpDigits; i++ {
+		mod *= 10
+	}
+	return fmt.Sprintf("%0*d", totpDigits, value%mod), nil
+}
+
+// GenerateTOTPCode will compute the code of the base32 encoded secret at supplied time, as an authenticator app does.
+func GenerateTOTPCode(secret string, at time.Time) (string, error) {
+	return totpCode(secret, totpStep(at))
+}
+
+// verifyTOTP will check the code against the steps in the drift window around now, skipping the steps
+// not after the last used one so that a code is never accepted twice. It returns the matching step.
+func verifyTOTP(secret, code string, lastUsedStep int64, now time.Time) (int64, bool) {
+	current := totpStep(now)
+	for step := current - totpDrift; step <= current+totpDrift; step++ {
+		if step <= lastUsedStep {
+			continue
+		}
+		expected, err := totpCode(secret, step)
+		if err != nil {
+			return 0, false
+		}
+		if subtle.ConstantTimeCompare([]byte(expected), []byte(code)) == 1 {
+			return step, true
+		}
+	}
+	return 0, false
+}
+
+// totpURI will return the otpauth URI used by authenticator apps to provision the secret.
+func totpURI(issuer, account, secret string) string {
+	label := url.PathEscape(issuer) + ":" + url.PathEscape(account)
+	params := url.Values{}
+	params.Set("secret", secret)
+	params.Set("issuer", issuer)
+	params.Set("algorithm", "SHA1")
+	params.Set("digits", fmt.Sprint(totpDigits))
+	params.Set("period", fmt.Sprint(int(totpPeriod/time.Second)))
+	return "otpauth://totp/" + label + "?" + params.Encode()
+}
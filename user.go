@@ -1,19 +1,38 @@
 package iam
 
+import "time"
+
 // Users is the type for a collection of users.
 type Users []*User
 
 // User is the aggregate root representing a user.
+// PasswordHistory holds the hashes of previous passwords, most recent first.
+// FailedAttempts counts the failed authentications since the last success or lockout,
+// Lockouts the consecutive lockouts since the last success.
 type User struct {
-	TenantID   TenantID   `bson:"tenantId"`
-	Username   string     `bson:"username"`
-	Password   string     `bson:"password"`
-	Enablement Enablement `bson:"enablement"`
-	Person     *Person    `bson:"person"`
+	TenantID           TenantID       `bson:"tenantId"`
+	Username           string         `bson:"username"`
+	Password           string         `bson:"password"`
+	PasswordHistory    []string       `bson:"passwordHistory,omitempty" json:"-"`
+	PasswordChangedAt  time.Time      `bson:"passwordChangedAt,omitempty"`
+	MustChangePassword bool           `bson:"mustChangePassword"`
+	FailedAttempts     int            `bson:"failedAttempts"`
+	Lockouts           int            `bson:"lockouts"`
+	LockedUntil        time.Time      `bson:"lockedUntil"`
+	SecondFactor       *SecondFactor  `bson:"secondFactor,omitempty" json:"-"`
+	PasswordReset      *PasswordReset `bson:"passwordReset,omitempty" json:"-"`
+	Enablement         Enablement     `bson:"enablement"`
+	Person             *Person        `bson:"person"`
 }
 
-// NewUser will create a new user with supplied initial data.
-func NewUser(tenantID, username, password string, person *Person) (*User, Events, error) {
+// NewUser will create a new user with supplied initial data, validating the password against the policy.
+// Passwords found by the breached password checker are rejected, a nil checker disables the check.
+func NewUser(
+	tenantID, username, password string,
+	person *Person,
+	policy PasswordPolicy,
+	breachedPasswords BreachedPasswordChecker,
+) (*User, Events, error) {
 	u := &User{
 		TenantID:   TenantID(tenantID),
 		Username:   username,
@@ -25,7 +44,7 @@ func NewUser(tenantID, username, password string, person *Person) (*User, Events
 		u.Person.FullName = person.FullName
 		u.Person.ContactInformation = person.ContactInformation
 	}
-	if _, err := u.ChangePassword("", password); err != nil {
+	if err := u.protectPassword("NewUser", password, policy, breachedPasswords); err != nil {
 		return nil, nil, err
 	}
 	events := Events{EventWithPayload(&UserRegistered{
@@ -37,48 +56,189 @@ func NewUser(tenantID, username, password string, person *Person) (*User, Events
 	return u, events, nil
 }
 
-// ChangePassword will change the new password.
-func (u *User) ChangePassword(current, changed string) (Events, error) {
-	enc, err := encrypt(current)
-	if err != nil {
-		return nil, err
-	}
-	if enc != u.Password {
+// ChangePassword will change the new password, validating it against the policy and the breached password checker.
+func (u *User) ChangePassword(
+	current, changed string,
+	policy PasswordPolicy,
+	breachedPasswords BreachedPasswordChecker,
+) (Events, error) {
+	const op = "ChangePassword"
+	if !u.VerifyPassword(current) {
 		return nil, &Error{
 			Code:    EINVALID,
 			Message: "Current password non confirmed.",
-			Op:      "ChangePassword",
+			Op:      op,
 		}
 	}
-	if changed == u.Username {
+	if changed == current {
 		return nil, &Error{
 			Code:    EINVALID,
-			Message: "Changed password must be different from username.",
-			Op:      "ChangePassword",
+			Message: "Changed password must be different from current one.",
+			Op:      op,
 		}
 	}
-	chenc, err := encrypt(changed)
-	if err != nil {
+	if err := u.protectPassword(op, changed, policy, breachedPasswords); err != nil {
 		return nil, err
 	}
-	if chenc == enc {
+	return Events{EventWithPayload(&UserPasswordChanged{
+		TenantID: u.TenantID,
+		Username: u.Username,
+	})}, nil
+}
+
+// VerifyPassword will check if supplied plain password matches the user password.
+func (u *User) VerifyPassword(plain string) bool {
+	if u.Password == "" {
+		return false
+	}
+	return matches(plain, u.Password)
+}
+
+// AssignTemporaryPassword will replace the password with a generated one, that must be changed at next login.
+func (u *User) AssignTemporaryPassword(policy PasswordPolicy, breachedPasswords BreachedPasswordChecker) (string, Events, error) {
+	const op = "AssignTemporaryPassword"
+	password, err := GeneratePassword(policy)
+	if err != nil {
+		return "", nil, err
+	}
+	if err := u.protectPassword(op, password, policy, breachedPasswords); err != nil {
+		return "", nil, err
+	}
+	u.MustChangePassword = true
+	return password, Events{EventWithPayload(&UserTemporaryPasswordAssigned{
+		TenantID: u.TenantID,
+		Username: u.Username,
+	})}, nil
+}
+
+// RequirePasswordChange will force the user to change password at next login.
+func (u *User) RequirePasswordChange() Events {
+	if u.MustChangePassword {
+		return nil
+	}
+	u.MustChangePassword = true
+	return Events{EventWithPayload(&UserPasswordChangeRequired{
+		TenantID: u.TenantID,
+		Username: u.Username,
+	})}
+}
+
+// IsPasswordExpired will check if the password is older than supplied maximum age.
+// A zero maximum age or an unknown change time never expire.
+func (u *User) IsPasswordExpired(maxAge time.Duration) bool {
+	if maxAge <= 0 || u.PasswordChangedAt.IsZero() {
+		return false
+	}
+	return time.Now().After(u.PasswordChangedAt.Add(maxAge))
+}
+
+// ExpirePassword will force a password change when the password is older than supplied maximum age.
+func (u *User) ExpirePassword(maxAge time.Duration) Events {
+	if u.MustChangePassword || !u.IsPasswordExpired(maxAge) {
+		return nil
+	}
+	u.MustChangePassword = true
+	return Events{EventWithPayload(&UserPasswordExpired{
+		TenantID:          u.TenantID,
+		Username:          u.Username,
+		PasswordChangedAt: u.PasswordChangedAt,
+	})}
+}
+
+// PasswordNeedsRehash will check if the user password was protected with an outdated algorithm or cost.
+func (u *User) PasswordNeedsRehash() bool {
+	return u.Password != "" && DefaultPasswordHasher.NeedsRehash(u.Password)
+}
+
+// RehashPassword will protect again the confirmed plain password with the default hasher.
+func (u *User) RehashPassword(plain string) (Events, error) {
+	const op = "RehashPassword"
+	if !u.VerifyPassword(plain) {
 		return nil, &Error{
 			Code:    EINVALID,
-			Message: "Changed password must be different from current one.",
-			Op:      "ChangePassword",
+			Message: "Current password non confirmed.",
+			Op:      op,
 		}
 	}
-	// Check that new password is not equal to current.
-	u.Password, err = encrypt(changed)
+	return u.rehashPassword(plain)
+}
+
+// rehashPassword will protect again the plain password with the default hasher, once confirmed by the caller.
+func (u *User) rehashPassword(plain string) (Events, error) {
+	enc, err := encrypt(plain)
 	if err != nil {
 		return nil, err
 	}
-	return Events{EventWithPayload(&UserPasswordChanged{
+	u.Password = enc
+	return Events{EventWithPayload(&UserPasswordRehashed{
 		TenantID: u.TenantID,
 		Username: u.Username,
 	})}, nil
 }
 
+func (u *User) protectPassword(
+	op, password string,
+	policy PasswordPolicy,
+	breachedPasswords BreachedPasswordChecker,
+) error {
+	if password == "" {
+		return &Error{
+			Code:    EINVALID,
+			Message: "Password is required.",
+			Op:      op,
+		}
+	}
+	if err := policy.Validate(password, u); err != nil {
+		return &Error{
+			Code:    EINVALID,
+			Message: "Password does not satisfy the password policy.",
+			Op:      op,
+			Err:     err,
+		}
+	}
+	if err := checkBreached(op, password, breachedPasswords); err != nil {
+		return err
+	}
+	enc, err := encrypt(password)
+	if err != nil {
+		return err
+	}
+	u.retirePassword(policy.HistoryDepth)
+	u.Password = enc
+	u.PasswordChangedAt = time.Now()
+	u.MustChangePassword = false
+	return nil
+}
+
+// retirePassword will move the current password into the history, keeping at most depth entries.
+func (u *User) retirePassword(depth int) {
+	if u.Password != "" {
+		u.PasswordHistory = append([]string{u.Password}, u.PasswordHistory...)
+	}
+	if depth < 0 {
+		depth = 0
+	}
+	if len(u.PasswordHistory) > depth {
+		u.PasswordHistory = u.PasswordHistory[:depth]
+	}
+}
+
+// isPasswordReused will check if supplied password matches the current one or one of the last depth previous ones.
+func (u *User) isPasswordReused(password string, depth int) bool {
+	if u.VerifyPassword(password) {
+		return true
+	}
+	for i, enc := range u.PasswordHistory {
+		if i >= depth {
+			break
+		}
+		if matches(password, enc) {
+			return true
+		}
+	}
+	return false
+}
+
 // ChangeContactInformation will change the contact information of a user.
 func (u *User) ChangeContactInformation(contactInformation ContactInformation) Events {
 	u.Person.ContactInformation = contactInformation
@@ -131,6 +291,31 @@ type UserPasswordChanged struct {
 	Username string
 }
 
+// UserTemporaryPasswordAssigned is the event raised when a generated temporary password is assigned to a user.
+type UserTemporaryPasswordAssigned struct {
+	TenantID TenantID
+	Username string
+}
+
+// UserPasswordChangeRequired is the event raised when a password change is required to a user.
+type UserPasswordChangeRequired struct {
+	TenantID TenantID
+	Username string
+}
+
+// UserPasswordExpired is the event raised when the password for a user expired.
+type UserPasswordExpired struct {
+	TenantID          TenantID
+	Username          string
+	PasswordChangedAt time.Time
+}
+
+// UserPasswordRehashed is the event raised when the password for a user was protected again with the default hasher.
+type UserPasswordRehashed struct {
+	TenantID TenantID
+	Username string
+}
+
 // UserEnablementChanged is the event raised when the user enablement changes.
 type UserEnablementChanged struct {
 	TenantID   TenantID
@@ -153,10 +338,20 @@ type PersonNameChanged struct {
 }
 
 // UserRepository is the interace for user repository.
+// Update must not write the password history, that users loaded by listings lack. ResetPassword must update the
+// user, password history included, only while its stored password reset token is the supplied hash, returning
+// false otherwise, so that a password reset token is used at most once.
 type UserRepository interface {
 	Add(*User) error
 	Update(*User) error
 	Remove(*User) error
 	UserWithUsername(TenantID, string) (*User, error)
+	UserWithEmailAddress(TenantID, EmailAddress) (*User, error)
+	UserWithPasswordResetToken(TenantID, string) (*User, error)
 	AllSimilarlyNamedUsers(TenantID, string, string) (Users, error)
+	AllUsersWithPasswordChangedBefore(TenantID, time.Time) (Users, error)
+	ExpirePassword(TenantID, string, time.Time) (bool, error)
+	ResetPassword(*User, string) (bool, error)
+	IncrementFailedAttempts(TenantID, string) (int, error)
+	UpdateLockout(*User) error
 }
@@ -0,0 +1,84 @@
+package iam_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/maurofran/iam"
+)
+
+var _ = Describe("User", func() {
+	var u *User
+
+	BeforeEach(func() {
+		var err error
+		u, _, err = NewUser("tenant", "jdoe", "gV7#pLq2!wZx", nil, DefaultPasswordPolicy(), nil)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	Describe("#VerifyPassword", func() {
+		It("should accept the current password", func() {
+			Expect(u.VerifyPassword("gV7#pLq2!wZx")).To(BeTrue())
+		})
+		It("should reject a different password", func() {
+			Expect(u.VerifyPassword("wrong")).To(BeFalse())
+		})
+	})
+
+	Describe("#RehashPassword", func() {
+		It("should rehash the confirmed password", func() {
+			previous := u.Password
+			events, err := u.RehashPassword("gV7#pLq2!wZx")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(events[0].Type).To(Equal("UserPasswordRehashed"))
+			Expect(u.Password).NotTo(Equal(previous))
+			Expect(u.VerifyPassword("gV7#pLq2!wZx")).To(BeTrue())
+		})
+		It("should not replace the password with an unconfirmed one", func() {
+			_, err := u.RehashPassword("wrong")
+			Expect(ErrorCode(err)).To(Equal(EINVALID))
+			Expect(u.VerifyPassword("gV7#pLq2!wZx")).To(BeTrue())
+		})
+	})
+
+	Describe("#AssignTemporaryPassword", func() {
+		It("should assign a generated password to be changed", func() {
+			password, events, err := u.AssignTemporaryPassword(DefaultPasswordPolicy(), nil)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(events).To(HaveLen(1))
+			Expect(u.VerifyPassword(password)).To(BeTrue())
+			Expect(u.MustChangePassword).To(BeTrue())
+		})
+	})
+
+	Describe("#ChangePassword", func() {
+		It("should change the password", func() {
+			events, err := u.ChangePassword("gV7#pLq2!wZx", "Rk4$mN8@tYb1", DefaultPasswordPolicy(), nil)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(events).To(HaveLen(1))
+			Expect(events[0].Type).To(Equal("UserPasswordChanged"))
+			Expect(u.VerifyPassword("Rk4$mN8@tYb1")).To(BeTrue())
+			Expect(u.VerifyPassword("gV7#pLq2!wZx")).To(BeFalse())
+		})
+		It("should clear a required password change", func() {
+			events := u.RequirePasswordChange()
+			Expect(events).To(HaveLen(1))
+			Expect(events[0].Type).To(Equal("UserPasswordChangeRequired"))
+			_, err := u.ChangePassword("gV7#pLq2!wZx", "Rk4$mN8@tYb1", DefaultPasswordPolicy(), nil)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(u.MustChangePassword).To(BeFalse())
+		})
+		It("should reject a wrong current password", func() {
+			_, err := u.ChangePassword("wrong", "Rk4$mN8@tYb1", DefaultPasswordPolicy(), nil)
+			Expect(ErrorCode(err)).To(Equal(EINVALID))
+		})
+		It("should reject the same password", func() {
+			_, err := u.ChangePassword("gV7#pLq2!wZx", "gV7#pLq2!wZx", DefaultPasswordPolicy(), nil)
+			Expect(ErrorCode(err)).To(Equal(EINVALID))
+		})
+		It("should reject the username as password", func() {
+			_, err := u.ChangePassword("gV7#pLq2!wZx", "jdoe", DefaultPasswordPolicy(), nil)
+			Expect(ErrorCode(err)).To(Equal(EINVALID))
+		})
+	})
+})